@@ -0,0 +1,123 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/db"
+	"github.com/3-brain-cells/sah-backend/ical"
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-chi/chi"
+)
+
+// Link to the iCalendar file of a finalized event (given its ID)
+const eventCalendarURL = "https://kairosaio.com/api/v1/events/%s/event.ics"
+
+// GetEventCalendar returns an iCalendar file with the final time and location of an event
+// (once it has been finalized) so that it can be added to calendar apps
+func GetEventCalendar(eventProvider db.EventProvider, discordSession *discordgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the event ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("GetEventCalendar event_id=%s", id)
+		event, err := eventProvider.GetSingle(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		if !event.Finalized {
+			util.ErrorWithCode(r, w, errors.New("the event hasn't been finalized yet"),
+				http.StatusNotFound)
+			return
+		}
+
+		// Fill in the organizer's name from their membership in the guild
+		creator := []types.User{{ID: event.CreatorID}}
+		userColorsAndNames(event.GuildID, discordSession)(creator)
+
+		calendar := ical.Calendar{
+			Events: []ical.Event{calendarEvent(*event, creator[0].Name)},
+		}
+		writeCalendar(w, r, &calendar, fmt.Sprintf("%s.ics", event.EventID))
+	}
+}
+
+// writeCalendar writes the calendar as the response
+func writeCalendar(w http.ResponseWriter, r *http.Request, calendar *ical.Calendar, filename string) {
+	var body strings.Builder
+	err := ical.Write(&body, calendar, time.Now())
+	if err != nil {
+		util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(body.String()))
+}
+
+// calendarEvent converts a finalized event to an iCalendar event
+// (with a UID that stays the same across exports)
+func calendarEvent(event types.Event, organizerName string) ical.Event {
+	finalLocation := event.FinalLocation
+
+	description := event.Description
+	if len(event.FinalTime.Users) > 0 {
+		names := make([]string, len(event.FinalTime.Users))
+		for i, user := range event.FinalTime.Users {
+			names[i] = user.Name
+			if names[i] == "" {
+				names[i] = user.ID
+			}
+		}
+		description = strings.TrimSpace(description + "\n\nAttending: " + strings.Join(names, ", "))
+	}
+
+	calendarEvent := ical.Event{
+		UID:         fmt.Sprintf("%s@super-auto-hangouts", event.EventID),
+		Summary:     event.Title,
+		Description: description,
+		Location:    calendarLocation(event),
+		Organizer: &ical.Organizer{
+			Name: organizerName,
+			URI:  fmt.Sprintf("https://discord.com/users/%s", event.CreatorID),
+		},
+		URL:   fmt.Sprintf("https://super-auto-hangouts.netlify.app/vote/%s", event.EventID),
+		Start: event.FinalTime.Start,
+		End:   event.FinalTime.End,
+	}
+	if finalLocation.Latitude != 0 || finalLocation.Longitude != 0 {
+		calendarEvent.Geo = &ical.Geo{
+			Latitude:  finalLocation.Latitude,
+			Longitude: finalLocation.Longitude,
+		}
+	}
+	return calendarEvent
+}
+
+// calendarLocation describes where the event takes place:
+// the final venue (if it has one), otherwise its meeting link or voice channel
+func calendarLocation(event types.Event) string {
+	venue := strings.Trim(fmt.Sprintf("%s, %s", event.FinalLocation.Name, event.FinalLocation.Address), ", ")
+	switch {
+	case venue != "" && hasVenue(event):
+		return venue
+	case event.MeetingLink != "":
+		return event.MeetingLink
+	case event.VoiceChannelID != "":
+		return fmt.Sprintf("https://discord.com/channels/%s/%s", event.GuildID, event.VoiceChannelID)
+	}
+	return venue
+}
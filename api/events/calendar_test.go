@@ -0,0 +1,50 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+func TestCalendarEvent(t *testing.T) {
+	start := time.Date(2022, time.March, 4, 23, 0, 0, 0, time.UTC)
+	event := types.Event{
+		EventID:     "abcde",
+		CreatorID:   "1234",
+		Title:       "Dinner",
+		Description: "Celebrating the end of exams",
+		Finalized:   true,
+		FinalTime: types.TimePair{
+			Start: start,
+			End:   start.Add(2 * time.Hour),
+			Users: []types.User{{ID: "1234", Name: "alice"}, {ID: "5678"}},
+		},
+		FinalLocation: types.Location{
+			Name:      "Pizza Place",
+			Address:   "123 Main St",
+			Latitude:  33.7756,
+			Longitude: -84.3963,
+		},
+	}
+
+	calendarEvent := calendarEvent(event, "alice")
+	if calendarEvent.UID != "abcde@super-auto-hangouts" {
+		t.Errorf("expected a UID based on the event ID, got %q", calendarEvent.UID)
+	}
+	if calendarEvent.Location != "Pizza Place, 123 Main St" {
+		t.Errorf("expected the location name and address, got %q", calendarEvent.Location)
+	}
+	if calendarEvent.Description != "Celebrating the end of exams\n\nAttending: alice, 5678" {
+		t.Errorf("expected the description to list who is attending, got %q", calendarEvent.Description)
+	}
+	if calendarEvent.Geo == nil || calendarEvent.Geo.Latitude != 33.7756 || calendarEvent.Geo.Longitude != -84.3963 {
+		t.Errorf("expected the location's coordinates, got %+v", calendarEvent.Geo)
+	}
+	if calendarEvent.Organizer == nil || calendarEvent.Organizer.Name != "alice" {
+		t.Errorf("expected the creator to be the organizer, got %+v", calendarEvent.Organizer)
+	}
+	if !calendarEvent.Start.Equal(event.FinalTime.Start) || !calendarEvent.End.Equal(event.FinalTime.End) {
+		t.Errorf("expected the final time, got %v - %v", calendarEvent.Start, calendarEvent.End)
+	}
+}
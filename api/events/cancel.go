@@ -0,0 +1,59 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/3-brain-cells/sah-backend/api/oauth"
+	"github.com/3-brain-cells/sah-backend/bot"
+	"github.com/3-brain-cells/sah-backend/db"
+	"github.com/3-brain-cells/sah-backend/util"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-chi/chi"
+)
+
+// CancelEvent cancels an event (which can only be done by its creator)
+// and announces the cancellation in the event's channel.
+// It has to be mounted behind oauth.Sessions.RequireSession.
+func CancelEvent(eventProvider db.EventProvider, discordSession *discordgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the event ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		event, err := eventProvider.GetSingle(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		if event == nil {
+			util.ErrorWithCode(r, w, errors.New("event not found"),
+				http.StatusNotFound)
+			return
+		}
+		if event.CreatorID != oauth.SessionUser(r.Context()) {
+			util.ErrorWithCode(r, w, errors.New("only the creator of the event can cancel it"),
+				http.StatusForbidden)
+			return
+		}
+		if event.Cancelled {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		log.Printf("CancelEvent event_id=%s", id)
+		err = eventProvider.CancelEvent(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		bot.SchedulingMessage(discordSession, fmt.Sprintf("Event **%s** has been cancelled.", event.Title), event.ChannelID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
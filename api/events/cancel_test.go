@@ -0,0 +1,26 @@
+package events
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+func TestCancelEventRequiresCreatorSession(t *testing.T) {
+	// The fake panics if the event is actually cancelled
+	events := &fakeEventProvider{event: &types.Event{CreatorID: "001"}}
+	handler := testSessions.RequireSession(CancelEvent(events, nil)).ServeHTTP
+
+	expectedCodes := map[string]int{
+		"":    http.StatusUnauthorized,
+		"002": http.StatusForbidden,
+	}
+	for sessionUserID, expected := range expectedCodes {
+		// The user ID in the query string is ignored
+		recorder := serve(http.MethodDelete, "/{id}", handler, "/e?user_id=001", sessionUserID)
+		if recorder.Code != expected {
+			t.Errorf("session %q: status = %d, want %d", sessionUserID, recorder.Code, expected)
+		}
+	}
+}
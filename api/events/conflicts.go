@@ -0,0 +1,117 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/db"
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+type availabilityWarning struct {
+	// The other event that the availability conflicts with
+	EventID string    `json:"event_id"`
+	Title   string    `json:"title"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Message string    `json:"message"`
+}
+
+// busyTimes maps the ID of each user that has submitted availability =>
+// the times of other finalized events in the same guild that they are attending
+func busyTimes(ctx context.Context, eventProvider db.EventProvider, event types.Event) (map[string][]types.TimeWindow, error) {
+	userIDs := make([]string, 0, len(event.UserAvailability))
+	for userID := range event.UserAvailability {
+		userIDs = append(userIDs, userID)
+	}
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	otherEvents, err := eventProvider.GetFinalizedEventsForUsers(ctx, event.GuildID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	busy := make(map[string][]types.TimeWindow)
+	for _, otherEvent := range otherEvents {
+		if otherEvent.EventID == event.EventID {
+			continue
+		}
+		for _, user := range otherEvent.FinalTime.Users {
+			busy[user.ID] = append(busy[user.ID], types.TimeWindow{
+				Start: otherEvent.FinalTime.Start,
+				End:   otherEvent.FinalTime.End,
+			})
+		}
+	}
+	return busy, nil
+}
+
+// conflictWarnings finds the other finalized events in the same guild
+// that the user is attending and that overlap their submitted availability
+func conflictWarnings(ctx context.Context, eventProvider db.EventProvider, event types.Event, userID string, days []types.DayAvailability) ([]availabilityWarning, error) {
+	otherEvents, err := eventProvider.GetFinalizedEventsForUsers(ctx, event.GuildID, []string{userID})
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := []availabilityWarning{}
+	for _, otherEvent := range otherEvents {
+		if otherEvent.EventID == event.EventID {
+			continue
+		}
+		finalTime := otherEvent.FinalTime
+		if !overlapsAny(days, finalTime.Start, finalTime.End) {
+			continue
+		}
+		warnings = append(warnings, availabilityWarning{
+			EventID: otherEvent.EventID,
+			Title:   otherEvent.Title,
+			Start:   finalTime.Start,
+			End:     finalTime.End,
+			Message: fmt.Sprintf("you are already attending %s on %s from %d:%02d till %d:%02d, so that time won't be considered",
+				otherEvent.Title, finalTime.Start.Format("01-02-2006"), finalTime.Start.Hour(), finalTime.Start.Minute(), finalTime.End.Hour(), finalTime.End.Minute()),
+		})
+	}
+	sort.Slice(warnings, func(i, j int) bool { return warnings[i].Start.Before(warnings[j].Start) })
+	return warnings, nil
+}
+
+func overlapsAny(days []types.DayAvailability, start time.Time, end time.Time) bool {
+	for _, day := range days {
+		for _, block := range day.AvailableBlocks {
+			if block.Start.Before(end) && block.End.After(start) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// subtractBusy removes the busy times from the blocks,
+// splitting blocks that have busy times in the middle
+func subtractBusy(blocks []types.AvailabilityBlock, busy []types.TimeWindow) []types.AvailabilityBlock {
+	for _, busyTime := range busy {
+		var remaining []types.AvailabilityBlock
+		for _, block := range blocks {
+			if !block.Start.Before(busyTime.End) || !block.End.After(busyTime.Start) {
+				remaining = append(remaining, block)
+				continue
+			}
+			if block.Start.Before(busyTime.Start) {
+				remaining = append(remaining, types.AvailabilityBlock{Start: block.Start, End: busyTime.Start})
+			}
+			if block.End.After(busyTime.End) {
+				remaining = append(remaining, types.AvailabilityBlock{Start: busyTime.End, End: block.End})
+			}
+		}
+		blocks = remaining
+	}
+	if blocks == nil {
+		return []types.AvailabilityBlock{}
+	}
+	return blocks
+}
@@ -0,0 +1,34 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+func TestSubtractBusy(t *testing.T) {
+	mar4 := time.Date(2022, time.March, 4, 0, 0, 0, 0, time.UTC)
+	blocks := []types.AvailabilityBlock{
+		timeBlock(mar4, 12, 0, 14, 0),
+		timeBlock(mar4, 17, 0, 23, 0),
+	}
+	busy := []types.TimeWindow{
+		{Start: mar4.Add(11 * time.Hour), End: mar4.Add(15 * time.Hour)},
+		{Start: mar4.Add(19 * time.Hour), End: mar4.Add(21 * time.Hour)},
+	}
+
+	remaining := subtractBusy(blocks, busy)
+	expected := []types.AvailabilityBlock{
+		timeBlock(mar4, 17, 0, 19, 0),
+		timeBlock(mar4, 21, 0, 23, 0),
+	}
+	if len(remaining) != len(expected) {
+		t.Fatalf("expected %d blocks, got %+v", len(expected), remaining)
+	}
+	for i := range expected {
+		if !remaining[i].Start.Equal(expected[i].Start) || !remaining[i].End.Equal(expected[i].End) {
+			t.Errorf("block %d: expected %+v, got %+v", i, expected[i], remaining[i])
+		}
+	}
+}
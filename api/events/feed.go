@@ -0,0 +1,83 @@
+package events
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/db"
+	"github.com/3-brain-cells/sah-backend/ical"
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+	"github.com/go-chi/chi"
+)
+
+// How often calendar apps should check the feed for changes
+const feedRefreshInterval = time.Hour
+
+// GetFeed returns a subscribable iCalendar feed of every finalized event
+// that the user (identified by the secret token in the URL) took part in, across all guilds.
+// Events keep the same UID, so calendar apps update them when they are rescheduled,
+// and cancelled events stay in the feed (marked as cancelled) so they get removed.
+func GetFeed(eventProvider db.EventProvider, profileProvider db.ProfileProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimSuffix(chi.URLParam(r, "token"), ".ics")
+		if token == "" {
+			util.ErrorWithCode(r, w, errors.New("the token URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		userID, err := profileProvider.GetFeedUser(r.Context(), token)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		log.Printf("GetFeed user_id=%s", userID)
+		events, err := eventProvider.GetEventsForUser(r.Context(), userID)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		calendar := ical.Calendar{
+			Name:            "Super Auto Hangouts",
+			RefreshInterval: feedRefreshInterval,
+			Events:          feedEvents(events),
+		}
+		writeCalendar(w, r, &calendar, "hangouts.ics")
+	}
+}
+
+// feedEvents converts the finalized events to iCalendar events, sorted by start
+func feedEvents(events []*types.Event) []ical.Event {
+	calendarEvents := []ical.Event{}
+	for _, event := range events {
+		if !event.Finalized {
+			continue
+		}
+
+		calendarEvent := calendarEvent(*event, organizerName(*event))
+		calendarEvent.Cancelled = event.Cancelled
+		calendarEvent.Sequence = event.Sequence
+		calendarEvent.LastModified = event.UpdatedAt
+		calendarEvents = append(calendarEvents, calendarEvent)
+	}
+	sort.Slice(calendarEvents, func(i, j int) bool { return calendarEvents[i].Start.Before(calendarEvents[j].Start) })
+	return calendarEvents
+}
+
+// organizerName finds the name of the event's creator
+// from the names stored with the users attending the event (if they are attending)
+func organizerName(event types.Event) string {
+	for _, user := range event.FinalTime.Users {
+		if user.ID == event.CreatorID {
+			return user.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package events
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/ical"
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+func TestFeedEvents(t *testing.T) {
+	start := time.Date(2022, time.March, 4, 23, 0, 0, 0, time.UTC)
+	updated := time.Date(2022, time.March, 2, 12, 0, 0, 0, time.UTC)
+	events := []*types.Event{
+		{
+			EventID:   "later",
+			CreatorID: "1234",
+			Title:     "Games",
+			Finalized: true,
+			FinalTime: types.TimePair{
+				Start: start.AddDate(0, 0, 7),
+				End:   start.AddDate(0, 0, 7).Add(time.Hour),
+				Users: []types.User{{ID: "1234", Name: "alice"}},
+			},
+		},
+		{
+			EventID:   "voting",
+			Title:     "Not finalized yet",
+			Finalized: false,
+		},
+		{
+			EventID:   "moved",
+			CreatorID: "5678",
+			Title:     "Dinner",
+			Finalized: true,
+			FinalTime: types.TimePair{Start: start, End: start.Add(2 * time.Hour)},
+			Cancelled: true,
+			Sequence:  2,
+			UpdatedAt: updated,
+		},
+	}
+
+	calendarEvents := feedEvents(events)
+	if len(calendarEvents) != 2 {
+		t.Fatalf("expected only the 2 finalized events, got %+v", calendarEvents)
+	}
+	if calendarEvents[0].UID != "moved@super-auto-hangouts" || calendarEvents[1].UID != "later@super-auto-hangouts" {
+		t.Errorf("expected the events to be sorted by start, got %s then %s", calendarEvents[0].UID, calendarEvents[1].UID)
+	}
+	if calendarEvents[1].Organizer.Name != "alice" {
+		t.Errorf("expected the organizer's name from the attending users, got %q", calendarEvents[1].Organizer.Name)
+	}
+
+	var written strings.Builder
+	err := ical.Write(&written, &ical.Calendar{Events: calendarEvents}, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"STATUS:CANCELLED\r\n", "SEQUENCE:2\r\n", "LAST-MODIFIED:20220302T120000Z\r\n"} {
+		if !strings.Contains(written.String(), expected) {
+			t.Errorf("expected the feed to contain %q, got %s", expected, written.String())
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/3-brain-cells/sah-backend/api/locations"
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+)
+
+// ambiguousLocationResponseBody is returned when the user's address matches more than one place,
+// so they can choose one and send its coordinates (and label) as their location
+type ambiguousLocationResponseBody struct {
+	Message    string                    `json:"message"`
+	Candidates []locations.GeocodeResult `json:"candidates"`
+}
+
+// resolveLocation geocodes the address if the user didn't give their coordinates.
+// If the address is ambiguous, then the location is nil and the candidates are returned instead.
+func resolveLocation(ctx context.Context, geocoder locations.Geocoder, location types.UserLocation, address string) (*types.UserLocation, []locations.GeocodeResult, error) {
+	address = strings.TrimSpace(address)
+	if locations.HasLocation(location) || address == "" {
+		return &location, nil, nil
+	}
+
+	result, candidates, err := locations.Resolve(ctx, geocoder, address)
+	if errors.Is(err, locations.ErrNoGeocodeResults) {
+		return nil, nil, &util.ValidationError{Fields: []types.FieldError{{
+			Field:   "address",
+			Message: "no places match the address",
+		}}}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if result == nil {
+		return nil, candidates, nil
+	}
+
+	resolved := result.Location()
+	return &resolved, nil, nil
+}
+
+// writeLocationCandidates responds with the places that an ambiguous address matches
+func writeLocationCandidates(r *http.Request, w http.ResponseWriter, candidates []locations.GeocodeResult) {
+	responseBody := ambiguousLocationResponseBody{
+		Message:    "the address matches more than one place",
+		Candidates: candidates,
+	}
+
+	jsonResponse, err := json.Marshal(&responseBody)
+	if err != nil {
+		util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	w.Write(jsonResponse)
+}
@@ -0,0 +1,148 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/db"
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-chi/chi"
+)
+
+// slotLength is the granularity of the availability grid
+const slotLength = 30 * time.Minute
+
+type getHeatmapResponseBody struct {
+	// Number of users that have submitted availability
+	Participants int           `json:"participants"`
+	Slots        []heatmapSlot `json:"slots"`
+}
+
+type heatmapSlot struct {
+	Start time.Time    `json:"start"`
+	End   time.Time    `json:"end"`
+	Count int          `json:"count"`
+	Users []types.User `json:"users"`
+}
+
+// GetAvailabilityHeatmap returns the aggregate availability of every user
+// for each slot in the event's window
+func GetAvailabilityHeatmap(eventProvider db.EventProvider, discordSession *discordgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the event ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("GetAvailabilityHeatmap event_id=%s", id)
+		event, err := eventProvider.GetSingle(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		slots := availabilityHeatmap(*event)
+		// Add all user colors and names to the slots
+		addUserColorsAndNames(event.GuildID, slots, discordSession)
+
+		responseSlots := make([]heatmapSlot, len(slots))
+		for i, slot := range slots {
+			responseSlots[i] = heatmapSlot{
+				Start: slot.Start,
+				End:   slot.End,
+				Count: len(slot.Users),
+				Users: slot.Users,
+			}
+		}
+		responseBody := getHeatmapResponseBody{
+			Participants: len(event.UserAvailability),
+			Slots:        responseSlots,
+		}
+
+		jsonResponse, err := json.Marshal(&responseBody)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// availabilityHeatmap returns every slot in the event's window,
+// each with the users that are available for the entire slot
+func availabilityHeatmap(event types.Event) []types.TimePair {
+	blocks := userBlocks(event)
+	slots := eventSlots(event)
+	for i := range slots {
+		slots[i].Users = availableUsers(blocks, slots[i].Start, slots[i].End)
+	}
+	return slots
+}
+
+// eventSlots divides each of the event's windows
+// into consecutive slots of slotLength
+func eventSlots(event types.Event) []types.TimePair {
+	var slots []types.TimePair
+	for _, window := range eventWindows(event) {
+		slots = append(slots, windowSlots(window)...)
+	}
+	return slots
+}
+
+// windowSlots divides a single window into consecutive slots of slotLength
+func windowSlots(window types.TimeWindow) []types.TimePair {
+	var slots []types.TimePair
+	for start := window.Start; !start.Add(slotLength).After(window.End); start = start.Add(slotLength) {
+		slots = append(slots, types.TimePair{
+			Start: start,
+			End:   start.Add(slotLength),
+			Users: []types.User{},
+		})
+	}
+	return slots
+}
+
+// userBlocks maps each user's ID => all of their blocks of availability
+func userBlocks(event types.Event) map[string][]types.AvailabilityBlock {
+	blocks := make(map[string][]types.AvailabilityBlock)
+	for userID, userAvailability := range event.UserAvailability {
+		blocks[userID] = []types.AvailabilityBlock{}
+		for _, dayAvailability := range userAvailability.DayAvailability {
+			blocks[userID] = append(blocks[userID], dayAvailability.AvailableBlocks...)
+		}
+	}
+	return blocks
+}
+
+// availableUsers returns the users (sorted by ID)
+// that have a single block of availability covering start through end
+func availableUsers(blocks map[string][]types.AvailabilityBlock, start time.Time, end time.Time) []types.User {
+	users := []types.User{}
+	for userID, userBlocks := range blocks {
+		if coversRange(userBlocks, start, end) {
+			users = append(users, types.User{ID: userID})
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users
+}
+
+func coversRange(blocks []types.AvailabilityBlock, start time.Time, end time.Time) bool {
+	for _, block := range blocks {
+		if !block.Start.After(start) && !block.End.Before(end) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+func TestAvailabilityHeatmap(t *testing.T) {
+	feb28 := time.Date(2022, time.February, 28, 0, 0, 0, 0, time.UTC)
+	mar1 := feb28.AddDate(0, 0, 1)
+
+	event := types.Event{
+		EarliestDate:    feb28,
+		LatestDate:      mar1,
+		StartTimeHour:   18,
+		StartTimeMinute: 0,
+		EndTimeHour:     20,
+		EndTimeMinute:   0,
+		UserAvailability: map[string]types.UserAvailability{
+			"001": {DayAvailability: []types.DayAvailability{
+				{Date: feb28, AvailableBlocks: []types.AvailabilityBlock{timeBlock(feb28, 18, 0, 19, 0)}},
+			}},
+			"002": {DayAvailability: []types.DayAvailability{
+				{Date: feb28, AvailableBlocks: []types.AvailabilityBlock{timeBlock(feb28, 18, 30, 20, 0)}},
+				{Date: mar1, AvailableBlocks: []types.AvailabilityBlock{timeBlock(mar1, 19, 0, 20, 0)}},
+			}},
+		},
+	}
+
+	slots := availabilityHeatmap(event)
+	// 2 days * 4 slots of 30 minutes
+	if len(slots) != 8 {
+		t.Fatalf("expected 8 slots, got %d", len(slots))
+	}
+
+	expectedCounts := []int{1, 2, 1, 1, 0, 0, 1, 1}
+	for i, slot := range slots {
+		if len(slot.Users) != expectedCounts[i] {
+			t.Errorf("slot %d (%v): expected %d users, got %d", i, slot.Start, expectedCounts[i], len(slot.Users))
+		}
+	}
+
+	if slots[1].Users[0].ID != "001" || slots[1].Users[1].ID != "002" {
+		t.Errorf("expected users to be sorted by ID, got %+v", slots[1].Users)
+	}
+}
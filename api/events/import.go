@@ -0,0 +1,230 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/db"
+	"github.com/3-brain-cells/sah-backend/ical"
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+	"github.com/go-chi/chi"
+)
+
+// Uploaded calendar files can't be larger than this
+const maxCalendarSize = 2 << 20
+
+type importAvailabilityResponseBody struct {
+	// The availability found by removing the busy times from the event's windows
+	Days []types.DayAvailability `json:"days"`
+	// The busy times in the calendar that overlap the event's windows
+	Busy []types.TimeWindow `json:"busy"`
+	// Parts of the calendar that couldn't be understood and were skipped
+	Skipped []string `json:"skipped"`
+	// Whether the availability was stored (only if ?confirm=true)
+	Confirmed bool `json:"confirmed"`
+	// Only included once confirmed
+	Warnings []availabilityWarning `json:"warnings,omitempty"`
+}
+
+// ImportAvailability finds a user's availability from an uploaded iCalendar (.ics) file,
+// which can be sent as the "file" field of a multipart form or as the entire request body.
+// The availability is only returned as a preview unless the confirm query parameter is true,
+// in which case it replaces the user's availability (keeping their location and travel buffer).
+func ImportAvailability(eventProvider db.EventProvider, profileProvider db.ProfileProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the event ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		userID := chi.URLParam(r, "user_id")
+		if userID == "" {
+			util.ErrorWithCode(r, w, errors.New("the user ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+		confirm := r.URL.Query().Get("confirm") == "true"
+
+		event, err := eventProvider.GetSingle(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		if event.Mode == types.EventModePoll {
+			util.ErrorWithCode(r, w, errors.New("the event is in poll mode, so answers should be given for its candidate times instead"),
+				http.StatusBadRequest)
+			return
+		}
+
+		// Times without a time zone are in the user's time zone (if they've saved one)
+		location := time.UTC
+		profile, err := profileProvider.GetProfile(r.Context(), userID)
+		var notFound *db.NotFoundError
+		if err != nil && !errors.As(err, &notFound) {
+			util.Error(r, w, err)
+			return
+		}
+		if profile != nil {
+			if profileLocation, err := time.LoadLocation(profile.TimeZone); err == nil {
+				location = profileLocation
+			}
+		}
+
+		file, err := calendarFile(w, r)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		calendar, err := ical.Parse(file, location)
+		if err != nil {
+			util.Error(r, w, &util.ValidationError{Fields: []types.FieldError{{
+				Field:   "file",
+				Message: fmt.Sprintf("invalid calendar: %v", err),
+			}}})
+			return
+		}
+
+		windows := eventWindows(*event)
+		busy, err := calendarBusyTimes(calendar, windows)
+		if err != nil {
+			util.Error(r, w, &util.ValidationError{Fields: []types.FieldError{{
+				Field:   "file",
+				Message: fmt.Sprintf("invalid calendar: %v", err),
+			}}})
+			return
+		}
+		days, err := normalizeAvailability(*event, availabilityFromBusy(windows, busy))
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		skipped := calendar.Warnings
+		if skipped == nil {
+			skipped = []string{}
+		}
+		responseBody := importAvailabilityResponseBody{
+			Days:    days,
+			Busy:    busy,
+			Skipped: skipped,
+		}
+
+		status := http.StatusOK
+		if confirm {
+			responseBody.Warnings, err = conflictWarnings(r.Context(), eventProvider, *event, userID, days)
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+
+			log.Printf("ImportAvailability event_id=%s user_id=%s", id, userID)
+			err = eventProvider.PutUserAvailabilityAndLocation(r.Context(), userID, types.UserAvailability{
+				DayAvailability:     days,
+				TravelBufferMinutes: event.UserAvailability[userID].TravelBufferMinutes,
+			}, event.UserLocations[userID], id)
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+			responseBody.Confirmed = true
+			status = http.StatusCreated
+		}
+
+		jsonResponse, err := json.Marshal(&responseBody)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(jsonResponse)
+	}
+}
+
+// calendarFile returns the uploaded calendar file from a multipart form,
+// or the entire request body otherwise
+func calendarFile(w http.ResponseWriter, r *http.Request) (io.ReadCloser, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxCalendarSize)
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.Body, nil
+	}
+
+	err := r.ParseMultipartForm(maxCalendarSize)
+	if err != nil {
+		return nil, err
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, fmt.Errorf("the 'file' form field is missing: %w", err)
+	}
+	return file, nil
+}
+
+// calendarBusyTimes returns the busy times in the calendar that overlap any of the windows
+func calendarBusyTimes(calendar *ical.Calendar, windows []types.TimeWindow) ([]types.TimeWindow, error) {
+	busy := []types.TimeWindow{}
+	if len(windows) == 0 {
+		return busy, nil
+	}
+
+	from, to := windowsRange(windows)
+	periods, err := calendar.BusyPeriods(from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, period := range periods {
+		busyTime := types.TimeWindow{Start: period.Start, End: period.End}
+		if overlapsWindows(windows, busyTime) {
+			busy = append(busy, busyTime)
+		}
+	}
+	return busy, nil
+}
+
+// windowsRange returns the earliest start and latest end of the (non-empty) windows
+func windowsRange(windows []types.TimeWindow) (time.Time, time.Time) {
+	from, to := windows[0].Start, windows[0].End
+	for _, window := range windows {
+		from = earliest(from, window.Start)
+		to = latest(to, window.End)
+	}
+	return from, to
+}
+
+func overlapsWindows(windows []types.TimeWindow, busyTime types.TimeWindow) bool {
+	for _, window := range windows {
+		if window.Start.Before(busyTime.End) && window.End.After(busyTime.Start) {
+			return true
+		}
+	}
+	return false
+}
+
+// availabilityFromBusy inverts busy times into availability,
+// which is every part of each window that isn't busy
+func availabilityFromBusy(windows []types.TimeWindow, busy []types.TimeWindow) []types.DayAvailability {
+	days := []types.DayAvailability{}
+	for _, window := range windows {
+		blocks := subtractBusy([]types.AvailabilityBlock{{Start: window.Start, End: window.End}}, busy)
+		if len(blocks) == 0 {
+			continue
+		}
+		days = append(days, types.DayAvailability{
+			Date:            resetToBeginningOfDay(window.Start),
+			AvailableBlocks: blocks,
+		})
+	}
+	return days
+}
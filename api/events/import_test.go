@@ -0,0 +1,71 @@
+package events
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/ical"
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+func TestImportedAvailability(t *testing.T) {
+	mar1 := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+	mar2 := mar1.AddDate(0, 0, 1)
+	mar3 := mar1.AddDate(0, 0, 2)
+	windows := []types.TimeWindow{
+		{Start: mar1.Add(17 * time.Hour), End: mar1.Add(23 * time.Hour)},
+		{Start: mar2.Add(17 * time.Hour), End: mar2.Add(23 * time.Hour)},
+		{Start: mar3.Add(17 * time.Hour), End: mar3.Add(23 * time.Hour)},
+	}
+
+	// Busy from 18:00 - 19:30 every day, all evening on the 2nd,
+	// and in the morning on the 3rd (which doesn't overlap the window)
+	calendar, err := ical.Parse(strings.NewReader("BEGIN:VCALENDAR\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"UID:gym\r\n"+
+		"DTSTART:20220301T180000\r\n"+
+		"DTEND:20220301T193000\r\n"+
+		"RRULE:FREQ=DAILY\r\n"+
+		"END:VEVENT\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"UID:concert\r\n"+
+		"DTSTART:20220302T163000Z\r\n"+
+		"DTEND:20220303T000000Z\r\n"+
+		"END:VEVENT\r\n"+
+		"BEGIN:VFREEBUSY\r\n"+
+		"FREEBUSY:20220303T090000Z/PT2H\r\n"+
+		"END:VFREEBUSY\r\n"+
+		"END:VCALENDAR\r\n"), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	busy, err := calendarBusyTimes(calendar, windows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(busy) != 4 {
+		t.Errorf("expected 4 busy times overlapping the windows, got %+v", busy)
+	}
+
+	expected := [][]types.AvailabilityBlock{
+		{timeBlock(mar1, 17, 0, 18, 0), timeBlock(mar1, 19, 30, 23, 0)},
+		{timeBlock(mar3, 17, 0, 18, 0), timeBlock(mar3, 19, 30, 23, 0)},
+	}
+	days := availabilityFromBusy(windows, busy)
+	if len(days) != len(expected) {
+		t.Fatalf("expected %d days, got %+v", len(expected), days)
+	}
+	for i, day := range days {
+		if len(day.AvailableBlocks) != len(expected[i]) {
+			t.Fatalf("day %d: expected %d blocks, got %+v", i, len(expected[i]), day.AvailableBlocks)
+		}
+		for j, block := range day.AvailableBlocks {
+			if !block.Start.Equal(expected[i][j].Start) || !block.End.Equal(expected[i][j].End) {
+				t.Errorf("day %d block %d: expected %v - %v, got %v - %v", i, j,
+					expected[i][j].Start, expected[i][j].End, block.Start, block.End)
+			}
+		}
+	}
+}
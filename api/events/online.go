@@ -0,0 +1,89 @@
+package events
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+)
+
+// hasVenue returns whether the event takes place at a venue
+// (in which case users give their locations and vote on venues)
+func hasVenue(event types.Event) bool {
+	return event.Type != types.EventTypeVirtual
+}
+
+// eventType returns the event's type
+// (which is in person for events created before there were types)
+func eventType(event types.Event) string {
+	if event.Type == "" {
+		return types.EventTypeInPerson
+	}
+	return event.Type
+}
+
+// validateEventType records any problems with the event's type and where it takes place online
+func validateEventType(validationError *util.ValidationError, eventType string, voiceChannelID string, meetingLink string) {
+	switch eventType {
+	case types.EventTypeInPerson, types.EventTypeHybrid:
+	case types.EventTypeVirtual:
+		if voiceChannelID == "" && meetingLink == "" {
+			validationError.Add("voice_channel_id", "virtual events need a voice channel or a meeting link")
+		}
+	default:
+		validationError.Add("type", "type '%s' must be one of '%s', '%s' or '%s'",
+			eventType, types.EventTypeInPerson, types.EventTypeVirtual, types.EventTypeHybrid)
+	}
+
+	// Discord IDs (snowflakes) are numbers
+	if strings.Trim(voiceChannelID, "0123456789") != "" {
+		validationError.Add("voice_channel_id", "voice channel ID '%s' must be a Discord channel ID", voiceChannelID)
+	}
+	if meetingLink != "" {
+		parsed, err := url.Parse(meetingLink)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			validationError.Add("meeting_link", "meeting link must be an http or https URL")
+		}
+	}
+}
+
+// onlinePlace describes where the event takes place online, such as "<#1234> or <https://meet.example.com/abc>"
+// (or an empty string if it doesn't have a voice channel or meeting link)
+func onlinePlace(event types.Event) string {
+	var places []string
+	if event.VoiceChannelID != "" {
+		// Discord shows the channel mention as a link to the channel
+		places = append(places, fmt.Sprintf("<#%s>", event.VoiceChannelID))
+	}
+	if event.MeetingLink != "" {
+		places = append(places, fmt.Sprintf("<%s>", event.MeetingLink))
+	}
+	return strings.Join(places, " or ")
+}
+
+// finalAnnouncement is the message that announces the final time and place of the event
+// (the time is in the event's local time)
+func finalAnnouncement(event types.Event, start time.Time, end time.Time, venue types.Location) string {
+	var place string
+	switch {
+	case !hasVenue(event):
+		place = "online"
+	case venue.Name != "":
+		place = fmt.Sprintf("at %v (%v)", venue.Name, venue.Address)
+	default:
+		place = "at a venue that wasn't voted on"
+	}
+	online := onlinePlace(event)
+	if hasVenue(event) && online != "" {
+		place += " and online"
+	}
+	if online != "" {
+		place += " in " + online
+	}
+
+	return fmt.Sprintf("Event %v is now over. The event will take place %s on %v from %d:%02d till %d:%02d\n"+
+		"Add it to your calendar: <%s>", event.Title, place, start.Format("01-02-2006"), start.Hour(), start.Minute(), end.Hour(), end.Minute(), fmt.Sprintf(eventCalendarURL, event.EventID))
+}
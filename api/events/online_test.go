@@ -0,0 +1,117 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/api/locations"
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+)
+
+func TestValidateEventType(t *testing.T) {
+	tests := []struct {
+		eventType, voiceChannelID, meetingLink string
+		invalidFields                          []string
+	}{
+		{types.EventTypeInPerson, "", "", nil},
+		{types.EventTypeVirtual, "123456789012345678", "", nil},
+		{types.EventTypeHybrid, "", "https://meet.example.com/abc", nil},
+		{types.EventTypeVirtual, "", "", []string{"voice_channel_id"}},
+		{types.EventTypeVirtual, "general", "javascript:alert(1)", []string{"voice_channel_id", "meeting_link"}},
+		{"telepathic", "", "", []string{"type"}},
+	}
+	for _, test := range tests {
+		validationError := &util.ValidationError{}
+		validateEventType(validationError, test.eventType, test.voiceChannelID, test.meetingLink)
+		var fields []string
+		for _, field := range validationError.Fields {
+			fields = append(fields, field.Field)
+		}
+		if strings.Join(fields, ",") != strings.Join(test.invalidFields, ",") {
+			t.Errorf("%s (%q, %q): invalid fields = %v, want %v",
+				test.eventType, test.voiceChannelID, test.meetingLink, fields, test.invalidFields)
+		}
+	}
+}
+
+func TestFinalAnnouncement(t *testing.T) {
+	start := time.Date(2022, time.March, 4, 20, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	venue := types.Location{Name: "Pizza Place", Address: "123 Main St"}
+
+	tests := []struct {
+		event    types.Event
+		venue    types.Location
+		expected string
+	}{
+		{
+			types.Event{Title: "Dinner"}, venue,
+			"The event will take place at Pizza Place (123 Main St) on 03-04-2022 from 20:00 till 22:00",
+		},
+		{
+			types.Event{Title: "Game night", Type: types.EventTypeVirtual, VoiceChannelID: "1234"}, types.Location{},
+			"The event will take place online in <#1234> on 03-04-2022",
+		},
+		{
+			types.Event{Title: "Watch party", Type: types.EventTypeHybrid, MeetingLink: "https://meet.example.com/abc"}, venue,
+			"The event will take place at Pizza Place (123 Main St) and online in <https://meet.example.com/abc> on",
+		},
+	}
+	for _, test := range tests {
+		announcement := finalAnnouncement(test.event, start, end, test.venue)
+		if !strings.Contains(announcement, test.expected) {
+			t.Errorf("%s: announcement = %q, want it to contain %q", test.event.Title, announcement, test.expected)
+		}
+	}
+}
+
+func TestCalendarLocationForVirtualEvents(t *testing.T) {
+	event := types.Event{GuildID: "1", Type: types.EventTypeVirtual, VoiceChannelID: "2"}
+	if location := calendarLocation(event); location != "https://discord.com/channels/1/2" {
+		t.Errorf("expected a link to the voice channel, got %q", location)
+	}
+	event.MeetingLink = "https://meet.example.com/abc"
+	if location := calendarLocation(event); location != "https://meet.example.com/abc" {
+		t.Errorf("expected the meeting link, got %q", location)
+	}
+}
+
+func TestVenueOptionsWhenSearchFails(t *testing.T) {
+	provider := &locations.FakeProvider{Err: errors.New("over the quota")}
+	places := locations.NewSelector(provider, nil)
+	event := types.Event{
+		EventID: "abcde",
+		UserLocations: map[string]types.UserLocation{
+			"alice": {Latitude: 33.7756, Longitude: -84.3963},
+		},
+		SuggestedVenues: []types.Location{
+			{Name: "Alice's place", Latitude: 33.78, Longitude: -84.39, Provider: locations.ProviderSuggested, PlaceID: "a", SuggestedBy: "alice"},
+		},
+	}
+
+	venues, travelMinutes, midpoint := venueOptions(context.Background(), event, nil, places, &locations.EstimateRouter{})
+	if len(provider.Queries) == 0 {
+		t.Errorf("expected places to be searched for")
+	}
+	// The suggested venue is still voted on
+	if len(venues) != 1 || venues[0].Name != "Alice's place" {
+		t.Errorf("venues = %v, want only the suggested venue", venues)
+	}
+	if len(travelMinutes["alice"]) != 1 {
+		t.Errorf("travel minutes = %v, want alice's time to the suggested venue", travelMinutes)
+	}
+	if midpoint == nil {
+		t.Errorf("expected the midpoint of the users' locations")
+	}
+
+	// Without any locations, there is no midpoint to search around
+	event.UserLocations = nil
+	venues, _, midpoint = venueOptions(context.Background(), event, nil, places, &locations.EstimateRouter{})
+	if len(venues) != 1 || midpoint != nil {
+		t.Errorf("venues = %v and midpoint = %v, want only the suggested venue and no midpoint", venues, midpoint)
+	}
+}
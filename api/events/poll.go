@@ -0,0 +1,246 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/api/locations"
+	"github.com/3-brain-cells/sah-backend/db"
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+	"github.com/go-chi/chi"
+)
+
+type getPollResponseBody struct {
+	Title          string           `json:"title"`
+	Description    string           `json:"description"`
+	CandidateTimes []types.TimePair `json:"candidate_times"`
+	// If null, then answers have not been submitted yet
+	Answers []string `json:"answers"`
+	// Locations aren't collected for virtual events
+	Type string `json:"type"`
+}
+
+// GetPoll returns the candidate times of an event in poll mode,
+// along with the user's answers for each
+func GetPoll(eventProvider db.EventProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the event ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		userID := chi.URLParam(r, "user_id")
+		if userID == "" {
+			util.ErrorWithCode(r, w, errors.New("the user ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("GetPoll event_id=%s", id)
+		event, err := eventProvider.GetSingle(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		if event.Mode != types.EventModePoll {
+			util.ErrorWithCode(r, w, errors.New("the event is not in poll mode"),
+				http.StatusBadRequest)
+			return
+		}
+
+		candidateTimes := make([]types.TimePair, len(event.CandidateTimes))
+		for i, candidate := range event.CandidateTimes {
+			candidateTimes[i] = types.TimePair{Start: candidate.Start, End: candidate.End, Users: []types.User{}}
+		}
+		var myAnswers []string = nil
+		if pollAnswers, ok := event.UserPollAnswers[userID]; ok {
+			myAnswers = pollAnswers.Answers
+		}
+
+		responseBody := getPollResponseBody{
+			Title:          event.Title,
+			Description:    event.Description,
+			CandidateTimes: candidateTimes,
+			Answers:        myAnswers,
+			Type:           eventType(*event),
+		}
+
+		jsonResponse, err := json.Marshal(&responseBody)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+type putPollAnswersRequestBody struct {
+	// One of "yes", "no", or "maybe" for each candidate time
+	Answers  []string           `json:"answers"`
+	Location types.UserLocation `json:"location"`
+	// Optional free-text address or postal code,
+	// which is geocoded if the location's coordinates aren't given
+	Address string `json:"address"`
+}
+
+// PutPollAnswers stores the user's answers to the candidate times of an event in poll mode
+func PutPollAnswers(eventProvider db.EventProvider, geocoder locations.Geocoder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the event ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		userID := chi.URLParam(r, "user_id")
+		if userID == "" {
+			util.ErrorWithCode(r, w, errors.New("the user ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		var body putPollAnswersRequestBody
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusBadRequest)
+			return
+		}
+
+		event, err := eventProvider.GetSingle(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		if eventOver(*event) {
+			util.ErrorWithCode(r, w, errors.New("answers can't be changed after the event is finalized or cancelled"),
+				http.StatusConflict)
+			return
+		}
+		if event.Mode != types.EventModePoll {
+			util.ErrorWithCode(r, w, errors.New("the event is not in poll mode"),
+				http.StatusBadRequest)
+			return
+		}
+
+		err = validatePollAnswers(*event, body.Answers)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Virtual events don't collect locations
+		location := &types.UserLocation{}
+		if hasVenue(*event) {
+			var candidates []locations.GeocodeResult
+			location, candidates, err = resolveLocation(r.Context(), geocoder, body.Location, body.Address)
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+			if location == nil {
+				writeLocationCandidates(r, w, candidates)
+				return
+			}
+		}
+
+		log.Printf("PutPollAnswers event_id=%s user_id=%s", id, userID)
+		err = eventProvider.PutUserPollAnswersAndLocation(r.Context(), userID, types.PollAnswers{
+			Answers: body.Answers,
+		}, *location, id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// validatePollAnswers makes sure there is one valid answer per candidate time
+func validatePollAnswers(event types.Event, answers []string) error {
+	validationError := &util.ValidationError{}
+	if len(answers) != len(event.CandidateTimes) {
+		validationError.Add("answers", "expected %d answers (one per candidate time), got %d",
+			len(event.CandidateTimes), len(answers))
+	}
+	for i, answer := range answers {
+		if answer != types.PollAnswerYes && answer != types.PollAnswerNo && answer != types.PollAnswerMaybe {
+			validationError.Add(fmt.Sprintf("answers[%d]", i), "answer '%s' must be one of 'yes', 'no', or 'maybe'", answer)
+		}
+	}
+	return validationError.OrNil()
+}
+
+// validateCandidateTimes records any problems with the times proposed by the creator
+func validateCandidateTimes(validationError *util.ValidationError, candidateTimes []types.TimePair) {
+	if len(candidateTimes) == 0 {
+		validationError.Add("candidate_times", "at least one candidate time is required in poll mode")
+	}
+	for i, candidate := range candidateTimes {
+		field := fmt.Sprintf("candidate_times[%d]", i)
+		if candidate.Start.IsZero() || candidate.End.IsZero() {
+			validationError.Add(field, "start and end times are required")
+		} else if !candidate.End.After(candidate.Start) {
+			validationError.Add(field, "time ends at %s, which is not after its start at %s",
+				candidate.End.Format(time.RFC3339), candidate.Start.Format(time.RFC3339))
+		}
+	}
+}
+
+// pollTimes converts the candidate times of an event in poll mode to vote options,
+// with the users that answered yes (and maybe) for each
+func pollTimes(event types.Event) []types.TimePair {
+	pairs := make([]types.TimePair, len(event.CandidateTimes))
+	for i, candidate := range event.CandidateTimes {
+		pairs[i] = types.TimePair{
+			Start:      candidate.Start,
+			End:        candidate.End,
+			Users:      []types.User{},
+			MaybeUsers: []types.User{},
+		}
+		for userID, pollAnswers := range event.UserPollAnswers {
+			if i >= len(pollAnswers.Answers) {
+				continue
+			}
+			switch pollAnswers.Answers[i] {
+			case types.PollAnswerYes:
+				pairs[i].Users = append(pairs[i].Users, types.User{ID: userID})
+			case types.PollAnswerMaybe:
+				pairs[i].MaybeUsers = append(pairs[i].MaybeUsers, types.User{ID: userID})
+			}
+		}
+		sort.Slice(pairs[i].Users, func(a, b int) bool { return pairs[i].Users[a].ID < pairs[i].Users[b].ID })
+		sort.Slice(pairs[i].MaybeUsers, func(a, b int) bool { return pairs[i].MaybeUsers[a].ID < pairs[i].MaybeUsers[b].ID })
+	}
+	return pairs
+}
+
+// candidateWindows converts the candidate times to windows
+// and finds the range of dates they fall in
+func candidateWindows(candidateTimes []types.TimePair) ([]types.TimeWindow, time.Time, time.Time) {
+	windows := make([]types.TimeWindow, len(candidateTimes))
+	var earliest, latest time.Time
+	for i, candidate := range candidateTimes {
+		windows[i] = types.TimeWindow{Start: candidate.Start, End: candidate.End}
+		if i == 0 || candidate.Start.Before(earliest) {
+			earliest = candidate.Start
+		}
+		if i == 0 || candidate.Start.After(latest) {
+			latest = candidate.Start
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Start.Before(windows[j].Start) })
+	return windows, resetToBeginningOfDay(earliest), resetToBeginningOfDay(latest)
+}
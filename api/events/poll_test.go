@@ -0,0 +1,42 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+func TestPollTimes(t *testing.T) {
+	start := time.Date(2022, time.March, 4, 19, 0, 0, 0, time.UTC)
+	event := types.Event{
+		Mode: types.EventModePoll,
+		CandidateTimes: []types.TimePair{
+			{Start: start, End: start.Add(2 * time.Hour)},
+			{Start: start.AddDate(0, 0, 1), End: start.AddDate(0, 0, 1).Add(2 * time.Hour)},
+		},
+		UserPollAnswers: map[string]types.PollAnswers{
+			"001": {Answers: []string{types.PollAnswerYes, types.PollAnswerNo}},
+			"002": {Answers: []string{types.PollAnswerMaybe, types.PollAnswerYes}},
+			"003": {Answers: []string{types.PollAnswerYes, types.PollAnswerYes}},
+		},
+	}
+
+	if err := validatePollAnswers(event, []string{types.PollAnswerYes, "sure"}); err == nil {
+		t.Errorf("expected an invalid answer to be rejected")
+	}
+
+	pairs := pollTimes(event)
+	if len(pairs) != 2 {
+		t.Fatalf("expected 2 times, got %+v", pairs)
+	}
+	if len(pairs[0].Users) != 2 || pairs[0].Users[0].ID != "001" || pairs[0].Users[1].ID != "003" {
+		t.Errorf("expected users 001 and 003 for the first time, got %+v", pairs[0].Users)
+	}
+	if len(pairs[0].MaybeUsers) != 1 || pairs[0].MaybeUsers[0].ID != "002" {
+		t.Errorf("expected user 002 to be a maybe for the first time, got %+v", pairs[0].MaybeUsers)
+	}
+	if len(pairs[1].Users) != 2 || len(pairs[1].MaybeUsers) != 0 {
+		t.Errorf("expected 2 users and no maybes for the second time, got %+v", pairs[1])
+	}
+}
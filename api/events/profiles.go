@@ -0,0 +1,88 @@
+package events
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/caldav"
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+const (
+	// Availability was pre-filled from the free/busy times of the user's CalDAV account
+	prefillSourceCalDAV = "caldav"
+	// Availability was pre-filled from the weekly availability in the user's profile
+	prefillSourceProfile = "profile"
+)
+
+// templateDays fills in availability for the event's windows
+// from the weekly availability saved in a user's profile
+// (interpreted in the profile's time zone), clipped to each window
+func templateDays(windows []types.TimeWindow, profile types.Profile) []types.DayAvailability {
+	location, err := time.LoadLocation(profile.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	days := []types.DayAvailability{}
+	for _, window := range windows {
+		blocks := []types.AvailabilityBlock{}
+
+		// Start from the day before the window,
+		// since blocks that span midnight can overlap it
+		start := resetToBeginningOfDay(window.Start.In(location)).AddDate(0, 0, -1)
+		for day := start; day.Before(window.End); day = day.AddDate(0, 0, 1) {
+			for _, weeklyBlock := range profile.WeeklyAvailability {
+				if weeklyBlock.Weekday != day.Weekday() {
+					continue
+				}
+
+				instance := windowOnDate(day, weeklyBlock.DailyWindow)
+				block := types.AvailabilityBlock{
+					Start: latest(instance.Start, window.Start),
+					End:   earliest(instance.End, window.End),
+				}
+				if block.End.After(block.Start) {
+					blocks = append(blocks, block)
+				}
+			}
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+
+		days = append(days, types.DayAvailability{
+			Date:            resetToBeginningOfDay(window.Start),
+			AvailableBlocks: mergeBlocks(blocks),
+		})
+	}
+	return days
+}
+
+// calDAVDays fills in availability for the event's windows
+// by removing the busy times in the user's CalDAV calendar.
+// If httpClient is nil, then the calendar is queried with a client that only connects to public addresses.
+func calDAVDays(ctx context.Context, account types.CalDAVAccount, windows []types.TimeWindow, httpClient *http.Client) ([]types.DayAvailability, error) {
+	if len(windows) == 0 {
+		return []types.DayAvailability{}, nil
+	}
+
+	client := caldav.Client{
+		URL:        account.URL,
+		Username:   account.Username,
+		Password:   account.Password,
+		HTTPClient: httpClient,
+	}
+	from, to := windowsRange(windows)
+	periods, err := client.FreeBusy(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	busy := make([]types.TimeWindow, len(periods))
+	for i, period := range periods {
+		busy[i] = types.TimeWindow{Start: period.Start, End: period.End}
+	}
+	return availabilityFromBusy(windows, busy), nil
+}
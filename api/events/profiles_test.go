@@ -0,0 +1,102 @@
+package events
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+func TestTemplateDays(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Friday through Sunday
+	fri := time.Date(2022, time.March, 4, 0, 0, 0, 0, newYork)
+	sat := fri.AddDate(0, 0, 1)
+	sun := fri.AddDate(0, 0, 2)
+
+	// Windows are stored in UTC
+	windows := []types.TimeWindow{
+		windowOnDate(fri, types.DailyWindow{StartHour: 17, EndHour: 23}),
+		windowOnDate(sat, types.DailyWindow{StartHour: 17, EndHour: 23}),
+		windowOnDate(sun, types.DailyWindow{StartHour: 0, EndHour: 12}),
+	}
+	for i := range windows {
+		windows[i].Start = windows[i].Start.UTC()
+		windows[i].End = windows[i].End.UTC()
+	}
+
+	profile := types.Profile{
+		TimeZone: "America/New_York",
+		WeeklyAvailability: []types.WeeklyBlock{
+			{Weekday: time.Friday, DailyWindow: types.DailyWindow{StartHour: 18, EndHour: 22}},
+			{Weekday: time.Saturday, DailyWindow: types.DailyWindow{StartHour: 22, EndHour: 2}},
+		},
+	}
+
+	expected := [][]types.AvailabilityBlock{
+		{timeBlock(fri, 18, 0, 22, 0)},
+		{timeBlock(sat, 22, 0, 23, 0)},
+		{timeBlock(sun, 0, 0, 2, 0)},
+	}
+
+	days := templateDays(windows, profile)
+	if len(days) != len(expected) {
+		t.Fatalf("expected %d days, got %+v", len(expected), days)
+	}
+	for i, day := range days {
+		if _, ok := windowForDate(windows, day.Date); !ok {
+			t.Errorf("day %d: date %v doesn't match a window", i, day.Date)
+		}
+		if len(day.AvailableBlocks) != len(expected[i]) {
+			t.Fatalf("day %d: expected %d blocks, got %+v", i, len(expected[i]), day.AvailableBlocks)
+		}
+		for j, block := range day.AvailableBlocks {
+			if !block.Start.Equal(expected[i][j].Start) || !block.End.Equal(expected[i][j].End) {
+				t.Errorf("day %d block %d: expected %v - %v, got %v - %v", i, j,
+					expected[i][j].Start, expected[i][j].End, block.Start, block.End)
+			}
+		}
+	}
+}
+
+func TestCalDAVDays(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/calendar")
+		io.WriteString(w, "BEGIN:VCALENDAR\r\n"+
+			"BEGIN:VFREEBUSY\r\n"+
+			"FREEBUSY:20220301T180000Z/20220301T193000Z\r\n"+
+			"FREEBUSY:20220302T160000Z/20220302T235900Z\r\n"+
+			"END:VFREEBUSY\r\n"+
+			"END:VCALENDAR\r\n")
+	}))
+	defer server.Close()
+
+	mar1 := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+	mar2 := mar1.AddDate(0, 0, 1)
+	windows := []types.TimeWindow{
+		{Start: mar1.Add(17 * time.Hour), End: mar1.Add(23 * time.Hour)},
+		{Start: mar2.Add(17 * time.Hour), End: mar2.Add(23 * time.Hour)},
+	}
+
+	days, err := calDAVDays(context.Background(), types.CalDAVAccount{URL: server.URL}, windows, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Busy all evening on the 2nd
+	if len(days) != 1 || len(days[0].AvailableBlocks) != 2 {
+		t.Fatalf("expected 2 blocks on the 1st, got %+v", days)
+	}
+	expected := []types.AvailabilityBlock{timeBlock(mar1, 17, 0, 18, 0), timeBlock(mar1, 19, 30, 23, 0)}
+	for i, block := range days[0].AvailableBlocks {
+		if !block.Start.Equal(expected[i].Start) || !block.End.Equal(expected[i].End) {
+			t.Errorf("block %d: expected %v - %v, got %v - %v", i, expected[i].Start, expected[i].End, block.Start, block.End)
+		}
+	}
+}
@@ -3,11 +3,15 @@ package events
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"math"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/3-brain-cells/sah-backend/api/locations"
+	"github.com/3-brain-cells/sah-backend/api/oauth"
 	"github.com/3-brain-cells/sah-backend/db"
 	"github.com/3-brain-cells/sah-backend/types"
 	"github.com/3-brain-cells/sah-backend/util"
@@ -15,35 +19,54 @@ import (
 	"github.com/go-chi/chi"
 )
 
-func Routes(database db.Provider, discordSession *discordgo.Session) *chi.Mux {
-	router := chi.NewRouter()
+func Routes(database db.Provider, discordSession *discordgo.Session, places *locations.Selector, geocoder locations.Geocoder, router locations.Router, sessions *oauth.Sessions) *chi.Mux {
+	mux := chi.NewRouter()
 
 	// create_event ==> CreatePartialEvent() ==>guildID, userID,generate random ID for event ==> put it in to the database
 	// user with USERID == creator goes to the sah-hangout.com/{eventID} ==> OAUTH with discord ==> user ID matches ==> fill out the form ==>
 	// PUT /{eventID} ==> PopulateEvent() ==> populate the event in the database with all the other stuff
 	// GET /{eventID}/voteoptions ==> GetVoteOptions() ==> get the voting options from the database
 	// POST /{eventID}/votes ==> PostVotes() ==> OAUTH also ==> post the votes to the database
-	// router.Put("/", CreatePartialEvent(database))
-
-	router.Put("/{id}", PopulateEvent(database, discordSession))
-	router.Get("/{id}/vote_options", GetVoteOptions(database))
-	router.Post("/{id}/votes", PostVotes(database))
-	router.Get("/{id}/availability/{user_id}", GetAvailability(database))
-	router.Put("/{id}/availability/{user_id}", PutAvailability(database))
-	// router.Put("/{id}/location/{user_id}", PutLocation(database))
-
-	return router
+	// mux.Put("/", CreatePartialEvent(database))
+
+	mux.Put("/{id}", PopulateEvent(database, discordSession, places, router))
+	mux.With(sessions.RequireSession).Delete("/{id}", CancelEvent(database, discordSession))
+	mux.Get("/{id}/vote_options", GetVoteOptions(database))
+	mux.Post("/{id}/votes", PostVotes(database))
+	mux.Get("/{id}/availability", GetAvailabilityHeatmap(database, discordSession))
+	mux.Get("/{id}/availability/{user_id}", GetAvailability(database, database, sessions))
+	mux.Put("/{id}/availability/{user_id}", PutAvailability(database, geocoder))
+	mux.Post("/{id}/availability/{user_id}/import", ImportAvailability(database, database))
+	mux.Get("/{id}/suggestions", GetSuggestions(database, discordSession))
+	mux.With(sessions.RequireSession).Post("/{id}/venues", PostVenue(database))
+	mux.With(sessions.RequireSession).Delete("/{id}/venues/{venue_id}", DeleteVenue(database))
+	mux.Get("/{id}/event.ics", GetEventCalendar(database, discordSession))
+	mux.Get("/{id}/poll/{user_id}", GetPoll(database))
+	mux.Put("/{id}/poll/{user_id}", PutPollAnswers(database, geocoder))
+	// mux.Put("/{id}/location/{user_id}", PutLocation(database))
+
+	return mux
 }
 
 type GetVoteOptionsResponseBody struct {
 	Times     []GetVoteOptionsTime     `json:"times"`
 	Locations []GetVoteOptionsLocation `json:"locations"`
+	// The point that the locations were found around, and how it was chosen
+	Midpoint         *types.Coordinates `json:"midpoint"`
+	MidpointStrategy string             `json:"midpointStrategy"`
+	TravelMode       string             `json:"travelMode"`
+	// The event's type (virtual events have no locations) and where it takes place online
+	Type           string `json:"type"`
+	VoiceChannelID string `json:"voiceChannelId"`
+	MeetingLink    string `json:"meetingLink"`
 }
 
 type GetVoteOptionsTime struct {
 	Start time.Time    `json:"start"`
 	End   time.Time    `json:"end"`
 	Users []types.User `json:"users"`
+	// Only set for events in poll mode
+	MaybeUsers []types.User `json:"maybeUsers,omitempty"`
 }
 
 type GetVoteOptionsLocation struct {
@@ -52,6 +75,23 @@ type GetVoteOptionsLocation struct {
 	DistanceFromCurrentUser float64 `json:"distanceFromCurrentUser"`
 	PreviewImageURL         string  `json:"previewImageUrl"`
 	Address                 string  `json:"address"`
+	URL                     string  `json:"url"`
+	// The ID of the user that suggested the location (if a user did)
+	SuggestedBy string `json:"suggestedBy,omitempty"`
+	// How long it takes the current user to get to the location
+	// (null if they didn't give a location or there is no route)
+	TravelMinutesFromCurrentUser *int `json:"travelMinutesFromCurrentUser"`
+	// The total travel time of every user with a location, and the longest travel time of any one of them
+	// (which the locations are ranked by)
+	TotalTravelMinutes int `json:"totalTravelMinutes"`
+	WorstTravelMinutes int `json:"worstTravelMinutes"`
+	// Whether the location's opening hours are known,
+	// and if they are, the indexes of the times that it is open for the whole of
+	OpeningHoursKnown bool  `json:"openingHoursKnown"`
+	OpenTimes         []int `json:"openTimes"`
+	// Set if the location is known to be closed for part of every time
+	// (which only venues suggested by users can be)
+	ClosedAtAllTimes bool `json:"closedAtAllTimes"`
 }
 
 // gets the current events voting options
@@ -84,9 +124,10 @@ func GetVoteOptions(eventProvider db.EventProvider) http.HandlerFunc {
 		responseTimes := make([]GetVoteOptionsTime, len(event.VoteOptions.StartEndPairs))
 		for i, time := range event.VoteOptions.StartEndPairs {
 			responseTimes[i] = GetVoteOptionsTime{
-				Start: time.Start,
-				End:   time.End,
-				Users: time.Users,
+				Start:      time.Start,
+				End:        time.End,
+				Users:      time.Users,
+				MaybeUsers: time.MaybeUsers,
 			}
 		}
 		responseLocations := make([]GetVoteOptionsLocation, len(event.VoteOptions.Location))
@@ -98,13 +139,27 @@ func GetVoteOptions(eventProvider db.EventProvider) http.HandlerFunc {
 					coords{location.Latitude, location.Longitude},
 					coords{userLocation.Latitude, userLocation.Longitude},
 				),
-				PreviewImageURL: location.Image,
+				PreviewImageURL: previewImage(location),
 				Address:         location.Address,
+				URL:             location.URL,
+				SuggestedBy:     location.SuggestedBy,
+			}
+			addTravelTimes(&responseLocations[i], event.VoteOptions.TravelMinutes, userID, i)
+			if location.Hours != nil {
+				responseLocations[i].OpeningHoursKnown = true
+				responseLocations[i].OpenTimes = locations.OpenTimes(location, event.VoteOptions.StartEndPairs)
+				responseLocations[i].ClosedAtAllTimes = len(responseLocations[i].OpenTimes) == 0
 			}
 		}
 		responseBody := GetVoteOptionsResponseBody{
-			Times:     responseTimes,
-			Locations: responseLocations,
+			Times:            responseTimes,
+			Locations:        responseLocations,
+			Midpoint:         sharedMidpoint(*event),
+			MidpointStrategy: event.MidpointStrategy,
+			TravelMode:       event.TravelMode,
+			Type:             eventType(*event),
+			VoiceChannelID:   event.VoiceChannelID,
+			MeetingLink:      event.MeetingLink,
 		}
 
 		// Return the single announcement as the top-level JSON
@@ -134,8 +189,10 @@ func latLongDistance(c1 coords, c2 coords) float64 {
 	long1 := math.Pi * c1.longitude / 180
 	long2 := math.Pi * c2.longitude / 180
 
-	// Calculate the great circle distance (in miles)
-	return math.Acos(math.Sin(lat1)*math.Sin(lat2)+math.Cos(lat1)*math.Cos(lat2)*math.Cos(long1-long2)) * 3958.8
+	// Calculate the great circle distance (in miles),
+	// clamping rounding errors for (nearly) identical coordinates
+	cosine := math.Sin(lat1)*math.Sin(lat2) + math.Cos(lat1)*math.Cos(lat2)*math.Cos(long1-long2)
+	return math.Acos(math.Min(1, math.Max(-1, cosine))) * 3958.8
 }
 
 type populateEventRequestBody struct {
@@ -149,6 +206,29 @@ type populateEventRequestBody struct {
 	EndTimeHour        int       `json:"end_time_hour"`
 	EndTimeMinute      int       `json:"end_time_minute"`
 	SwitchToVotingTime time.Time `json:"switch_to_voting"` // ISO 8601 string
+	// Optional windows that replace the daily start and end time
+	WeekdayWindow *types.DailyWindow `json:"weekday_window"`
+	WeekendWindow *types.DailyWindow `json:"weekend_window"`
+	DateWindows   []types.DateWindow `json:"date_windows"`
+	// Optional dates that the event can't take place on
+	ExcludedDates []time.Time `json:"excluded_dates"` // ISO 8601 strings
+	// Either "grid" (the default) or "poll"
+	Mode string `json:"mode"`
+	// The times proposed by the creator in poll mode
+	// (in which case the dates and times above are ignored)
+	CandidateTimes []types.TimePair `json:"candidate_times"`
+	// One of "in_person" (the default), "virtual" or "hybrid"
+	Type string `json:"type"`
+	// Where virtual and hybrid events take place online
+	// (at least one is required for virtual events)
+	VoiceChannelID string `json:"voice_channel_id"`
+	MeetingLink    string `json:"meeting_link"`
+	// One of "centroid" (the default), "geometric_median" or "minimax"
+	MidpointStrategy string `json:"midpoint_strategy"`
+	// One of "walk", "transit" or "drive" (the default)
+	TravelMode string `json:"travel_mode"`
+	// Optional filters for the places that are suggested
+	VenueFilters types.VenueFilters `json:"venue_filters"`
 }
 
 func resetToBeginningOfDay(t time.Time) time.Time {
@@ -157,7 +237,7 @@ func resetToBeginningOfDay(t time.Time) time.Time {
 }
 
 // need to confirm that the user who is populating the event is the same as the user who created the event
-func PopulateEvent(eventProvider db.EventProvider, discordSession *discordgo.Session) http.HandlerFunc {
+func PopulateEvent(eventProvider db.EventProvider, discordSession *discordgo.Session, places *locations.Selector, router locations.Router) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		id := chi.URLParam(r, "id")
@@ -174,6 +254,50 @@ func PopulateEvent(eventProvider db.EventProvider, discordSession *discordgo.Ses
 			return
 		}
 
+		if body.Mode == "" {
+			body.Mode = types.EventModeGrid
+		}
+		validationError := &util.ValidationError{}
+		switch body.Mode {
+		case types.EventModeGrid:
+		case types.EventModePoll:
+			validateCandidateTimes(validationError, body.CandidateTimes)
+		default:
+			validationError.Add("mode", "mode '%s' must be one of '%s' or '%s'", body.Mode, types.EventModeGrid, types.EventModePoll)
+		}
+		if body.Type == "" {
+			body.Type = types.EventTypeInPerson
+		}
+		body.VoiceChannelID = strings.TrimSpace(body.VoiceChannelID)
+		body.MeetingLink = strings.TrimSpace(body.MeetingLink)
+		validateEventType(validationError, body.Type, body.VoiceChannelID, body.MeetingLink)
+		if body.MidpointStrategy == "" {
+			body.MidpointStrategy = locations.MidpointCentroid
+		}
+		if err := locations.ValidMidpointStrategy(body.MidpointStrategy); err != nil {
+			validationError.Add("midpoint_strategy", "%s", err)
+		}
+		if body.TravelMode == "" {
+			body.TravelMode = locations.ModeDrive
+		}
+		if err := locations.ValidTravelMode(body.TravelMode); err != nil {
+			validationError.Add("travel_mode", "%s", err)
+		}
+		validateVenueFilters(validationError, body.VenueFilters)
+		if err := validationError.OrNil(); err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// In poll mode, the dates and windows come from the candidate times
+		var candidateTimeWindows []types.TimeWindow
+		if body.Mode == types.EventModePoll {
+			candidateTimeWindows, body.EarliestDate, body.LatestDate = candidateWindows(body.CandidateTimes)
+			for i := range body.CandidateTimes {
+				body.CandidateTimes[i].Users = []types.User{}
+			}
+		}
+
 		// add the field that switches from filling out schedule to voting
 		body.SwitchToVotingTime = time.Now().Add(time.Until(body.EarliestDate) / 2)
 
@@ -196,6 +320,45 @@ func PopulateEvent(eventProvider db.EventProvider, discordSession *discordgo.Ses
 			EndTimeHour:        body.EndTimeHour,
 			EndTimeMinute:      body.EndTimeMinute,
 			SwitchToVotingTime: body.SwitchToVotingTime,
+			WeekdayWindow:      body.WeekdayWindow,
+			WeekendWindow:      body.WeekendWindow,
+			DateWindows:        body.DateWindows,
+			ExcludedDates:      body.ExcludedDates,
+			Mode:               body.Mode,
+			CandidateTimes:     body.CandidateTimes,
+			Type:               body.Type,
+			VoiceChannelID:     body.VoiceChannelID,
+			MeetingLink:        body.MeetingLink,
+			MidpointStrategy:   body.MidpointStrategy,
+			TravelMode:         body.TravelMode,
+			VenueFilters:       body.VenueFilters,
+		}
+		for i := range partialEvent.DateWindows {
+			partialEvent.DateWindows[i].Date = resetToBeginningOfDay(partialEvent.DateWindows[i].Date)
+		}
+		for i := range partialEvent.ExcludedDates {
+			partialEvent.ExcludedDates[i] = resetToBeginningOfDay(partialEvent.ExcludedDates[i])
+		}
+
+		if partialEvent.Mode == types.EventModeGrid {
+			validateDefaultWindow(validationError, partialEvent)
+		}
+		validateWindowRules(validationError, partialEvent)
+		if err := validationError.OrNil(); err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Resolve the daily times to absolute windows
+		// (in the time zone that the dates were given in)
+		partialEvent.Windows = resolveWindows(partialEvent)
+		if partialEvent.Mode == types.EventModePoll {
+			partialEvent.Windows = candidateTimeWindows
+		}
+		if len(partialEvent.Windows) == 0 {
+			util.ErrorWithCode(r, w, errors.New("the event has no dates that aren't excluded"),
+				http.StatusBadRequest)
+			return
 		}
 
 		log.Printf("PopulateEvent event_id=%s user_id=%s", id, body.UserID)
@@ -206,7 +369,7 @@ func PopulateEvent(eventProvider db.EventProvider, discordSession *discordgo.Ses
 		}
 
 		// create a thread that manages the event
-		go ManageEvent(eventProvider, discordSession, partialEvent.EventID)
+		go ManageEvent(eventProvider, discordSession, places, router, partialEvent.EventID)
 
 		w.WriteHeader(http.StatusCreated)
 	}
@@ -257,11 +420,32 @@ type getAvailabilityResponseBody struct {
 	StartTimeMinute int       `json:"start_time_minute"`
 	EndTimeHour     int       `json:"end_time_hour"`
 	EndTimeMinute   int       `json:"end_time_minute"`
+	// The windows (at most one per date) that availability can be given in,
+	// which can span midnight and vary by date
+	Windows []types.TimeWindow `json:"windows"`
+	// Dates in the range that the event can't take place on
+	ExcludedDates []time.Time `json:"excluded_dates"`
 	// If null, then availability has not been submitted yet
+	// and the user has no weekly availability saved in their profile
 	Days []types.DayAvailability `json:"days"`
+	// Whether Days was filled in from the user's CalDAV account or profile
+	// (and hasn't been submitted for this event yet)
+	Prefilled bool `json:"prefilled"`
+	// Where Days was filled in from (prefillSourceCalDAV or prefillSourceProfile)
+	PrefillSource string `json:"prefill_source,omitempty"`
+	// If null, then the user hasn't given a location for this event
+	// and has no home location saved in their profile
+	Location *types.UserLocation `json:"location"`
+	// If null, then the travel buffer is estimated
+	TravelBufferMinutes *int `json:"travel_buffer_minutes"`
+	// Locations aren't collected for virtual events
+	Type string `json:"type"`
 }
 
-func GetAvailability(eventProvider db.EventProvider) http.HandlerFunc {
+// GetAvailability returns the windows of an event and a user's availability within them.
+// If the user hasn't submitted availability yet, it is pre-filled from their CalDAV account
+// or the weekly availability in their profile.
+func GetAvailability(eventProvider db.EventProvider, profileProvider db.ProfileProvider, sessions *oauth.Sessions) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
 		if id == "" {
@@ -289,20 +473,75 @@ func GetAvailability(eventProvider db.EventProvider) http.HandlerFunc {
 			return
 		}
 		var myAvailabilityDays []types.DayAvailability = nil
-		if userAvailability, ok := event.UserAvailability[userID]; ok {
+		var myTravelBufferMinutes *int = nil
+		var myLocation *types.UserLocation = nil
+		userAvailability, submitted := event.UserAvailability[userID]
+		if submitted {
 			if len(userAvailability.DayAvailability) > 0 {
 				myAvailabilityDays = userAvailability.DayAvailability
 			}
+			myTravelBufferMinutes = userAvailability.TravelBufferMinutes
+		}
+		if userLocation, ok := event.UserLocations[userID]; ok {
+			myLocation = &userLocation
+		}
+
+		windows := eventWindows(*event)
+		prefillSource := ""
+		// The user's CalDAV account and profile are only used when the user themselves is asking,
+		// since the profile has their home location and CalDAV queries send their credentials out
+		if !submitted && sessions.IsUser(r, userID) {
+			var notFound *db.NotFoundError
+			account, err := profileProvider.GetCalDAVAccount(r.Context(), userID)
+			if err != nil && !errors.As(err, &notFound) {
+				util.Error(r, w, err)
+				return
+			}
+			if account != nil {
+				days, err := calDAVDays(r.Context(), *account, windows, nil)
+				if err != nil {
+					// Fall back to the weekly availability in the user's profile
+					log.Printf("Error pre-filling availability from CalDAV (user_id=%s): %v", userID, err)
+				} else {
+					myAvailabilityDays = days
+					prefillSource = prefillSourceCalDAV
+				}
+			}
+
+			profile, err := profileProvider.GetProfile(r.Context(), userID)
+			if err != nil && !errors.As(err, &notFound) {
+				util.Error(r, w, err)
+				return
+			}
+			if profile != nil {
+				if prefillSource == "" {
+					days := templateDays(windows, *profile)
+					if len(days) > 0 {
+						myAvailabilityDays = days
+						prefillSource = prefillSourceProfile
+					}
+				}
+				if myLocation == nil {
+					myLocation = profile.HomeLocation
+				}
+			}
 		}
 
 		responseBody := getAvailabilityResponseBody{
-			EarliestDate:    event.EarliestDate,
-			LatestDate:      event.LatestDate,
-			StartTimeHour:   event.StartTimeHour,
-			StartTimeMinute: event.StartTimeMinute,
-			EndTimeHour:     event.EndTimeHour,
-			EndTimeMinute:   event.EndTimeMinute,
-			Days:            myAvailabilityDays,
+			EarliestDate:        event.EarliestDate,
+			LatestDate:          event.LatestDate,
+			StartTimeHour:       event.StartTimeHour,
+			StartTimeMinute:     event.StartTimeMinute,
+			EndTimeHour:         event.EndTimeHour,
+			EndTimeMinute:       event.EndTimeMinute,
+			Windows:             windows,
+			ExcludedDates:       event.ExcludedDates,
+			Days:                myAvailabilityDays,
+			Prefilled:           prefillSource != "",
+			PrefillSource:       prefillSource,
+			Location:            myLocation,
+			TravelBufferMinutes: myTravelBufferMinutes,
+			Type:                eventType(*event),
 		}
 
 		// Return the single announcement as the top-level JSON
@@ -357,9 +596,21 @@ func GetAvailability(eventProvider db.EventProvider) http.HandlerFunc {
 type putAvailabilityRequestBody struct {
 	Days     []types.DayAvailability `json:"days"`
 	Location types.UserLocation      `json:"location"`
+	// Optional free-text address or postal code,
+	// which is geocoded if the location's coordinates aren't given
+	Address string `json:"address"`
+	// Optional; estimated from the distance to the other users if null
+	TravelBufferMinutes *int `json:"travel_buffer_minutes"`
+}
+
+type putAvailabilityResponseBody struct {
+	// Other finalized events the user is attending that overlap their availability
+	Warnings []availabilityWarning `json:"warnings"`
+	// The location that was stored (geocoded from the address if one was given)
+	Location types.UserLocation `json:"location"`
 }
 
-func PutAvailability(eventProvider db.EventProvider) http.HandlerFunc {
+func PutAvailability(eventProvider db.EventProvider, geocoder locations.Geocoder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
 		if id == "" {
@@ -382,125 +633,185 @@ func PutAvailability(eventProvider db.EventProvider) http.HandlerFunc {
 			return
 		}
 
+		event, err := eventProvider.GetSingle(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		if eventOver(*event) {
+			util.ErrorWithCode(r, w, errors.New("availability can't be changed after the event is finalized or cancelled"),
+				http.StatusConflict)
+			return
+		}
+		if event.Mode == types.EventModePoll {
+			util.ErrorWithCode(r, w, errors.New("the event is in poll mode, so answers should be given for its candidate times instead"),
+				http.StatusBadRequest)
+			return
+		}
+
+		days, err := normalizeAvailability(*event, body.Days)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		if body.TravelBufferMinutes != nil && (*body.TravelBufferMinutes < 0 || time.Duration(*body.TravelBufferMinutes)*time.Minute > maxTravelBuffer) {
+			util.Error(r, w, &util.ValidationError{Fields: []types.FieldError{{
+				Field:   "travel_buffer_minutes",
+				Message: fmt.Sprintf("travel buffer must be between 0 and %d minutes", int(maxTravelBuffer.Minutes())),
+			}}})
+			return
+		}
+
+		// Virtual events don't collect locations
+		location := &types.UserLocation{}
+		if hasVenue(*event) {
+			var candidates []locations.GeocodeResult
+			location, candidates, err = resolveLocation(r.Context(), geocoder, body.Location, body.Address)
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+			if location == nil {
+				writeLocationCandidates(r, w, candidates)
+				return
+			}
+		}
+
+		// Warn about (but still store) availability that overlaps other events the user is attending
+		warnings, err := conflictWarnings(r.Context(), eventProvider, *event, userID, days)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
 		log.Printf("PutAvailability event_id=%s user_id=%s", id, userID)
 		err = eventProvider.PutUserAvailabilityAndLocation(r.Context(), userID, types.UserAvailability{
-			DayAvailability: body.Days,
-		}, body.Location, id)
+			DayAvailability:     days,
+			TravelBufferMinutes: body.TravelBufferMinutes,
+		}, *location, id)
 		if err != nil {
 			util.Error(r, w, err)
 			return
 		}
 
+		responseBody := putAvailabilityResponseBody{
+			Warnings: warnings,
+			Location: *location,
+		}
+
+		jsonResponse, err := json.Marshal(&responseBody)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
+		w.Write(jsonResponse)
 	}
 }
 
-func FindAvailability(event types.Event) []types.TimePair {
-	// 1. divide time slots into buckets of 30 min in the day
-	// 2. look for the longest time slots available / most popular
-	// 3. first we need to create the buckets and then fill it in
-	//    with number of users available
-
-	// 24 * 2 buckets = 48 buckets * numDays
-	tempEarliest := time.Date(event.EarliestDate.Year(), event.EarliestDate.Month(), event.EarliestDate.Day(), 0, 0, 0, 0, event.EarliestDate.Location())
-	tempLatest := time.Date(event.EarliestDate.Year(), event.LatestDate.Month(), event.LatestDate.Day(), 0, 0, 0, 0, event.LatestDate.Location())
-	duration := tempLatest.Sub(tempEarliest)
-	var numDays float64 = 1 + duration.Hours()/24
-	var buckets = make([]int, int(48*numDays)+1)
-	max := 0
+const (
+	// Proposed times need to be at least 1 hr long
+	minSlotsPerPair = 2
+	// and at most 3 hrs long
+	maxSlotsPerPair = 6
+)
 
-	// populate the buckets
-	for _, userAvailability := range event.UserAvailability {
-		for _, dayAvailability := range userAvailability.DayAvailability {
-			// bucket indexing [(dayNum * 48) + (hour * 2)]
-			// make temp time, copies event.Earliest Date and makes hour = 0
-			offset := dayAvailability.Date.Sub(tempEarliest).Hours() * 2
-
-			for _, block := range dayAvailability.AvailableBlocks {
-				startBucket := (block.StartMinute % 30) + (block.StartHour * 2) + int(offset)
-				endBucket := int(math.Ceil(float64(block.EndMinute)/30)) + (block.EndHour * 2) + int(offset)
-				for i := startBucket; i <= endBucket; i++ {
-					buckets[i] += 1
-					if buckets[i] > max {
-						max = buckets[i]
-					}
-				}
+// FindAvailability finds the most popular times in the event's windows
+// that are between 1 and 3 hrs long
+// (after removing the times users are busy and accounting for each user's travel buffer).
+// It starts by only considering slots where the most users are available,
+// and lowers that threshold until times are found in at least 3 windows.
+func FindAvailability(event types.Event, busy map[string][]types.TimeWindow) []types.TimePair {
+	// 1. divide the windows into slots of 30 min
+	// 2. fill in the slots with the users available
+	// 3. look for the longest runs of slots available / most popular
+	blocks := usableBlocks(event, busy)
+	slotsPerWindow, max := slotsWithUsers(event, blocks)
+	pairs, _ := selectPopularTimes(slotsPerWindow, max)
+
+	// Only include users that are available for the entire time
+	for i := range pairs {
+		pairs[i].Users = availableUsers(blocks, pairs[i].Start, pairs[i].End)
+	}
+
+	return pairs
+}
+
+// slotsWithUsers divides each of the event's windows into slots with the users available,
+// also returning the most users available for any slot
+func slotsWithUsers(event types.Event, blocks map[string][]types.AvailabilityBlock) ([][]types.TimePair, int) {
+	windows := eventWindows(event)
+	slotsPerWindow := make([][]types.TimePair, len(windows))
+	max := 0
+	for i, window := range windows {
+		slotsPerWindow[i] = windowSlots(window)
+		for j := range slotsPerWindow[i] {
+			slot := &slotsPerWindow[i][j]
+			slot.Users = availableUsers(blocks, slot.Start, slot.End)
+			if len(slot.Users) > max {
+				max = len(slot.Users)
 			}
 		}
 	}
+	return slotsPerWindow, max
+}
 
-	startBucket := -1
-	var endBucket int
-	var ret []types.DayAvailability
-	//check which bucket ranges are most popular --> should be == max
-	for len(ret) < 3 && max > 0 {
-		ret = []types.DayAvailability{}
-		for j := 0; j < int(numDays); j++ {
-			startBucket = -1
-			var dayBlock []types.AvailabilityBlock
-			for i := 0; i < 48; i++ {
-				if startBucket == -1 && buckets[j*48+i] >= max {
-					startBucket = i
-				} else if startBucket != -1 && buckets[j*48+i] < max && i-startBucket < 2 {
-					// needs to be at least 1 hr long
-					continue
-				} else if startBucket != -1 && (buckets[j*48+i] < max || i-startBucket == 12) {
-					// max of 3 hrs
-					endBucket = i
-					var d types.AvailabilityBlock
-					d.StartHour = int(startBucket / 2)
-					d.EndHour = int(endBucket / 2)
-					d.StartMinute = 30 * (startBucket % 2)
-					d.EndMinute = 30 * (endBucket % 2)
-					dayBlock = append(dayBlock, d)
-					startBucket = -1
-				}
-			}
-			var day types.DayAvailability
-			day.Date = event.EarliestDate.Add(time.Hour * time.Duration(24*j))
+// selectPopularTimes starts with a threshold of max users
+// and lowers it until times are found in at least 3 windows,
+// returning the times and the threshold that they were found with
+func selectPopularTimes(slotsPerWindow [][]types.TimePair, max int) ([]types.TimePair, int) {
+	var pairs []types.TimePair
+	threshold := max
+	for ; threshold > 0; threshold-- {
+		var windowsWithPairs int
+		pairs, windowsWithPairs = popularTimes(slotsPerWindow, threshold)
+		if windowsWithPairs >= 3 || threshold == 1 {
+			break
+		}
+	}
+	return pairs, threshold
+}
 
-			// check if the dayBlock is empty
-			if len(dayBlock) > 0 {
-				day.AvailableBlocks = dayBlock
-				ret = append(ret, day)
+// popularTimes finds runs of consecutive slots where at least threshold users
+// are available, splitting runs that are too long and dropping ones that are too short.
+// It also returns how many windows the times were found in.
+func popularTimes(slotsPerWindow [][]types.TimePair, threshold int) ([]types.TimePair, int) {
+	var pairs []types.TimePair
+	windowsWithPairs := 0
+	for _, slots := range slotsPerWindow {
+		found := false
+		runStart := -1
+		endRun := func(end int) {
+			if runStart != -1 && end-runStart >= minSlotsPerPair {
+				pairs = append(pairs, types.TimePair{
+					Start: slots[runStart].Start,
+					End:   slots[end-1].End,
+				})
+				found = true
 			}
+			runStart = -1
 		}
-		max = max - 1
-	}
 
-	// convert ret to list of time pairs
-	var ret2 []types.TimePair
-	for _, day := range ret {
-		for _, block := range day.AvailableBlocks {
-			var pair types.TimePair
-			pair.Start = time.Date(day.Date.Year(), day.Date.Month(), day.Date.Day(), block.StartHour, block.StartMinute, 0, 0, day.Date.Location())
-			pair.End = time.Date(day.Date.Year(), day.Date.Month(), day.Date.Day(), block.EndHour, block.EndMinute, 0, 0, day.Date.Location())
-			userSet := make(map[types.User]struct{})
-			for key, userAvailability := range event.UserAvailability {
-				for _, dayAvailability := range userAvailability.DayAvailability {
-					if dayAvailability.Date.Equal(day.Date) {
-						for _, block := range dayAvailability.AvailableBlocks {
-							if block.StartHour*60+block.StartMinute >= pair.Start.Hour()*60+pair.Start.Minute() &&
-								block.EndHour*60+block.EndMinute <= pair.End.Hour()*60+pair.End.Minute() {
-								var woohoo types.User
-								woohoo.ID = key
-								if _, ok := userSet[woohoo]; !ok {
-									// new user
-									log.Printf("adding userID=%s", key)
-									userSet[woohoo] = struct{}{}
-								}
-							}
-						}
-					}
-				}
+		for i, slot := range slots {
+			if len(slot.Users) < threshold {
+				endRun(i)
+				continue
 			}
-			pair.Users = []types.User{}
-			for user := range userSet {
-				pair.Users = append(pair.Users, user)
+			if runStart != -1 && i-runStart == maxSlotsPerPair {
+				endRun(i)
 			}
-			ret2 = append(ret2, pair)
+			if runStart == -1 {
+				runStart = i
+			}
+		}
+		endRun(len(slots))
+
+		if found {
+			windowsWithPairs++
 		}
 	}
 
-	return ret2
+	return pairs, windowsWithPairs
 }
@@ -1,11 +1,19 @@
 package events
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/3-brain-cells/sah-backend/api/oauth"
+	"github.com/3-brain-cells/sah-backend/db"
 	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/go-chi/chi"
 )
 
 func TestAvailability(t *testing.T) {
@@ -28,18 +36,8 @@ func TestAvailability(t *testing.T) {
 	var userAvailability types.UserAvailability
 	// USER 1
 	// day 1
-	block1 := types.AvailabilityBlock{
-		StartHour:   13,
-		StartMinute: 0,
-		EndHour:     14,
-		EndMinute:   30,
-	}
-	block2 := types.AvailabilityBlock{
-		StartHour:   18,
-		StartMinute: 0,
-		EndHour:     19,
-		EndMinute:   0,
-	}
+	block1 := timeBlock(feb28, 13, 0, 14, 30)
+	block2 := timeBlock(feb28, 18, 0, 19, 0)
 	var availableBlocks []types.AvailabilityBlock
 	availableBlocks = append(availableBlocks, block1)
 	availableBlocks = append(availableBlocks, block2)
@@ -50,18 +48,8 @@ func TestAvailability(t *testing.T) {
 	dayAvailabilities = append(dayAvailabilities, dayAvailability)
 
 	// day 2
-	block1 = types.AvailabilityBlock{
-		StartHour:   10,
-		StartMinute: 0,
-		EndHour:     14,
-		EndMinute:   00,
-	}
-	block2 = types.AvailabilityBlock{
-		StartHour:   22,
-		StartMinute: 0,
-		EndHour:     23,
-		EndMinute:   0,
-	}
+	block1 = timeBlock(mar1, 10, 0, 14, 0)
+	block2 = timeBlock(mar1, 22, 0, 23, 0)
 	availableBlocks = []types.AvailabilityBlock{}
 	availableBlocks = append(availableBlocks, block1)
 	availableBlocks = append(availableBlocks, block2)
@@ -70,18 +58,8 @@ func TestAvailability(t *testing.T) {
 	dayAvailabilities = append(dayAvailabilities, dayAvailability)
 
 	// day 3
-	block1 = types.AvailabilityBlock{
-		StartHour:   8,
-		StartMinute: 0,
-		EndHour:     9,
-		EndMinute:   9,
-	}
-	block2 = types.AvailabilityBlock{
-		StartHour:   19,
-		StartMinute: 0,
-		EndHour:     23,
-		EndMinute:   46,
-	}
+	block1 = timeBlock(mar3, 8, 0, 9, 9)
+	block2 = timeBlock(mar3, 19, 0, 23, 46)
 	availableBlocks = []types.AvailabilityBlock{}
 	availableBlocks = append(availableBlocks, block1)
 	availableBlocks = append(availableBlocks, block2)
@@ -93,18 +71,8 @@ func TestAvailability(t *testing.T) {
 
 	// USER 2
 	// day 1
-	block1 = types.AvailabilityBlock{
-		StartHour:   12,
-		StartMinute: 00,
-		EndHour:     16,
-		EndMinute:   37,
-	}
-	block2 = types.AvailabilityBlock{
-		StartHour:   18,
-		StartMinute: 0,
-		EndHour:     19,
-		EndMinute:   0,
-	}
+	block1 = timeBlock(feb28, 12, 0, 16, 37)
+	block2 = timeBlock(feb28, 18, 0, 19, 0)
 	availableBlocks = []types.AvailabilityBlock{}
 	availableBlocks = append(availableBlocks, block1)
 	availableBlocks = append(availableBlocks, block2)
@@ -114,12 +82,7 @@ func TestAvailability(t *testing.T) {
 	dayAvailabilities = append(dayAvailabilities, dayAvailability)
 
 	// day 2
-	block1 = types.AvailabilityBlock{
-		StartHour:   10,
-		StartMinute: 9,
-		EndHour:     12,
-		EndMinute:   00,
-	}
+	block1 = timeBlock(mar2, 10, 9, 12, 0)
 	availableBlocks = []types.AvailabilityBlock{}
 	availableBlocks = append(availableBlocks, block1)
 	dayAvailability.Date = mar2
@@ -127,12 +90,7 @@ func TestAvailability(t *testing.T) {
 	dayAvailabilities = append(dayAvailabilities, dayAvailability)
 
 	// day 3
-	block1 = types.AvailabilityBlock{
-		StartHour:   10,
-		StartMinute: 16,
-		EndHour:     21,
-		EndMinute:   9,
-	}
+	block1 = timeBlock(mar3, 10, 16, 21, 9)
 	availableBlocks = []types.AvailabilityBlock{}
 	availableBlocks = append(availableBlocks, block1)
 	dayAvailability.Date = mar3
@@ -143,24 +101,9 @@ func TestAvailability(t *testing.T) {
 
 	// USER 3
 	// day 1
-	block1 = types.AvailabilityBlock{
-		StartHour:   13,
-		StartMinute: 0,
-		EndHour:     14,
-		EndMinute:   00,
-	}
-	block2 = types.AvailabilityBlock{
-		StartHour:   14,
-		StartMinute: 0,
-		EndHour:     15,
-		EndMinute:   0,
-	}
-	block3 := types.AvailabilityBlock{
-		StartHour:   15,
-		StartMinute: 0,
-		EndHour:     16,
-		EndMinute:   0,
-	}
+	block1 = timeBlock(feb28, 13, 0, 14, 0)
+	block2 = timeBlock(feb28, 14, 0, 15, 0)
+	block3 := timeBlock(feb28, 15, 0, 16, 0)
 	availableBlocks = []types.AvailabilityBlock{}
 	availableBlocks = append(availableBlocks, block1)
 	availableBlocks = append(availableBlocks, block2)
@@ -171,12 +114,7 @@ func TestAvailability(t *testing.T) {
 	dayAvailabilities = append(dayAvailabilities, dayAvailability)
 
 	// day 2
-	block1 = types.AvailabilityBlock{
-		StartHour:   10,
-		StartMinute: 0,
-		EndHour:     22,
-		EndMinute:   00,
-	}
+	block1 = timeBlock(mar2, 10, 0, 22, 0)
 	availableBlocks = []types.AvailabilityBlock{}
 	availableBlocks = append(availableBlocks, block1)
 	dayAvailability.Date = mar2
@@ -184,12 +122,7 @@ func TestAvailability(t *testing.T) {
 	dayAvailabilities = append(dayAvailabilities, dayAvailability)
 
 	// day 3
-	block1 = types.AvailabilityBlock{
-		StartHour:   20,
-		StartMinute: 0,
-		EndHour:     21,
-		EndMinute:   26,
-	}
+	block1 = timeBlock(mar3, 20, 0, 21, 26)
 	availableBlocks = []types.AvailabilityBlock{}
 	availableBlocks = append(availableBlocks, block1)
 	dayAvailability.Date = mar3
@@ -200,18 +133,8 @@ func TestAvailability(t *testing.T) {
 
 	// USER 4
 	// day 1
-	block1 = types.AvailabilityBlock{
-		StartHour:   13,
-		StartMinute: 0,
-		EndHour:     14,
-		EndMinute:   30,
-	}
-	block2 = types.AvailabilityBlock{
-		StartHour:   18,
-		StartMinute: 0,
-		EndHour:     19,
-		EndMinute:   0,
-	}
+	block1 = timeBlock(mar1, 13, 0, 14, 30)
+	block2 = timeBlock(mar1, 18, 0, 19, 0)
 	availableBlocks = []types.AvailabilityBlock{}
 	availableBlocks = append(availableBlocks, block1)
 	availableBlocks = append(availableBlocks, block2)
@@ -221,12 +144,8 @@ func TestAvailability(t *testing.T) {
 	dayAvailabilities = append(dayAvailabilities, dayAvailability)
 
 	// day 2
-	block1 = types.AvailabilityBlock{
-		StartHour:   10,
-		StartMinute: 0,
-		EndHour:     14,
-		EndMinute:   00,
-	}
+	block1 = timeBlock(mar2, 10, 0, 14, 0)
+	block2 = timeBlock(mar2, 18, 0, 19, 0)
 	availableBlocks = []types.AvailabilityBlock{}
 	availableBlocks = append(availableBlocks, block1)
 	availableBlocks = append(availableBlocks, block2)
@@ -241,6 +160,149 @@ func TestAvailability(t *testing.T) {
 
 	fmt.Printf("event: %+v\n", event)
 
-	ret := FindAvailability(event)
+	ret := FindAvailability(event, nil)
 	fmt.Printf("returned: %+v\n", ret)
 }
+
+// timeBlock creates a block of availability on the given date
+func timeBlock(date time.Time, startHour int, startMinute int, endHour int, endMinute int) types.AvailabilityBlock {
+	return types.AvailabilityBlock{
+		Start: date.Add(time.Duration(startHour)*time.Hour + time.Duration(startMinute)*time.Minute),
+		End:   date.Add(time.Duration(endHour)*time.Hour + time.Duration(endMinute)*time.Minute),
+	}
+}
+
+func TestLateNightAvailability(t *testing.T) {
+	feb28 := time.Date(2022, time.February, 28, 0, 0, 0, 0, time.UTC)
+	event := types.Event{
+		EarliestDate:  feb28,
+		LatestDate:    feb28,
+		StartTimeHour: 21,
+		EndTimeHour:   1,
+	}
+	lateNight := types.DayAvailability{
+		Date:            feb28,
+		AvailableBlocks: []types.AvailabilityBlock{timeBlock(feb28, 22, 0, 25, 0)},
+	}
+	event.UserAvailability = map[string]types.UserAvailability{
+		"001": {DayAvailability: []types.DayAvailability{lateNight}},
+		"002": {DayAvailability: []types.DayAvailability{lateNight}},
+	}
+
+	ret := FindAvailability(event, nil)
+	if len(ret) != 1 {
+		t.Fatalf("expected a single time, got %+v", ret)
+	}
+	if !ret[0].Start.Equal(feb28.Add(22*time.Hour)) || !ret[0].End.Equal(feb28.Add(25*time.Hour)) {
+		t.Errorf("expected the time to span midnight, got %v - %v", ret[0].Start, ret[0].End)
+	}
+	if len(ret[0].Users) != 2 {
+		t.Errorf("expected both users to be available, got %+v", ret[0].Users)
+	}
+}
+
+// fakeEventProvider returns the same event for every ID
+// (other methods panic)
+type fakeEventProvider struct {
+	db.EventProvider
+	event *types.Event
+}
+
+func (p *fakeEventProvider) GetSingle(ctx context.Context, eventID string) (*types.Event, error) {
+	return p.event, nil
+}
+
+// fakeProfileProvider returns the same profile and CalDAV account for every user
+// (other methods panic)
+type fakeProfileProvider struct {
+	db.ProfileProvider
+	profile *types.Profile
+	account *types.CalDAVAccount
+	// How many times the CalDAV account was looked up
+	calDAVLookups int
+}
+
+func (p *fakeProfileProvider) GetCalDAVAccount(ctx context.Context, userID string) (*types.CalDAVAccount, error) {
+	p.calDAVLookups++
+	return p.account, nil
+}
+
+func (p *fakeProfileProvider) GetProfile(ctx context.Context, userID string) (*types.Profile, error) {
+	return p.profile, nil
+}
+
+var testSessions = oauth.NewSessions([]byte("0123456789abcdef0123456789abcdef"), time.Hour)
+
+// serve sends the request to the handler mounted at the route,
+// with a session for the user if sessionUserID isn't empty
+func serve(method string, route string, handler http.HandlerFunc, path string, sessionUserID string) *httptest.ResponseRecorder {
+	router := chi.NewRouter()
+	router.Method(method, route, handler)
+	r := httptest.NewRequest(method, path, nil)
+	if sessionUserID != "" {
+		r.Header.Set("Authorization", "Bearer "+testSessions.Issue(sessionUserID))
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, r)
+	return recorder
+}
+
+func TestGetAvailabilityPrefillsOnlyForSessionUser(t *testing.T) {
+	mar1 := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+	events := &fakeEventProvider{event: &types.Event{
+		EarliestDate:  mar1,
+		LatestDate:    mar1,
+		StartTimeHour: 9,
+		EndTimeHour:   17,
+	}}
+	profiles := &fakeProfileProvider{profile: &types.Profile{
+		UserID: "001",
+		WeeklyAvailability: []types.WeeklyBlock{
+			{Weekday: time.Tuesday, DailyWindow: types.DailyWindow{StartHour: 10, EndHour: 12}},
+		},
+		HomeLocation: &types.UserLocation{Latitude: 33.7756, Longitude: -84.3963},
+		TimeZone:     "UTC",
+	}}
+	handler := GetAvailability(events, profiles, testSessions)
+
+	for _, sessionUserID := range []string{"002", "", "001"} {
+		recorder := serve(http.MethodGet, "/{id}/availability/{user_id}", handler, "/e/availability/001", sessionUserID)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("session %q: status = %d, want 200", sessionUserID, recorder.Code)
+		}
+		var body getAvailabilityResponseBody
+		if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		owner := sessionUserID == "001"
+		if body.Prefilled != owner || (len(body.Days) > 0) != owner {
+			t.Errorf("session %q: prefilled = %v with %d days, want prefilled = %v",
+				sessionUserID, body.Prefilled, len(body.Days), owner)
+		}
+		if (body.Location != nil) != owner {
+			t.Errorf("session %q: location = %+v, want the home location only for the user", sessionUserID, body.Location)
+		}
+		// Only the user's own requests can query their CalDAV server with their credentials
+		if lookedUp := profiles.calDAVLookups > 0; lookedUp != owner {
+			t.Errorf("session %q: CalDAV account looked up = %v, want %v", sessionUserID, lookedUp, owner)
+		}
+	}
+}
+
+func TestAnswersRejectedAfterEventOver(t *testing.T) {
+	handlers := map[string]http.HandlerFunc{
+		"/{id}/availability/{user_id}": PutAvailability(&fakeEventProvider{event: &types.Event{Finalized: true}}, nil),
+		"/{id}/poll/{user_id}":         PutPollAnswers(&fakeEventProvider{event: &types.Event{Cancelled: true, Mode: types.EventModePoll}}, nil),
+	}
+	for route, handler := range handlers {
+		router := chi.NewRouter()
+		router.Put(route, handler)
+		path := strings.NewReplacer("{id}", "e", "{user_id}", "001").Replace(route)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, path, strings.NewReader("{}")))
+		if recorder.Code != http.StatusConflict {
+			t.Errorf("%s: status = %d, want %d", route, recorder.Code, http.StatusConflict)
+		}
+	}
+}
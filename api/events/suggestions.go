@@ -0,0 +1,276 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/db"
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-chi/chi"
+)
+
+const (
+	// How much each component contributes to the score of a suggestion
+	attendanceWeight = 0.8
+	durationWeight   = 0.2
+)
+
+type getSuggestionsResponseBody struct {
+	// Number of users that have submitted availability (or poll answers)
+	Participants int `json:"participants"`
+	// The most users available for any single slot
+	MaxAvailable int `json:"max_available"`
+	// How many users needed to be available for every slot of a chosen time
+	// (only for events in grid mode)
+	Threshold   int              `json:"threshold"`
+	Suggestions []slotSuggestion `json:"suggestions"`
+}
+
+type slotSuggestion struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Score float64   `json:"score"`
+	// Whether the time is (or will be) one of the event's vote options
+	Chosen bool `json:"chosen"`
+	// Only included if ?explain=true
+	Explanation *slotExplanation `json:"explanation,omitempty"`
+}
+
+type slotExplanation struct {
+	Attending      []types.User    `json:"attending"`
+	MaybeAttending []types.User    `json:"maybe_attending"`
+	NotAttending   []types.User    `json:"not_attending"`
+	Components     scoreComponents `json:"components"`
+	Reasons        []string        `json:"reasons"`
+}
+
+type scoreComponents struct {
+	// Fraction of participants that can attend (maybes count as half)
+	Attendance float64 `json:"attendance"`
+	// Length of the time relative to the longest possible time (3 hrs)
+	Duration float64 `json:"duration"`
+}
+
+// GetSuggestions returns the candidate times for an event ranked by score.
+// If the explain query parameter is true, each time also includes who can and can't attend,
+// the components of its score, and why it was or wasn't chosen.
+func GetSuggestions(eventProvider db.EventProvider, discordSession *discordgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the event ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+		explain := r.URL.Query().Get("explain") == "true"
+
+		log.Printf("GetSuggestions event_id=%s explain=%t", id, explain)
+		event, err := eventProvider.GetSingle(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		busy, err := busyTimes(r.Context(), eventProvider, *event)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		responseBody := rankSuggestions(*event, busy)
+		if explain {
+			addToUsers := userColorsAndNames(event.GuildID, discordSession)
+			for _, suggestion := range responseBody.Suggestions {
+				addToUsers(suggestion.Explanation.Attending)
+				addToUsers(suggestion.Explanation.MaybeAttending)
+				addToUsers(suggestion.Explanation.NotAttending)
+			}
+		} else {
+			for i := range responseBody.Suggestions {
+				responseBody.Suggestions[i].Explanation = nil
+			}
+		}
+
+		jsonResponse, err := json.Marshal(&responseBody)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// rankSuggestions finds every candidate time that FindAvailability considers
+// (at any threshold), and ranks them by score along with an explanation for each.
+// Busy users are never counted as attending.
+func rankSuggestions(event types.Event, busy map[string][]types.TimeWindow) getSuggestionsResponseBody {
+	if event.Mode == types.EventModePoll {
+		return rankPollSuggestions(event)
+	}
+
+	participants := make([]string, 0, len(event.UserAvailability))
+	for userID := range event.UserAvailability {
+		participants = append(participants, userID)
+	}
+
+	blocks := usableBlocks(event, busy)
+	slotsPerWindow, max := slotsWithUsers(event, blocks)
+	chosenPairs, chosenThreshold := selectPopularTimes(slotsPerWindow, max)
+	chosen := make(map[types.TimeWindow]bool)
+	for _, pair := range chosenPairs {
+		chosen[types.TimeWindow{Start: pair.Start, End: pair.End}] = true
+	}
+
+	// Collect the times found at each threshold,
+	// keeping track of the highest threshold each was found at
+	foundAt := make(map[types.TimeWindow]int)
+	var candidates []types.TimeWindow
+	for threshold := max; threshold > 0; threshold-- {
+		pairs, _ := popularTimes(slotsPerWindow, threshold)
+		for _, pair := range pairs {
+			key := types.TimeWindow{Start: pair.Start, End: pair.End}
+			if _, ok := foundAt[key]; !ok {
+				foundAt[key] = threshold
+				candidates = append(candidates, key)
+			}
+		}
+	}
+
+	suggestions := make([]slotSuggestion, len(candidates))
+	for i, candidate := range candidates {
+		attending := availableUsers(blocks, candidate.Start, candidate.End)
+		components := scoreComponents{
+			Attendance: fraction(len(attending), len(participants)),
+			Duration:   candidate.End.Sub(candidate.Start).Hours() / (float64(maxSlotsPerPair) * slotLength.Hours()),
+		}
+
+		reasons := []string{fmt.Sprintf("%d of %d participants can attend the entire time", len(attending), len(participants))}
+		threshold := foundAt[candidate]
+		switch {
+		case chosen[candidate]:
+			reasons = append(reasons, fmt.Sprintf("chosen because at least %d users are available for every 30 minutes of it", chosenThreshold))
+			if chosenThreshold < max {
+				reasons = append(reasons, fmt.Sprintf("the threshold was lowered from %d to %d users because fewer than 3 dates had times that met it", max, chosenThreshold))
+			}
+		case threshold < chosenThreshold:
+			reasons = append(reasons, fmt.Sprintf("not chosen because only %d users are available for some of it, which is below the threshold of %d", threshold, chosenThreshold))
+		default:
+			reasons = append(reasons, fmt.Sprintf("not chosen because the threshold was lowered to %d users, which found other times that overlap it", chosenThreshold))
+		}
+		if candidate.End.Sub(candidate.Start) == time.Duration(maxSlotsPerPair)*slotLength {
+			reasons = append(reasons, "times are limited to 3 hrs, so longer availability is split up")
+		}
+
+		suggestions[i] = slotSuggestion{
+			Start:  candidate.Start,
+			End:    candidate.End,
+			Score:  attendanceWeight*components.Attendance + durationWeight*components.Duration,
+			Chosen: chosen[candidate],
+			Explanation: &slotExplanation{
+				Attending:      attending,
+				MaybeAttending: []types.User{},
+				NotAttending:   otherUsers(participants, attending),
+				Components:     components,
+				Reasons:        reasons,
+			},
+		}
+	}
+	sortSuggestions(suggestions)
+
+	return getSuggestionsResponseBody{
+		Participants: len(participants),
+		MaxAvailable: max,
+		Threshold:    chosenThreshold,
+		Suggestions:  suggestions,
+	}
+}
+
+// rankPollSuggestions ranks the candidate times of an event in poll mode
+// (which are always all chosen)
+func rankPollSuggestions(event types.Event) getSuggestionsResponseBody {
+	participants := make([]string, 0, len(event.UserPollAnswers))
+	for userID := range event.UserPollAnswers {
+		participants = append(participants, userID)
+	}
+
+	max := 0
+	pairs := pollTimes(event)
+	suggestions := make([]slotSuggestion, len(pairs))
+	for i, pair := range pairs {
+		if len(pair.Users) > max {
+			max = len(pair.Users)
+		}
+		// Maybes count as half of a yes
+		components := scoreComponents{
+			Attendance: fraction(2*len(pair.Users)+len(pair.MaybeUsers), 2*len(participants)),
+			Duration:   pair.End.Sub(pair.Start).Hours() / (float64(maxSlotsPerPair) * slotLength.Hours()),
+		}
+		suggestions[i] = slotSuggestion{
+			Start:  pair.Start,
+			End:    pair.End,
+			Score:  attendanceWeight*components.Attendance + durationWeight*components.Duration,
+			Chosen: true,
+			Explanation: &slotExplanation{
+				Attending:      pair.Users,
+				MaybeAttending: pair.MaybeUsers,
+				NotAttending:   otherUsers(participants, append(append([]types.User{}, pair.Users...), pair.MaybeUsers...)),
+				Components:     components,
+				Reasons: []string{
+					fmt.Sprintf("%d of %d participants answered yes and %d answered maybe", len(pair.Users), len(participants), len(pair.MaybeUsers)),
+					"chosen because it was proposed by the creator",
+				},
+			},
+		}
+	}
+	sortSuggestions(suggestions)
+
+	return getSuggestionsResponseBody{
+		Participants: len(participants),
+		MaxAvailable: max,
+		Suggestions:  suggestions,
+	}
+}
+
+// sortSuggestions sorts by descending score, then by start time
+func sortSuggestions(suggestions []slotSuggestion) {
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Start.Before(suggestions[j].Start)
+	})
+}
+
+// otherUsers returns the participants (sorted by ID) that aren't in users
+func otherUsers(participants []string, users []types.User) []types.User {
+	included := make(map[string]bool)
+	for _, user := range users {
+		included[user.ID] = true
+	}
+
+	others := []types.User{}
+	for _, userID := range participants {
+		if !included[userID] {
+			others = append(others, types.User{ID: userID})
+		}
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].ID < others[j].ID })
+	return others
+}
+
+func fraction(count int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
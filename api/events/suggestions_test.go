@@ -0,0 +1,59 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+func TestRankSuggestions(t *testing.T) {
+	mar4 := time.Date(2022, time.March, 4, 0, 0, 0, 0, time.UTC)
+	event := types.Event{
+		EarliestDate:  mar4,
+		LatestDate:    mar4,
+		StartTimeHour: 18,
+		EndTimeHour:   22,
+		UserAvailability: map[string]types.UserAvailability{
+			"001": {DayAvailability: []types.DayAvailability{
+				{Date: mar4, AvailableBlocks: []types.AvailabilityBlock{timeBlock(mar4, 18, 0, 22, 0)}},
+			}},
+			"002": {DayAvailability: []types.DayAvailability{
+				{Date: mar4, AvailableBlocks: []types.AvailabilityBlock{timeBlock(mar4, 19, 0, 21, 0)}},
+			}},
+		},
+	}
+
+	response := rankSuggestions(event, nil)
+	if response.Participants != 2 || response.MaxAvailable != 2 {
+		t.Fatalf("expected 2 participants and at most 2 available, got %+v", response)
+	}
+	// With a single date, the threshold is lowered all the way to 1 user
+	if response.Threshold != 1 {
+		t.Errorf("expected the threshold to be lowered to 1, got %d", response.Threshold)
+	}
+	if len(response.Suggestions) == 0 {
+		t.Fatalf("expected suggestions")
+	}
+
+	best := response.Suggestions[0]
+	if !best.Start.Equal(mar4.Add(19*time.Hour)) || !best.End.Equal(mar4.Add(21*time.Hour)) {
+		t.Errorf("expected 19:00 - 21:00 to be ranked first, got %v - %v", best.Start, best.End)
+	}
+	if best.Chosen {
+		t.Errorf("expected 19:00 - 21:00 not to be chosen since the threshold was lowered")
+	}
+	if len(best.Explanation.Attending) != 2 || len(best.Explanation.NotAttending) != 0 {
+		t.Errorf("expected both users to attend, got %+v", best.Explanation)
+	}
+
+	chosen := 0
+	for _, suggestion := range response.Suggestions {
+		if suggestion.Chosen {
+			chosen++
+		}
+	}
+	if chosen != len(FindAvailability(event, nil)) {
+		t.Errorf("expected the chosen suggestions to match FindAvailability, got %d chosen", chosen)
+	}
+}
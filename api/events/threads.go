@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/3-brain-cells/sah-backend/api/locations"
@@ -15,7 +16,7 @@ import (
 )
 
 // ManageEvent manages an event after it has been populated
-func ManageEvent(eventProvider db.EventProvider, discordSession *discordgo.Session, eventID string) {
+func ManageEvent(eventProvider db.EventProvider, discordSession *discordgo.Session, places *locations.Selector, router locations.Router, eventID string) {
 	currentTime := time.Now()
 
 	// get the event associated with the eventID
@@ -25,12 +26,16 @@ func ManageEvent(eventProvider db.EventProvider, discordSession *discordgo.Sessi
 		fmt.Println("error getting event: ", err)
 		return
 	}
+	if event.Cancelled {
+		log.Printf("Event %s (event_id=%s) was cancelled; returning early", event.Title, event.EventID)
+		return
+	}
 	if currentTime.Before(event.SwitchToVotingTime) {
 		// event is currently in scheduling phase
 		str := fmt.Sprintf("New event created: **%s**\n"+
 			"Possible dates: %v through %v\n"+
-			"Possible times: %d:%02d through %d:%02d\n"+
-			"\nEnter your availability here: <https://super-auto-hangouts.netlify.app/availability/%s>", event.Title, event.EarliestDate.Format("01-02-2006"), event.LatestDate.Format("01-02-2006"), event.StartTimeHour, event.StartTimeMinute, event.EndTimeHour, event.EndTimeMinute, event.EventID)
+			"Possible times: %s\n"+
+			"\nEnter your availability here: <https://super-auto-hangouts.netlify.app/%s/%s>", event.Title, event.EarliestDate.Format("01-02-2006"), event.LatestDate.Format("01-02-2006"), describeTimes(*event), availabilityPage(*event), event.EventID)
 		bot.SchedulingMessage(discordSession, str, event.ChannelID)
 		// time.Sleep(event.SwitchToVotingTime.Sub(currentTime))
 		time.Sleep(time.Second * 60)
@@ -42,18 +47,32 @@ func ManageEvent(eventProvider db.EventProvider, discordSession *discordgo.Sessi
 			fmt.Println("error getting event: ", err)
 			return
 		}
-		availTimes := FindAvailability(*event)
-		// Add all user colors and names to the vote time options
-		addUserColorsAndNames(event.GuildID, availTimes, discordSession)
-		availLocations, err := locations.GetNearby(*event)
-		if err != nil {
-			fmt.Println("error getting locations: ", err)
+		if event.Cancelled {
+			log.Printf("Event %s (event_id=%s) was cancelled; returning early", event.Title, event.EventID)
 			return
 		}
+		var availTimes []types.TimePair
+		if event.Mode == types.EventModePoll {
+			availTimes = pollTimes(*event)
+		} else {
+			// Treat the times users are attending other finalized events as busy
+			busy, err := busyTimes(ctx, eventProvider, *event)
+			if err != nil {
+				fmt.Println("error getting busy times: ", err)
+			}
+			availTimes = FindAvailability(*event, busy)
+		}
+		// Add all user colors and names to the vote time options
+		addUserColorsAndNames(event.GuildID, availTimes, discordSession)
 
 		// update these two to the database
 		event.VoteOptions.StartEndPairs = availTimes
-		event.VoteOptions.Location = availLocations
+		// Virtual events don't have venues to vote on
+		votingOn := "time"
+		if hasVenue(*event) {
+			event.VoteOptions.Location, event.VoteOptions.TravelMinutes, event.VoteOptions.Midpoint = venueOptions(ctx, *event, availTimes, places, router)
+			votingOn = "location and time"
+		}
 		// update the database
 		ctx := context.Background()
 		err = eventProvider.UpdateVoteOptions(ctx, event.VoteOptions, event.EventID)
@@ -61,9 +80,9 @@ func ManageEvent(eventProvider db.EventProvider, discordSession *discordgo.Sessi
 			fmt.Println("error updating event: ", err)
 			return
 		}
-		str := fmt.Sprintf("Voting for event **%s** location and time has started: <https://super-auto-hangouts.netlify.app/vote/%s>\n"+
+		str := fmt.Sprintf("Voting for event **%s** %s has started: <https://super-auto-hangouts.netlify.app/vote/%s>\n"+
 			"Possible dates: %v through %v\n"+
-			"Possible times: %d:%02d through %d:%02d\n", event.Title, event.EventID, event.EarliestDate.Format("01-02-2006"), event.LatestDate.Format("01-02-2006"), event.StartTimeHour, event.StartTimeMinute, event.EndTimeHour, event.EndTimeMinute)
+			"Possible times: %s\n", event.Title, votingOn, event.EventID, event.EarliestDate.Format("01-02-2006"), event.LatestDate.Format("01-02-2006"), describeTimes(*event))
 		bot.SchedulingMessage(discordSession, str, event.ChannelID)
 		// time.Sleep(event.EarliestDate.Sub(currentTime))
 		time.Sleep(time.Second * 60)
@@ -78,8 +97,18 @@ func ManageEvent(eventProvider db.EventProvider, discordSession *discordgo.Sessi
 		return
 	}
 
+	if event.Cancelled {
+		log.Printf("Event %s (event_id=%s) was cancelled; returning early", event.Title, event.EventID)
+		return
+	}
+
 	if len(event.UserVotes) == 0 {
 		log.Printf("No votes for event %s (event_id=%s); returning early", event.Title, event.EventID)
+		// The event won't be finalized, so the users' locations are deleted now
+		err = eventProvider.DeleteLocations(ctx, eventID)
+		if err != nil {
+			log.Printf("Failed to delete the locations for event_id=%s: %v", event.EventID, err)
+		}
 		return
 	}
 
@@ -117,22 +146,64 @@ func ManageEvent(eventProvider db.EventProvider, discordSession *discordgo.Sessi
 	}
 
 	// get the actual location and time and create string
-	locationFinal := event.VoteOptions.Location[locationIndex]
+	// (there are no locations for virtual events, or if none were found)
+	var locationFinal types.Location
+	if locationIndex >= 0 && locationIndex < len(event.VoteOptions.Location) {
+		locationFinal = event.VoteOptions.Location[locationIndex]
+	}
 	startEndFinal := event.VoteOptions.StartEndPairs[timeIndex]
 
+	err = eventProvider.FinalizeEvent(ctx, event.EventID, startEndFinal, locationFinal)
+	if err != nil {
+		fmt.Println("error finalizing event: ", err)
+	}
+
 	loc, _ := time.LoadLocation("EST")
 	start := time.Date(startEndFinal.Start.Year(), startEndFinal.Start.Month(), startEndFinal.Start.Day(), startEndFinal.Start.Hour(), startEndFinal.Start.Minute(), 0, 0, loc)
 	end := time.Date(startEndFinal.End.Year(), startEndFinal.End.Month(), startEndFinal.End.Day(), startEndFinal.End.Hour(), startEndFinal.End.Minute(), 0, 0, loc)
-	str := fmt.Sprintf("Event %v is now over. The event will take place at %v (%v) on %v from %d:%02d till %d:%02d", event.Title, locationFinal.Name, locationFinal.Address, start.Format("01-02-2006"), start.Hour(), start.Minute(), end.Hour(), end.Minute())
+	str := finalAnnouncement(*event, start, end, locationFinal)
 	bot.SchedulingMessage(discordSession, str, event.ChannelID)
 
 }
 
+// availabilityPage is the frontend page that users give their availability on
+func availabilityPage(event types.Event) string {
+	if event.Mode == types.EventModePoll {
+		return "poll"
+	}
+	return "availability"
+}
+
+// describeTimes summarizes the times of day that the event can take place at
+func describeTimes(event types.Event) string {
+	if event.Mode == types.EventModePoll {
+		candidates := make([]string, len(event.CandidateTimes))
+		for i, candidate := range event.CandidateTimes {
+			candidates[i] = fmt.Sprintf("%s %d:%02d through %d:%02d", candidate.Start.Format("01-02-2006"),
+				candidate.Start.Hour(), candidate.Start.Minute(), candidate.End.Hour(), candidate.End.Minute())
+		}
+		return strings.Join(candidates, ", ")
+	}
+
+	times := fmt.Sprintf("%d:%02d through %d:%02d", event.StartTimeHour, event.StartTimeMinute, event.EndTimeHour, event.EndTimeMinute)
+	if event.WeekdayWindow != nil || event.WeekendWindow != nil || len(event.DateWindows) > 0 {
+		times += " (varies by date)"
+	}
+	if len(event.ExcludedDates) > 0 {
+		excluded := make([]string, len(event.ExcludedDates))
+		for i, date := range event.ExcludedDates {
+			excluded[i] = date.Format("01-02-2006")
+		}
+		times += fmt.Sprintf(", excluding %s", strings.Join(excluded, ", "))
+	}
+	return times
+}
+
 // upon restart of the application, need to restart all in progress events
 // get all events from the database
 // for each event, check if it is in progress (compare the last time to current time and is populated)
 // if it is in progress, then restart it (call ManageEvent), else remove it
-func Restart(eventProvider db.EventProvider, discordSession *discordgo.Session) {
+func Restart(eventProvider db.EventProvider, discordSession *discordgo.Session, places *locations.Selector, router locations.Router) {
 	// get all events
 	ctx := context.Background()
 
@@ -149,7 +220,7 @@ func Restart(eventProvider db.EventProvider, discordSession *discordgo.Session)
 			// check that it is still before the initial event time
 			if time.Now().Before(event.EarliestDate) {
 				// restart event
-				ManageEvent(eventProvider, discordSession, event.EventID)
+				ManageEvent(eventProvider, discordSession, places, router, event.EventID)
 			}
 		}
 	}
@@ -157,6 +228,16 @@ func Restart(eventProvider db.EventProvider, discordSession *discordgo.Session)
 }
 
 func addUserColorsAndNames(guildID string, availTimes []types.TimePair, discordSession *discordgo.Session) {
+	addToUsers := userColorsAndNames(guildID, discordSession)
+	for i := range availTimes {
+		addToUsers(availTimes[i].Users)
+		addToUsers(availTimes[i].MaybeUsers)
+	}
+}
+
+// userColorsAndNames returns a function that fills in the color and name of users
+// from their membership in the guild, only fetching each member once
+func userColorsAndNames(guildID string, discordSession *discordgo.Session) func(users []types.User) {
 	type colorAndName struct {
 		Color string
 		Name  string
@@ -168,9 +249,9 @@ func addUserColorsAndNames(guildID string, availTimes []types.TimePair, discordS
 	}
 
 	colorMap := make(map[string]colorAndName)
-	for i := range availTimes {
-		for j := range availTimes[i].Users {
-			id := availTimes[i].Users[j].ID
+	return func(users []types.User) {
+		for j := range users {
+			id := users[j].ID
 			if _, ok := colorMap[id]; !ok {
 				var name string = "unknown"
 				var color string = "#222222"
@@ -201,14 +282,18 @@ func addUserColorsAndNames(guildID string, availTimes []types.TimePair, discordS
 				}
 			}
 
-			availTimes[i].Users[j].Color = colorMap[id].Color
-			availTimes[i].Users[j].Name = colorMap[id].Name
+			users[j].Color = colorMap[id].Color
+			users[j].Name = colorMap[id].Name
 		}
 	}
 }
 
 // From https://github.com/bwmarrin/discordgo/blob/cd95ccc2d3c030436fcd9ec3caf0b43f539350dd/state.go#L1258
 func firstRoleColor(guild *discordgo.Guild, memberRoles []string) int {
+	if guild == nil {
+		return 0
+	}
+
 	roles := discordgo.Roles(guild.Roles)
 	sort.Sort(roles)
 
@@ -0,0 +1,85 @@
+package events
+
+import (
+	"math"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/api/locations"
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+const (
+	// Average speed (in mph) used to estimate travel buffers from distance
+	estimatedTravelSpeed = 20
+	// Estimated travel buffers are rounded up to a multiple of this
+	travelBufferIncrement = 5 * time.Minute
+	// Travel buffers can't be longer than this
+	maxTravelBuffer = 4 * time.Hour
+)
+
+// travelBuffers maps each user's ID => the time they need to travel to and from the event.
+// Users can state their own buffer,
+// otherwise it is estimated from their distance to the midpoint of all users
+// (found with the event's midpoint strategy).
+func travelBuffers(event types.Event) map[string]time.Duration {
+	midpoint, hasMidpoint := locations.EventMidpoint(event)
+
+	buffers := make(map[string]time.Duration)
+	for userID, userAvailability := range event.UserAvailability {
+		if userAvailability.TravelBufferMinutes != nil {
+			buffers[userID] = time.Duration(*userAvailability.TravelBufferMinutes) * time.Minute
+			continue
+		}
+
+		location, ok := event.UserLocations[userID]
+		if !ok || !locations.HasLocation(location) || !hasMidpoint {
+			continue
+		}
+		distance := latLongDistance(
+			coords{location.Latitude, location.Longitude},
+			coords{midpoint.Latitude, midpoint.Longitude},
+		)
+		buffers[userID] = estimateTravelBuffer(distance)
+	}
+	return buffers
+}
+
+// estimateTravelBuffer estimates the time needed to travel the distance (in miles)
+func estimateTravelBuffer(distance float64) time.Duration {
+	minutes := distance / estimatedTravelSpeed * 60
+	increments := math.Ceil(minutes / travelBufferIncrement.Minutes())
+	buffer := time.Duration(increments) * travelBufferIncrement
+	if buffer > maxTravelBuffer {
+		return maxTravelBuffer
+	}
+	return buffer
+}
+
+// usableBlocks maps each user's ID => their blocks of availability
+// without the times they are busy at other events,
+// and then shrunk on both ends by their travel buffer
+// (since they can't arrive right at the start of a block or leave right at its end)
+func usableBlocks(event types.Event, busy map[string][]types.TimeWindow) map[string][]types.AvailabilityBlock {
+	buffers := travelBuffers(event)
+	blocks := userBlocks(event)
+	for userID, userBlocks := range blocks {
+		userBlocks = subtractBusy(userBlocks, busy[userID])
+		blocks[userID] = userBlocks
+
+		buffer := buffers[userID]
+		if buffer == 0 {
+			continue
+		}
+
+		shrunk := []types.AvailabilityBlock{}
+		for _, block := range userBlocks {
+			block.Start = block.Start.Add(buffer)
+			block.End = block.End.Add(-buffer)
+			if block.End.After(block.Start) {
+				shrunk = append(shrunk, block)
+			}
+		}
+		blocks[userID] = shrunk
+	}
+	return blocks
+}
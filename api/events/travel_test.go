@@ -0,0 +1,50 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+func TestTravelBuffers(t *testing.T) {
+	mar4 := time.Date(2022, time.March, 4, 0, 0, 0, 0, time.UTC)
+	thirtyMinutes := 30
+	event := types.Event{
+		EarliestDate:  mar4,
+		LatestDate:    mar4,
+		StartTimeHour: 18,
+		EndTimeHour:   22,
+		UserAvailability: map[string]types.UserAvailability{
+			"001": {
+				DayAvailability:     []types.DayAvailability{{Date: mar4, AvailableBlocks: []types.AvailabilityBlock{timeBlock(mar4, 18, 0, 21, 0)}}},
+				TravelBufferMinutes: &thirtyMinutes,
+			},
+			"002": {DayAvailability: []types.DayAvailability{{Date: mar4, AvailableBlocks: []types.AvailabilityBlock{timeBlock(mar4, 18, 0, 21, 0)}}}},
+			"003": {DayAvailability: []types.DayAvailability{{Date: mar4, AvailableBlocks: []types.AvailabilityBlock{timeBlock(mar4, 18, 0, 21, 0)}}}},
+		},
+		// 002 and 003 are about 10 miles apart, so each is 5 miles from the midpoint
+		UserLocations: map[string]types.UserLocation{
+			"002": {Latitude: 40.0, Longitude: -74.0},
+			"003": {Latitude: 40.0 + 9.8/69.0, Longitude: -74.0},
+		},
+	}
+
+	buffers := travelBuffers(event)
+	if buffers["001"] != 30*time.Minute {
+		t.Errorf("expected the stated buffer to be used, got %v", buffers["001"])
+	}
+	if buffers["002"] != 15*time.Minute || buffers["003"] != 15*time.Minute {
+		t.Errorf("expected 5 miles at 20 mph to be rounded up to 15 minutes, got %v and %v", buffers["002"], buffers["003"])
+	}
+
+	blocks := usableBlocks(event, nil)
+	if !blocks["001"][0].Start.Equal(mar4.Add(18*time.Hour+30*time.Minute)) || !blocks["001"][0].End.Equal(mar4.Add(20*time.Hour+30*time.Minute)) {
+		t.Errorf("expected the block to be shrunk on both ends, got %+v", blocks["001"][0])
+	}
+
+	users := availableUsers(blocks, mar4.Add(18*time.Hour), mar4.Add(19*time.Hour))
+	if len(users) != 0 {
+		t.Errorf("expected nobody to be able to arrive right at the start, got %+v", users)
+	}
+}
@@ -0,0 +1,129 @@
+package events
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+)
+
+// normalizeAvailability validates submitted availability against the event.
+// Days that don't have a window in the event and malformed blocks are rejected
+// with a *util.ValidationError, while the remaining blocks are clipped to the
+// window of their day and merged when they overlap.
+// The returned days are sorted by date and never contain empty days.
+func normalizeAvailability(event types.Event, days []types.DayAvailability) ([]types.DayAvailability, error) {
+	validationError := &util.ValidationError{}
+	windows := eventWindows(event)
+
+	// Maps the date's Unix time => index in normalized
+	dayIndices := make(map[int64]int)
+	var normalized []types.DayAvailability
+	for i, day := range days {
+		field := fmt.Sprintf("days[%d]", i)
+		window, ok := windowForDate(windows, day.Date)
+		if !ok {
+			validationError.Add(field+".date", "date %s is not one of the event's dates (%s through %s)",
+				day.Date.Format("2006-01-02"), event.EarliestDate.Format("2006-01-02"), event.LatestDate.Format("2006-01-02"))
+			continue
+		}
+
+		var blocks []types.AvailabilityBlock
+		for j, block := range day.AvailableBlocks {
+			blockField := fmt.Sprintf("%s.available_blocks[%d]", field, j)
+			if !validateBlock(validationError, blockField, block) {
+				continue
+			}
+
+			// Clip the block to the window of its day
+			block = types.AvailabilityBlock{
+				Start: latest(block.Start, window.Start),
+				End:   earliest(block.End, window.End),
+			}
+			if !block.End.After(block.Start) {
+				continue
+			}
+			blocks = append(blocks, block)
+		}
+
+		// Days that appear multiple times are combined
+		if index, ok := dayIndices[day.Date.Unix()]; ok {
+			normalized[index].AvailableBlocks = append(normalized[index].AvailableBlocks, blocks...)
+		} else {
+			dayIndices[day.Date.Unix()] = len(normalized)
+			normalized = append(normalized, types.DayAvailability{
+				Date:            day.Date,
+				AvailableBlocks: blocks,
+			})
+		}
+	}
+
+	if err := validationError.OrNil(); err != nil {
+		return nil, err
+	}
+
+	result := []types.DayAvailability{}
+	for _, day := range normalized {
+		day.AvailableBlocks = mergeBlocks(day.AvailableBlocks)
+		if len(day.AvailableBlocks) > 0 {
+			result = append(result, day)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Date.Before(result[j].Date) })
+
+	return result, nil
+}
+
+// validateBlock records any problems with the block's fields,
+// returning whether the block is valid
+func validateBlock(validationError *util.ValidationError, field string, block types.AvailabilityBlock) bool {
+	valid := true
+	if block.Start.IsZero() {
+		validationError.Add(field+".start", "start time is missing")
+		valid = false
+	}
+	if block.End.IsZero() {
+		validationError.Add(field+".end", "end time is missing")
+		valid = false
+	}
+	if valid && !block.End.After(block.Start) {
+		validationError.Add(field, "block ends at %s, which is not after its start at %s",
+			block.End.Format(time.RFC3339), block.Start.Format(time.RFC3339))
+		valid = false
+	}
+	return valid
+}
+
+// mergeBlocks sorts the blocks and combines any that overlap or touch
+func mergeBlocks(blocks []types.AvailabilityBlock) []types.AvailabilityBlock {
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Start.Before(blocks[j].Start) })
+
+	var merged []types.AvailabilityBlock
+	for _, block := range blocks {
+		if len(merged) > 0 {
+			last := &merged[len(merged)-1]
+			if !block.Start.After(last.End) {
+				last.End = latest(last.End, block.End)
+				continue
+			}
+		}
+		merged = append(merged, block)
+	}
+	return merged
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,68 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+)
+
+func TestNormalizeAvailability(t *testing.T) {
+	feb28 := time.Date(2022, time.February, 28, 0, 0, 0, 0, time.UTC)
+	mar1 := feb28.AddDate(0, 0, 1)
+	event := types.Event{
+		EarliestDate:  feb28,
+		LatestDate:    mar1,
+		StartTimeHour: 20,
+		EndTimeHour:   2,
+	}
+
+	days, err := normalizeAvailability(event, []types.DayAvailability{
+		{Date: mar1, AvailableBlocks: []types.AvailabilityBlock{timeBlock(mar1, 18, 0, 21, 0)}},
+		{Date: feb28, AvailableBlocks: []types.AvailabilityBlock{
+			timeBlock(feb28, 23, 0, 25, 0),
+			timeBlock(feb28, 21, 0, 23, 30),
+			timeBlock(feb28, 26, 0, 27, 0),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []types.DayAvailability{
+		{Date: feb28, AvailableBlocks: []types.AvailabilityBlock{timeBlock(feb28, 21, 0, 25, 0)}},
+		{Date: mar1, AvailableBlocks: []types.AvailabilityBlock{timeBlock(mar1, 20, 0, 21, 0)}},
+	}
+	if len(days) != len(expected) {
+		t.Fatalf("expected %d days, got %+v", len(expected), days)
+	}
+	for i := range expected {
+		if !days[i].Date.Equal(expected[i].Date) || len(days[i].AvailableBlocks) != 1 ||
+			!days[i].AvailableBlocks[0].Start.Equal(expected[i].AvailableBlocks[0].Start) ||
+			!days[i].AvailableBlocks[0].End.Equal(expected[i].AvailableBlocks[0].End) {
+			t.Errorf("day %d: expected %+v, got %+v", i, expected[i], days[i])
+		}
+	}
+
+	_, err = normalizeAvailability(event, []types.DayAvailability{
+		{Date: mar1.AddDate(0, 0, 1), AvailableBlocks: []types.AvailabilityBlock{timeBlock(mar1, 44, 0, 45, 0)}},
+		{Date: feb28, AvailableBlocks: []types.AvailabilityBlock{
+			timeBlock(feb28, 22, 0, 21, 0),
+			{End: feb28.Add(22 * time.Hour)},
+		}},
+	})
+	validationError, ok := err.(*util.ValidationError)
+	if !ok {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	fields := make(map[string]bool)
+	for _, field := range validationError.Fields {
+		fields[field.Field] = true
+	}
+	for _, field := range []string{"days[0].date", "days[1].available_blocks[0]", "days[1].available_blocks[1].start"} {
+		if !fields[field] {
+			t.Errorf("expected an error for %s, got %+v", field, validationError.Fields)
+		}
+	}
+}
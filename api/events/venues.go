@@ -0,0 +1,398 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/api/locations"
+	"github.com/3-brain-cells/sah-backend/api/oauth"
+	"github.com/3-brain-cells/sah-backend/db"
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+	"github.com/go-chi/chi"
+	"github.com/segmentio/ksuid"
+)
+
+// Link to a photo of a place served through the photo proxy (given the provider and the photo reference)
+const placePhotoURL = "https://kairosaio.com/api/v1/photos/%s/%s"
+
+const (
+	// Each user can suggest at most this many venues for an event
+	maxSuggestedVenuesPerUser = 3
+	maxVenueNameLength        = 100
+	// An event can have at most this many suggested venues,
+	// which are voted on instead of some of the places that are found
+	maxSuggestedVenues = locations.NearbyLimit
+)
+
+type postVenueRequestBody struct {
+	// Optional, since the venue is suggested by the user whose session the request has
+	UserID    string  `json:"user_id"`
+	Name      string  `json:"name"`
+	Address   string  `json:"address"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Optional link to more information about the venue
+	URL string `json:"url"`
+}
+
+// PostVenue adds a venue that the creator or a participant wants to vote on.
+// Venues can only be suggested until voting starts.
+// The route must be behind oauth.Sessions.RequireSession.
+func PostVenue(eventProvider db.EventProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the event ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		var body postVenueRequestBody
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusBadRequest)
+			return
+		}
+		body.Name = strings.TrimSpace(body.Name)
+		body.Address = strings.TrimSpace(body.Address)
+
+		userID := oauth.SessionUser(r.Context())
+		if body.UserID != "" && body.UserID != userID {
+			util.ErrorWithCode(r, w, errors.New("venues can only be suggested as the logged-in user"),
+				http.StatusForbidden)
+			return
+		}
+
+		validationError := &util.ValidationError{}
+		if body.Name == "" || len(body.Name) > maxVenueNameLength {
+			validationError.Add("name", "name must be between 1 and %d characters", maxVenueNameLength)
+		}
+		if body.Latitude < -90 || body.Latitude > 90 {
+			validationError.Add("latitude", "latitude %v must be between -90 and 90", body.Latitude)
+		}
+		if body.Longitude < -180 || body.Longitude > 180 {
+			validationError.Add("longitude", "longitude %v must be between -180 and 180", body.Longitude)
+		}
+		if !locations.HasLocation(types.UserLocation{Latitude: body.Latitude, Longitude: body.Longitude}) {
+			validationError.Add("latitude", "the venue's coordinates are required")
+		}
+		if body.URL != "" {
+			venueURL, err := url.Parse(body.URL)
+			if err != nil || (venueURL.Scheme != "https" && venueURL.Scheme != "http") || venueURL.Host == "" {
+				validationError.Add("url", "url must be an http or https link")
+			}
+		}
+		if err := validationError.OrNil(); err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		event, err := eventProvider.GetSingle(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		if !hasVenue(*event) {
+			util.ErrorWithCode(r, w, errors.New("the event is virtual, so venues can't be suggested"),
+				http.StatusBadRequest)
+			return
+		}
+		if !isParticipant(*event, userID) {
+			util.ErrorWithCode(r, w, errors.New("only the creator and participants of the event can suggest venues"),
+				http.StatusForbidden)
+			return
+		}
+		if votingStarted(*event) {
+			util.ErrorWithCode(r, w, errors.New("venues can't be suggested after voting has started"),
+				http.StatusConflict)
+			return
+		}
+		if len(event.SuggestedVenues) >= maxSuggestedVenues {
+			util.ErrorWithCode(r, w, fmt.Errorf("an event can have at most %d suggested venues", maxSuggestedVenues),
+				http.StatusConflict)
+			return
+		}
+		suggestedCount := 0
+		for _, venue := range event.SuggestedVenues {
+			if venue.SuggestedBy == userID {
+				suggestedCount++
+			}
+		}
+		if suggestedCount >= maxSuggestedVenuesPerUser {
+			util.ErrorWithCode(r, w, fmt.Errorf("each user can suggest at most %d venues", maxSuggestedVenuesPerUser),
+				http.StatusConflict)
+			return
+		}
+
+		venue := types.Location{
+			Name:        body.Name,
+			Address:     body.Address,
+			Latitude:    body.Latitude,
+			Longitude:   body.Longitude,
+			URL:         body.URL,
+			Provider:    locations.ProviderSuggested,
+			PlaceID:     ksuid.New().String(),
+			SuggestedBy: userID,
+		}
+
+		log.Printf("PostVenue event_id=%s user_id=%s", id, userID)
+		err = eventProvider.AddSuggestedVenue(r.Context(), id, venue)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		jsonResponse, err := json.Marshal(&venue)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(jsonResponse)
+	}
+}
+
+// DeleteVenue removes a suggested venue,
+// which can be done by the user that suggested it or the event's creator.
+// The route must be behind oauth.Sessions.RequireSession.
+func DeleteVenue(eventProvider db.EventProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the event ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		venueID := chi.URLParam(r, "venue_id")
+		if venueID == "" {
+			util.ErrorWithCode(r, w, errors.New("the venue ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		userID := oauth.SessionUser(r.Context())
+
+		event, err := eventProvider.GetSingle(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		var venue *types.Location
+		for i := range event.SuggestedVenues {
+			if event.SuggestedVenues[i].PlaceID == venueID {
+				venue = &event.SuggestedVenues[i]
+			}
+		}
+		if venue == nil {
+			util.Error(r, w, db.NewNotFoundError(venueID))
+			return
+		}
+		if venue.SuggestedBy != userID && event.CreatorID != userID {
+			util.ErrorWithCode(r, w, errors.New("only the user that suggested the venue or the creator of the event can remove it"),
+				http.StatusForbidden)
+			return
+		}
+		if votingStarted(*event) {
+			util.ErrorWithCode(r, w, errors.New("venues can't be removed after voting has started"),
+				http.StatusConflict)
+			return
+		}
+
+		log.Printf("DeleteVenue event_id=%s venue_id=%s", id, venueID)
+		err = eventProvider.RemoveSuggestedVenue(r.Context(), id, venueID)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// isParticipant checks whether the user created the event or has answered it
+// (by giving their availability, poll answers or location)
+func isParticipant(event types.Event, userID string) bool {
+	_, hasAvailability := event.UserAvailability[userID]
+	_, hasPollAnswers := event.UserPollAnswers[userID]
+	_, hasLocation := event.UserLocations[userID]
+	return userID != "" && (event.CreatorID == userID || hasAvailability || hasPollAnswers || hasLocation)
+}
+
+// votingStarted checks whether the event's vote options have been found
+// (or it is already over)
+func votingStarted(event types.Event) bool {
+	return len(event.VoteOptions.StartEndPairs) > 0 || len(event.VoteOptions.Location) > 0 ||
+		eventOver(event)
+}
+
+// eventOver checks whether the event has been finalized or cancelled,
+// after which the users' locations are deleted and their answers can't be changed
+func eventOver(event types.Event) bool {
+	return event.Finalized || event.Cancelled
+}
+
+// validateVenueFilters records any problems with the event's venue filters
+func validateVenueFilters(validationError *util.ValidationError, filters types.VenueFilters) {
+	for i, category := range filters.Categories {
+		if strings.TrimSpace(category) == "" {
+			validationError.Add(fmt.Sprintf("venue_filters.categories[%d]", i), "category is empty")
+		}
+	}
+	if len(filters.Categories) > locations.MaxCategories {
+		validationError.Add("venue_filters.categories", "at most %d categories can be given", locations.MaxCategories)
+	}
+	if filters.RadiusMeters < 0 || filters.RadiusMeters > locations.MaxRadius {
+		validationError.Add("venue_filters.radius_meters", "radius %d must be between 0 and %d meters",
+			filters.RadiusMeters, locations.MaxRadius)
+	}
+	if filters.MinRating < 0 || filters.MinRating > 5 {
+		validationError.Add("venue_filters.min_rating", "minimum rating %v must be between 0 and 5", filters.MinRating)
+	}
+	if filters.MaxPriceLevel < 0 || filters.MaxPriceLevel > 4 {
+		validationError.Add("venue_filters.max_price_level", "price level %d must be between 0 and 4", filters.MaxPriceLevel)
+	}
+}
+
+// mostPopularTime returns the time option that the most users are available for
+// (the earliest one if there is a tie), or false if there are no options
+func mostPopularTime(pairs []types.TimePair) (types.TimePair, bool) {
+	var popular types.TimePair
+	most := -1
+	for _, pair := range pairs {
+		if len(pair.Users) > most || (len(pair.Users) == most && pair.Start.Before(popular.Start)) {
+			popular = pair
+			most = len(pair.Users)
+		}
+	}
+	return popular, most >= 0
+}
+
+// venueOptions finds the venues to vote on around the midpoint of the users' locations,
+// along with the venues that users suggested,
+// and returns them with the users' travel times to them and the midpoint (if any users gave a location).
+// If places can't be found, then only the suggested venues are voted on
+// (rather than the event not moving on to voting).
+func venueOptions(ctx context.Context, event types.Event, times []types.TimePair, places *locations.Selector, router locations.Router) ([]types.Location, map[string][]int, *types.Coordinates) {
+	var openAt time.Time
+	chosen, hasChosen := mostPopularTime(times)
+	if event.VenueFilters.OpenAtChosenTime && hasChosen {
+		openAt = chosen.Start
+	}
+
+	var found []types.Location
+	midpoint, hasMidpoint := locations.EventMidpoint(event)
+	if hasMidpoint {
+		var err error
+		found, err = locations.GetNearby(ctx, places.ForGuild(event.GuildID), midpoint, event.VenueFilters, openAt)
+		if err != nil {
+			log.Printf("Failed to find places for eventID=%s, so only suggested venues are voted on: %v", event.EventID, err)
+		}
+	} else {
+		log.Printf("No users gave a location for eventID=%s, so only suggested venues are voted on", event.EventID)
+	}
+
+	// Venues that users suggested are voted on along with the places that were found
+	venues := locations.MergeSuggestions(event.SuggestedVenues, found, locations.NearbyLimit)
+	// Places that are closed at every time option aren't voted on
+	venues = locations.WithOpeningHours(ctx, places.ForGuild(event.GuildID), venues)
+	venues = locations.OnlyOpen(venues, times)
+	// Not every provider can search for places that are open at a time,
+	// so the places' opening hours are checked for all of them
+	if !openAt.IsZero() {
+		venues = locations.OnlyOpenDuring(venues, chosen)
+	}
+	// Rank the venues by how long it takes everyone to get there
+	venues, travelMinutes := rankByTravelTime(ctx, router, event, venues)
+
+	if !hasMidpoint {
+		return venues, travelMinutes, nil
+	}
+	return venues, travelMinutes, &midpoint
+}
+
+// rankByTravelTime sorts the vote option locations by how long it takes the users to get to them,
+// returning the sorted locations and each user's travel time (in minutes) to each of them.
+// If the router fails, the travel times are estimated instead.
+func rankByTravelTime(ctx context.Context, router locations.Router, event types.Event, places []types.Location) ([]types.Location, map[string][]int) {
+	ranking, err := locations.RankByTravelTime(ctx, router, event.UserLocations, places, event.TravelMode)
+	if err != nil {
+		log.Printf("Failed to get travel times for eventID=%s, estimating them instead: %v", event.EventID, err)
+		ranking, err = locations.RankByTravelTime(ctx, &locations.EstimateRouter{}, event.UserLocations, places, event.TravelMode)
+		if err != nil {
+			log.Printf("Failed to estimate travel times for eventID=%s: %v", event.EventID, err)
+			return places, nil
+		}
+	}
+
+	travelMinutes := make(map[string][]int)
+	for userID, times := range ranking.UserTimes {
+		minutes := make([]int, len(times))
+		for i, travelTime := range times {
+			if travelTime == locations.NoRoute {
+				minutes[i] = -1
+			} else {
+				minutes[i] = int(math.Ceil(travelTime.Minutes()))
+			}
+		}
+		travelMinutes[userID] = minutes
+	}
+	return ranking.Locations, travelMinutes
+}
+
+// addTravelTimes fills in the travel times to the location at the index for the current user
+// and the totals over every user
+func addTravelTimes(location *GetVoteOptionsLocation, travelMinutes map[string][]int, userID string, index int) {
+	for travelUserID, minutes := range travelMinutes {
+		if index >= len(minutes) || minutes[index] < 0 {
+			continue
+		}
+		if travelUserID == userID {
+			userMinutes := minutes[index]
+			location.TravelMinutesFromCurrentUser = &userMinutes
+		}
+		location.TotalTravelMinutes += minutes[index]
+		if minutes[index] > location.WorstTravelMinutes {
+			location.WorstTravelMinutes = minutes[index]
+		}
+	}
+}
+
+// sharedMidpoint returns the event's midpoint if more than one user gave a location
+// (since the midpoint of a single user's location is where that user is)
+func sharedMidpoint(event types.Event) *types.Coordinates {
+	users := 0
+	for _, location := range event.UserLocations {
+		if locations.HasLocation(location) {
+			users++
+		}
+	}
+	if users < 2 {
+		return nil
+	}
+	return event.VoteOptions.Midpoint
+}
+
+// previewImage returns the link to the location's photo (through the photo proxy) or image.
+// Locations stored before the photo proxy had links to Google photos that include the API key,
+// which the database replaces with photo references; any that are left aren't sent to clients.
+func previewImage(location types.Location) string {
+	if location.Photo != "" {
+		return fmt.Sprintf(placePhotoURL, location.Provider, url.PathEscape(location.Photo))
+	}
+	if strings.Contains(location.Image, "key=") {
+		return ""
+	}
+	return location.Image
+}
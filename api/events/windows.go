@@ -0,0 +1,147 @@
+package events
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+)
+
+// eventWindows returns the windows in which the event can take place,
+// falling back to resolving them from the event's daily times
+// for events that were populated before windows were stored
+func eventWindows(event types.Event) []types.TimeWindow {
+	if len(event.Windows) > 0 {
+		return event.Windows
+	}
+
+	return resolveWindows(event)
+}
+
+// resolveWindows creates a window for each date between the earliest and latest date (inclusive).
+// Excluded dates are skipped, and each date uses the first of the following that is set:
+// - the event's window for that specific date
+// - the event's weekend/weekday window
+// - the event's daily start and end time
+func resolveWindows(event types.Event) []types.TimeWindow {
+	defaultWindow := types.DailyWindow{
+		StartHour:   event.StartTimeHour,
+		StartMinute: event.StartTimeMinute,
+		EndHour:     event.EndTimeHour,
+		EndMinute:   event.EndTimeMinute,
+	}
+
+	var windows []types.TimeWindow
+	for day := event.EarliestDate; !day.After(event.LatestDate); day = day.AddDate(0, 0, 1) {
+		if isExcluded(event.ExcludedDates, day) {
+			continue
+		}
+
+		window := defaultWindow
+		if dateWindow, ok := findDateWindow(event.DateWindows, day); ok {
+			window = dateWindow
+		} else if isWeekend(day) && event.WeekendWindow != nil {
+			window = *event.WeekendWindow
+		} else if !isWeekend(day) && event.WeekdayWindow != nil {
+			window = *event.WeekdayWindow
+		}
+		windows = append(windows, windowOnDate(day, window))
+	}
+	return windows
+}
+
+// windowOnDate creates a single window starting on the given date.
+// If the end time is not after the start time, the window ends on the following day
+// (so 21:00 - 1:00 is a late-night window and 0:00 - 0:00 is the entire day).
+func windowOnDate(date time.Time, window types.DailyWindow) types.TimeWindow {
+	year, month, day := date.Date()
+	start := time.Date(year, month, day, window.StartHour, window.StartMinute, 0, 0, date.Location())
+	end := time.Date(year, month, day, window.EndHour, window.EndMinute, 0, 0, date.Location())
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return types.TimeWindow{Start: start, End: end}
+}
+
+// windowForDate finds the window that starts within the 24 hours after date
+func windowForDate(windows []types.TimeWindow, date time.Time) (types.TimeWindow, bool) {
+	for _, window := range windows {
+		if !window.Start.Before(date) && window.Start.Before(date.Add(24*time.Hour)) {
+			return window, true
+		}
+	}
+	return types.TimeWindow{}, false
+}
+
+// validateDefaultWindow records any problems with the event's daily start and end time.
+// The end can be before the start (for windows that end on the following day),
+// but a window can't start and end at the same time, other than 0:00 - 0:00 for the entire day.
+func validateDefaultWindow(validationError *util.ValidationError, event types.Event) {
+	if event.StartTimeHour < 0 || event.StartTimeHour > 23 {
+		validationError.Add("start_time_hour", "hour %d must be between 0 and 23", event.StartTimeHour)
+	}
+	if event.StartTimeMinute < 0 || event.StartTimeMinute > 59 {
+		validationError.Add("start_time_minute", "minute %d must be between 0 and 59", event.StartTimeMinute)
+	}
+	if event.EndTimeHour < 0 || event.EndTimeHour > 23 {
+		validationError.Add("end_time_hour", "hour %d must be between 0 and 23", event.EndTimeHour)
+	}
+	if event.EndTimeMinute < 0 || event.EndTimeMinute > 59 {
+		validationError.Add("end_time_minute", "minute %d must be between 0 and 59", event.EndTimeMinute)
+	}
+
+	start := event.StartTimeHour*60 + event.StartTimeMinute
+	end := event.EndTimeHour*60 + event.EndTimeMinute
+	if start == end && start != 0 {
+		validationError.Add("end_time_hour", "the end time %02d:%02d must be different from the start time",
+			event.EndTimeHour, event.EndTimeMinute)
+	}
+}
+
+// validateWindowRules records any problems with the per-weekday and per-date windows of the event
+func validateWindowRules(validationError *util.ValidationError, event types.Event) {
+	if event.WeekdayWindow != nil {
+		util.ValidateDailyWindow(validationError, "weekday_window", *event.WeekdayWindow)
+	}
+	if event.WeekendWindow != nil {
+		util.ValidateDailyWindow(validationError, "weekend_window", *event.WeekendWindow)
+	}
+	for i, dateWindow := range event.DateWindows {
+		field := fmt.Sprintf("date_windows[%d]", i)
+		if dateWindow.Date.Before(event.EarliestDate) || dateWindow.Date.After(event.LatestDate) {
+			validationError.Add(field+".date", "date %s is outside of the event's range",
+				dateWindow.Date.Format("2006-01-02"))
+		}
+		util.ValidateDailyWindow(validationError, field, dateWindow.DailyWindow)
+	}
+}
+
+func findDateWindow(dateWindows []types.DateWindow, date time.Time) (types.DailyWindow, bool) {
+	for _, dateWindow := range dateWindows {
+		if sameDate(dateWindow.Date, date) {
+			return dateWindow.DailyWindow, true
+		}
+	}
+	return types.DailyWindow{}, false
+}
+
+func isExcluded(excludedDates []time.Time, date time.Time) bool {
+	for _, excluded := range excludedDates {
+		if sameDate(excluded, date) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWeekend(date time.Time) bool {
+	return date.Weekday() == time.Saturday || date.Weekday() == time.Sunday
+}
+
+// sameDate compares the calendar dates of a and b (each in their own time zone)
+func sameDate(a time.Time, b time.Time) bool {
+	aYear, aMonth, aDay := a.Date()
+	bYear, bMonth, bDay := b.Date()
+	return aYear == bYear && aMonth == bMonth && aDay == bDay
+}
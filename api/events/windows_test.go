@@ -0,0 +1,75 @@
+package events
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+)
+
+func TestResolveWindows(t *testing.T) {
+	// Friday through Monday
+	fri := time.Date(2022, time.March, 4, 0, 0, 0, 0, time.UTC)
+	sat := fri.AddDate(0, 0, 1)
+	sun := fri.AddDate(0, 0, 2)
+	mon := fri.AddDate(0, 0, 3)
+
+	event := types.Event{
+		EarliestDate:  fri,
+		LatestDate:    mon,
+		StartTimeHour: 18,
+		EndTimeHour:   22,
+		WeekendWindow: &types.DailyWindow{StartHour: 12, EndHour: 1},
+		DateWindows: []types.DateWindow{
+			{Date: mon, DailyWindow: types.DailyWindow{StartHour: 19, StartMinute: 30, EndHour: 21}},
+		},
+		ExcludedDates: []time.Time{sun},
+	}
+
+	expected := []types.TimeWindow{
+		{Start: fri.Add(18 * time.Hour), End: fri.Add(22 * time.Hour)},
+		{Start: sat.Add(12 * time.Hour), End: sat.Add(25 * time.Hour)},
+		{Start: mon.Add(19*time.Hour + 30*time.Minute), End: mon.Add(21 * time.Hour)},
+	}
+
+	windows := resolveWindows(event)
+	if len(windows) != len(expected) {
+		t.Fatalf("expected %d windows, got %+v", len(expected), windows)
+	}
+	for i := range expected {
+		if !windows[i].Start.Equal(expected[i].Start) || !windows[i].End.Equal(expected[i].End) {
+			t.Errorf("window %d: expected %v - %v, got %v - %v", i,
+				expected[i].Start, expected[i].End, windows[i].Start, windows[i].End)
+		}
+	}
+}
+
+func TestValidateDefaultWindow(t *testing.T) {
+	tests := []struct {
+		event         types.Event
+		invalidFields []string
+	}{
+		{types.Event{StartTimeHour: 18, EndTimeHour: 22}, nil},
+		// Late-night windows and the entire day are allowed
+		{types.Event{StartTimeHour: 21, EndTimeHour: 1}, nil},
+		{types.Event{}, nil},
+		{types.Event{StartTimeHour: 25, EndTimeHour: 22, EndTimeMinute: 60}, []string{"start_time_hour", "end_time_minute"}},
+		{types.Event{StartTimeHour: 18, EndTimeHour: -1}, []string{"end_time_hour"}},
+		{types.Event{StartTimeHour: 18, EndTimeHour: 18}, []string{"end_time_hour"}},
+	}
+	for _, test := range tests {
+		validationError := &util.ValidationError{}
+		validateDefaultWindow(validationError, test.event)
+		var fields []string
+		for _, field := range validationError.Fields {
+			fields = append(fields, field.Field)
+		}
+		if strings.Join(fields, ",") != strings.Join(test.invalidFields, ",") {
+			t.Errorf("%02d:%02d - %02d:%02d: invalid fields = %v, want %v",
+				test.event.StartTimeHour, test.event.StartTimeMinute, test.event.EndTimeHour, test.event.EndTimeMinute,
+				fields, test.invalidFields)
+		}
+	}
+}
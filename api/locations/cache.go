@@ -0,0 +1,254 @@
+package locations
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/db"
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+const (
+	// Searches are cached for this long unless PLACE_CACHE_TTL is set
+	defaultPlaceCacheTTL = 24 * time.Hour
+	// At most this many searches are kept in memory unless PLACE_CACHE_SIZE is set
+	defaultPlaceCacheSize = 500
+	// Search centers are rounded to this many decimal places (about 110 meters),
+	// so that searches around nearby midpoints share results
+	placeCachePrecision = 3
+)
+
+// PlaceCache keeps the results of place searches for a while,
+// in memory and optionally in a cache shared between servers
+type PlaceCache struct {
+	ttl     time.Duration
+	maxSize int
+	// If nil, then searches are only cached in memory
+	shared db.PlaceCacheProvider
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	hits    int
+	misses  int
+}
+
+type placeCacheEntry struct {
+	key       string
+	locations []types.Location
+	expiresAt time.Time
+}
+
+// NewPlaceCache creates a cache that keeps up to maxSize searches in memory for the TTL.
+// If shared is not nil, then searches are also shared through it.
+func NewPlaceCache(ttl time.Duration, maxSize int, shared db.PlaceCacheProvider) *PlaceCache {
+	return &PlaceCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		shared:  shared,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// NewPlaceCacheFromEnv creates a cache from the environment:
+// PLACE_CACHE_TTL is how long searches are cached for ("24h" if not set, or "0" to not cache them),
+// PLACE_CACHE_SIZE is how many searches are kept in memory (500 if not set),
+// and PLACE_CACHE_SHARED is "true" to also share searches through the database.
+// It returns nil if searches aren't cached.
+func NewPlaceCacheFromEnv(database db.PlaceCacheProvider) (*PlaceCache, error) {
+	ttl := defaultPlaceCacheTTL
+	if value, ok := os.LookupEnv("PLACE_CACHE_TTL"); ok && value != "" {
+		var err error
+		ttl, err = time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("invalid PLACE_CACHE_TTL '%s' (expected a duration such as '24h')", value)
+		}
+	}
+	if ttl == 0 {
+		return nil, nil
+	}
+
+	size := defaultPlaceCacheSize
+	if value, ok := os.LookupEnv("PLACE_CACHE_SIZE"); ok && value != "" {
+		var err error
+		size, err = strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid PLACE_CACHE_SIZE '%s' (expected a number of searches)", value)
+		}
+	}
+
+	var shared db.PlaceCacheProvider
+	if os.Getenv("PLACE_CACHE_SHARED") == "true" {
+		shared = database
+	}
+	return NewPlaceCache(ttl, size, shared), nil
+}
+
+// Wrap returns a provider that caches the searches of the provider with the name
+func (c *PlaceCache) Wrap(name string, provider PlaceProvider) *CachingProvider {
+	return &CachingProvider{name: name, provider: provider, cache: c}
+}
+
+// Stats returns how many searches were found in the cache and how many weren't
+func (c *PlaceCache) Stats() (hits int, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+func (c *PlaceCache) get(ctx context.Context, key string) ([]types.Location, bool) {
+	c.mu.Lock()
+	element, ok := c.entries[key]
+	if ok {
+		entry := element.Value.(*placeCacheEntry)
+		if time.Now().Before(entry.expiresAt) {
+			c.order.MoveToFront(element)
+			c.hits++
+			c.logLocked("hit", "memory")
+			c.mu.Unlock()
+			return entry.locations, true
+		}
+		c.removeLocked(element)
+	}
+	c.mu.Unlock()
+
+	if c.shared != nil {
+		locations, expiresAt, ok, err := c.shared.GetCachedPlaces(ctx, key)
+		if err != nil {
+			log.Printf("Failed to get places from the shared cache: %v", err)
+		} else if ok {
+			// The search might have been cached a while ago,
+			// so it is only kept in memory until it expires from the shared cache
+			c.addLocal(key, locations, expiresAt)
+			c.mu.Lock()
+			c.hits++
+			c.logLocked("hit", "shared")
+			c.mu.Unlock()
+			return locations, true
+		}
+	}
+
+	c.mu.Lock()
+	c.misses++
+	c.logLocked("miss", "")
+	c.mu.Unlock()
+	return nil, false
+}
+
+func (c *PlaceCache) add(ctx context.Context, key string, locations []types.Location) {
+	expiresAt := time.Now().Add(c.ttl)
+	c.addLocal(key, locations, expiresAt)
+	if c.shared != nil {
+		err := c.shared.PutCachedPlaces(ctx, key, locations, expiresAt)
+		if err != nil {
+			log.Printf("Failed to add places to the shared cache: %v", err)
+		}
+	}
+}
+
+func (c *PlaceCache) addLocal(key string, locations []types.Location, expiresAt time.Time) {
+	if c.maxSize == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.removeLocked(element)
+	}
+	for c.order.Len() >= c.maxSize {
+		c.removeLocked(c.order.Back())
+	}
+	c.entries[key] = c.order.PushFront(&placeCacheEntry{key: key, locations: locations, expiresAt: expiresAt})
+}
+
+func (c *PlaceCache) removeLocked(element *list.Element) {
+	entry := c.order.Remove(element).(*placeCacheEntry)
+	delete(c.entries, entry.key)
+}
+
+// logLocked logs the result of a lookup along with the running totals.
+// The key isn't logged since it has the (rounded) midpoint of the users' locations.
+func (c *PlaceCache) logLocked(result string, source string) {
+	if source != "" {
+		result += " (" + source + ")"
+	}
+	log.Printf("Place search cache %s: hits=%d misses=%d", result, c.hits, c.misses)
+}
+
+// CachingProvider is a place provider that caches the searches of another provider
+type CachingProvider struct {
+	name     string
+	provider PlaceProvider
+	cache    *PlaceCache
+}
+
+// Nearby searches around the center rounded to about 110 meters
+// (for places open at the start of the hour, if the query has a time),
+// returning the cached places if the same search was made recently
+func (p *CachingProvider) Nearby(ctx context.Context, query PlaceQuery) ([]types.Location, error) {
+	query = p.roundedQuery(query)
+	key := p.key(query)
+	if locations, ok := p.cache.get(ctx, key); ok {
+		return append([]types.Location{}, locations...), nil
+	}
+
+	locations, err := p.provider.Nearby(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	p.cache.add(ctx, key, append([]types.Location{}, locations...))
+	return locations, nil
+}
+
+// roundedQuery rounds the query's center (and time), so that similar searches share results
+func (p *CachingProvider) roundedQuery(query PlaceQuery) PlaceQuery {
+	query.Center = types.Coordinates{
+		Latitude:  roundTo(query.Center.Latitude, placeCachePrecision),
+		Longitude: roundTo(query.Center.Longitude, placeCachePrecision),
+	}
+	// Places open at times in the same hour are treated as the same
+	if !query.OpenAt.IsZero() {
+		query.OpenAt = query.OpenAt.UTC().Truncate(time.Hour)
+	}
+	return query
+}
+
+// Unwrap returns the provider whose searches are cached
+func (p *CachingProvider) Unwrap() PlaceProvider {
+	return p.provider
+}
+
+// key identifies the search, including everything in the query that changes its results
+func (p *CachingProvider) key(query PlaceQuery) string {
+	openAt := ""
+	if !query.OpenAt.IsZero() {
+		openAt = query.OpenAt.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%s|%.*f,%.*f|%s|%d|%d|%s|%d|%s",
+		p.name, placeCachePrecision, query.Center.Latitude, placeCachePrecision, query.Center.Longitude,
+		query.Category, query.Radius, query.Limit, query.Keyword, query.MaxPriceLevel, openAt)
+}
+
+// Unwrap returns the provider that a CachingProvider wraps
+// (or the provider itself if it isn't one),
+// which is used to find out what else the provider can do (such as looking up opening hours)
+func Unwrap(provider PlaceProvider) PlaceProvider {
+	if caching, ok := provider.(*CachingProvider); ok {
+		return caching.provider
+	}
+	return provider
+}
+
+func roundTo(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
@@ -0,0 +1,176 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+// memoryPlaceCache is a shared cache that keeps the places in a map
+type memoryPlaceCache struct {
+	places    map[string][]types.Location
+	expiresAt map[string]time.Time
+	gets      int
+}
+
+func newMemoryPlaceCache() *memoryPlaceCache {
+	return &memoryPlaceCache{places: make(map[string][]types.Location), expiresAt: make(map[string]time.Time)}
+}
+
+func (c *memoryPlaceCache) GetCachedPlaces(ctx context.Context, key string) ([]types.Location, time.Time, bool, error) {
+	c.gets++
+	places, ok := c.places[key]
+	return places, c.expiresAt[key], ok, nil
+}
+
+func (c *memoryPlaceCache) PutCachedPlaces(ctx context.Context, key string, places []types.Location, expiresAt time.Time) error {
+	c.places[key] = places
+	c.expiresAt[key] = expiresAt
+	return nil
+}
+
+func cacheQuery(latitude float64, category string) PlaceQuery {
+	return PlaceQuery{
+		Center:   types.Coordinates{Latitude: latitude, Longitude: -84.3963},
+		Radius:   1500,
+		Category: category,
+		Limit:    10,
+	}
+}
+
+func TestCachingProvider(t *testing.T) {
+	fake := &FakeProvider{}
+	cache := NewPlaceCache(time.Hour, 2, nil)
+	provider := cache.Wrap(ProviderFake, fake)
+	ctx := context.Background()
+
+	first, err := provider.Nearby(ctx, cacheQuery(33.77561, CategoryRestaurant))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A midpoint a few meters away rounds to the same search
+	second, err := provider.Nearby(ctx, cacheQuery(33.77559, CategoryRestaurant))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Queries) != 1 {
+		t.Errorf("made %d searches, want 1", len(fake.Queries))
+	}
+	if fake.Queries[0].Center.Latitude != 33.776 {
+		t.Errorf("searched around latitude %v, want the rounded 33.776", fake.Queries[0].Center.Latitude)
+	}
+	if len(first) != len(second) || first[0] != second[0] {
+		t.Errorf("cached places = %v, want %v", second, first)
+	}
+
+	// Other categories are searched separately,
+	// and the least recently used search is evicted once there are too many
+	provider.Nearby(ctx, cacheQuery(33.7756, CategoryPark))
+	provider.Nearby(ctx, cacheQuery(33.7756, CategoryBar))
+	provider.Nearby(ctx, cacheQuery(33.7756, CategoryRestaurant))
+	if len(fake.Queries) != 4 {
+		t.Errorf("made %d searches, want 4", len(fake.Queries))
+	}
+	if hits, misses := cache.Stats(); hits != 1 || misses != 4 {
+		t.Errorf("hits = %d and misses = %d, want 1 and 4", hits, misses)
+	}
+
+	// Failed searches aren't cached
+	fake.Err = errors.New("over the quota")
+	if _, err := provider.Nearby(ctx, cacheQuery(40, CategoryRestaurant)); err == nil {
+		t.Errorf("expected the search to fail")
+	}
+	fake.Err = nil
+	provider.Nearby(ctx, cacheQuery(40, CategoryRestaurant))
+	if len(fake.Queries) != 6 {
+		t.Errorf("made %d searches, want 6", len(fake.Queries))
+	}
+}
+
+func TestCachingProviderExpires(t *testing.T) {
+	fake := &FakeProvider{}
+	provider := NewPlaceCache(time.Millisecond, 10, nil).Wrap(ProviderFake, fake)
+
+	provider.Nearby(context.Background(), cacheQuery(33.7756, CategoryRestaurant))
+	time.Sleep(5 * time.Millisecond)
+	provider.Nearby(context.Background(), cacheQuery(33.7756, CategoryRestaurant))
+	if len(fake.Queries) != 2 {
+		t.Errorf("made %d searches, want 2 after the cached search expired", len(fake.Queries))
+	}
+}
+
+func TestCachingProviderShared(t *testing.T) {
+	shared := newMemoryPlaceCache()
+	fake := &FakeProvider{}
+
+	// Another server made the search
+	NewPlaceCache(time.Hour, 10, shared).Wrap(ProviderFake, fake).
+		Nearby(context.Background(), cacheQuery(33.7756, CategoryRestaurant))
+
+	cache := NewPlaceCache(time.Hour, 10, shared)
+	provider := cache.Wrap(ProviderFake, fake)
+	for i := 0; i < 2; i++ {
+		places, err := provider.Nearby(context.Background(), cacheQuery(33.7756, CategoryRestaurant))
+		if err != nil || len(places) != 3 {
+			t.Errorf("places = %v (err = %v), want the shared places", places, err)
+		}
+	}
+	if len(fake.Queries) != 1 {
+		t.Errorf("made %d searches, want 1", len(fake.Queries))
+	}
+	// The second lookup is found in memory
+	if shared.gets != 2 {
+		t.Errorf("looked in the shared cache %d times, want 2", shared.gets)
+	}
+	if hits, misses := cache.Stats(); hits != 2 || misses != 0 {
+		t.Errorf("hits = %d and misses = %d, want 2 and 0", hits, misses)
+	}
+
+	// Searches from other providers aren't shared
+	NewPlaceCache(time.Hour, 10, shared).Wrap(ProviderOverpass, fake).
+		Nearby(context.Background(), cacheQuery(33.7756, CategoryRestaurant))
+	if len(fake.Queries) != 2 {
+		t.Errorf("made %d searches, want 2", len(fake.Queries))
+	}
+}
+
+func TestCachingProviderSharedExpiry(t *testing.T) {
+	shared := newMemoryPlaceCache()
+	fake := &FakeProvider{}
+	query := cacheQuery(33.7756, CategoryRestaurant)
+
+	// Another server made the search, which has almost expired
+	other := NewPlaceCache(time.Hour, 10, shared).Wrap(ProviderFake, fake)
+	other.Nearby(context.Background(), query)
+	key := other.key(other.roundedQuery(query))
+	shared.expiresAt[key] = time.Now().Add(5 * time.Millisecond)
+
+	cache := NewPlaceCache(time.Hour, 10, shared)
+	provider := cache.Wrap(ProviderFake, fake)
+	provider.Nearby(context.Background(), query)
+	// It expires from memory when it expires from the shared cache, rather than an hour from now
+	time.Sleep(10 * time.Millisecond)
+	delete(shared.places, key)
+	provider.Nearby(context.Background(), query)
+	if len(fake.Queries) != 2 {
+		t.Errorf("made %d searches, want 2 after the shared search expired", len(fake.Queries))
+	}
+}
+
+func TestCachingProviderRoundsOpenAt(t *testing.T) {
+	fake := &FakeProvider{}
+	provider := NewPlaceCache(time.Hour, 10, nil).Wrap(ProviderFake, fake)
+
+	for _, minute := range []int{10, 50} {
+		query := cacheQuery(33.7756, CategoryRestaurant)
+		query.OpenAt = time.Date(2022, time.March, 4, 18, minute, 0, 0, time.UTC)
+		provider.Nearby(context.Background(), query)
+	}
+	// Both times are searched for as the start of the hour, so the second search is cached
+	if len(fake.Queries) != 1 || !fake.Queries[0].OpenAt.Equal(time.Date(2022, time.March, 4, 18, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected one search for places open at 18:00, got %+v", fake.Queries)
+	}
+}
@@ -0,0 +1,59 @@
+package locations
+
+import (
+	"context"
+	"sync"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+// FakeProvider is a place provider that doesn't make any requests,
+// for tests and local development without API keys
+type FakeProvider struct {
+	// Returned for every query (up to its limit).
+	// If nil, then a few places around the query's center are made up
+	Locations []types.Location
+	// Maps place ID => the opening hours of the place
+	// (the hours of places that aren't in it are unknown)
+	Hours map[string]*types.OpeningHours
+	// If set, then every query fails with it
+	Err error
+	// The queries it received
+	Queries []PlaceQuery
+
+	mu sync.Mutex
+}
+
+func (p *FakeProvider) Nearby(ctx context.Context, query PlaceQuery) ([]types.Location, error) {
+	p.mu.Lock()
+	p.Queries = append(p.Queries, query)
+	p.mu.Unlock()
+	if p.Err != nil {
+		return nil, p.Err
+	}
+
+	locations := p.Locations
+	if locations == nil {
+		locations = []types.Location{
+			{Name: "Fake Pizza", Address: "1 Main St", Rating: 4.5, Latitude: query.Center.Latitude + 0.001, Longitude: query.Center.Longitude},
+			{Name: "Fake Tacos", Address: "2 Main St", Rating: 4, Latitude: query.Center.Latitude, Longitude: query.Center.Longitude + 0.001},
+			{Name: "Fake Noodles", Address: "3 Main St", Rating: 3.5, Latitude: query.Center.Latitude - 0.001, Longitude: query.Center.Longitude},
+		}
+		for i := range locations {
+			locations[i].Provider = ProviderFake
+			locations[i].PlaceID = locations[i].Name
+		}
+	}
+
+	if query.Limit > 0 && len(locations) > query.Limit {
+		locations = locations[:query.Limit]
+	}
+	return append([]types.Location{}, locations...), nil
+}
+
+func (p *FakeProvider) OpeningHours(ctx context.Context, location types.Location) (*types.OpeningHours, error) {
+	if location.Provider != ProviderFake {
+		return nil, nil
+	}
+	return p.Hours[location.PlaceID], nil
+}
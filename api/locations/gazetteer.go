@@ -0,0 +1,83 @@
+package locations
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+// GazetteerGeocoder looks up addresses in a fixed list of places without making any requests,
+// for tests and local development
+type GazetteerGeocoder struct {
+	Places []GazetteerPlace
+}
+
+// GazetteerPlace is a named place in a gazetteer
+type GazetteerPlace struct {
+	// Such as "30332" or "Georgia Tech, Atlanta"
+	Name        string
+	Coordinates types.Coordinates
+}
+
+// LoadGazetteer reads a gazetteer from a CSV file
+// with a name,latitude,longitude row for each place
+func LoadGazetteer(r io.Reader) (*GazetteerGeocoder, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid gazetteer: %w", err)
+	}
+
+	gazetteer := &GazetteerGeocoder{}
+	for i, record := range records {
+		latitude, latErr := strconv.ParseFloat(record[1], 64)
+		longitude, lonErr := strconv.ParseFloat(record[2], 64)
+		if latErr != nil || lonErr != nil {
+			return nil, fmt.Errorf("invalid coordinates for gazetteer place %d (%s)", i+1, record[0])
+		}
+		gazetteer.Places = append(gazetteer.Places, GazetteerPlace{
+			Name:        record[0],
+			Coordinates: types.Coordinates{Latitude: latitude, Longitude: longitude},
+		})
+	}
+	return gazetteer, nil
+}
+
+// Geocode matches the address against the places' names, ignoring case.
+// Exact matches are certain, while places whose names contain the address
+// (or the other way around) are partial matches.
+func (g *GazetteerGeocoder) Geocode(ctx context.Context, address string) ([]GeocodeResult, error) {
+	query := strings.ToLower(strings.TrimSpace(address))
+	if query == "" {
+		return []GeocodeResult{}, nil
+	}
+
+	results := []GeocodeResult{}
+	for _, place := range g.Places {
+		name := strings.ToLower(place.Name)
+		confidence := 0.0
+		switch {
+		case name == query:
+			confidence = 1
+		case strings.Contains(name, query) || strings.Contains(query, name):
+			confidence = 0.5
+		default:
+			continue
+		}
+		results = append(results, GeocodeResult{
+			Label:       place.Name,
+			Coordinates: place.Coordinates,
+			Confidence:  confidence,
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Confidence > results[j].Confidence })
+	return results, nil
+}
@@ -0,0 +1,106 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/3-brain-cells/sah-backend/env"
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+const (
+	GeocoderNominatim = "nominatim"
+	GeocoderGoogle    = "google"
+	GeocoderGazetteer = "gazetteer"
+
+	// At most this many candidates are returned for an uncertain match
+	maxGeocodeCandidates = 5
+	// The best match is only used if it is this much more confident than the next best match
+	geocodeConfidenceMargin = 0.2
+)
+
+// ErrNoGeocodeResults is returned when nothing matches an address
+var ErrNoGeocodeResults = errors.New("no places match the address")
+
+// Geocoder finds the coordinates of free-text addresses (such as a street address or postal code)
+type Geocoder interface {
+	// Geocode returns the places that match the address, best match first
+	Geocode(ctx context.Context, address string) ([]GeocodeResult, error)
+}
+
+// GeocodeResult is a place that matches an address
+type GeocodeResult struct {
+	// Describes the place for users to check it is the right one,
+	// such as "123 Main St, Atlanta, GA 30332, USA"
+	Label       string            `json:"label"`
+	Coordinates types.Coordinates `json:"coordinates"`
+	// From 0 to 1, how closely the place matches the address
+	Confidence float64 `json:"confidence"`
+}
+
+// Location converts the result to a user's location
+func (r GeocodeResult) Location() types.UserLocation {
+	return types.UserLocation{
+		Latitude:  r.Coordinates.Latitude,
+		Longitude: r.Coordinates.Longitude,
+		Label:     r.Label,
+	}
+}
+
+// Resolve geocodes the address, returning the match if it is certain,
+// or the candidates for the user to choose from if it isn't
+// (with the first return value set to nil)
+func Resolve(ctx context.Context, geocoder Geocoder, address string) (*GeocodeResult, []GeocodeResult, error) {
+	results, err := geocoder.Geocode(ctx, address)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil, ErrNoGeocodeResults
+	}
+	if len(results) == 1 || results[0].Confidence-results[1].Confidence >= geocodeConfidenceMargin {
+		return &results[0], nil, nil
+	}
+
+	if len(results) > maxGeocodeCandidates {
+		results = results[:maxGeocodeCandidates]
+	}
+	return nil, results, nil
+}
+
+// NewGeocoderFromEnv creates the geocoder named by GEOCODER ("nominatim" if not set)
+func NewGeocoderFromEnv() (Geocoder, error) {
+	name := GeocoderNominatim
+	if value, ok := os.LookupEnv("GEOCODER"); ok && value != "" {
+		name = value
+	}
+
+	switch name {
+	case GeocoderNominatim:
+		// The public Nominatim instance is used if no URL is set
+		url, _ := env.GetEnv("Nominatim URL", "NOMINATIM_URL")
+		return &NominatimGeocoder{URL: url}, nil
+	case GeocoderGoogle:
+		apiKey, err := env.GetEnv("Google API key", "GOOGLE_API_KEY")
+		if err != nil {
+			return nil, err
+		}
+		return &GoogleGeocoder{APIKey: apiKey}, nil
+	case GeocoderGazetteer:
+		path, err := env.GetEnv("gazetteer file", "GAZETTEER_FILE")
+		if err != nil {
+			return nil, err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gazetteer: %w", err)
+		}
+		defer file.Close()
+		return LoadGazetteer(file)
+	default:
+		return nil, fmt.Errorf("unknown geocoder '%s' (expected one of '%s', '%s' or '%s')",
+			name, GeocoderNominatim, GeocoderGoogle, GeocoderGazetteer)
+	}
+}
@@ -0,0 +1,125 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testGazetteer = `30332, 33.7766, -84.3981
+"Springfield, IL", 39.7817, -89.6501
+"Springfield, MA", 42.1015, -72.5898
+`
+
+func TestResolveGazetteer(t *testing.T) {
+	gazetteer, err := LoadGazetteer(strings.NewReader(testGazetteer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, candidates, err := Resolve(context.Background(), gazetteer, " 30332 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil || result.Label != "30332" || result.Coordinates.Latitude != 33.7766 || candidates != nil {
+		t.Errorf("expected the postal code to be matched, got %+v and %+v", result, candidates)
+	}
+
+	result, candidates, err = Resolve(context.Background(), gazetteer, "springfield")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != nil || len(candidates) != 2 {
+		t.Errorf("expected both Springfields to be candidates, got %+v and %+v", result, candidates)
+	}
+
+	// An exact match is more certain than the partial match
+	result, _, err = Resolve(context.Background(), gazetteer, "Springfield, MA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil || result.Coordinates.Longitude != -72.5898 {
+		t.Errorf("expected the exact match, got %+v", result)
+	}
+
+	_, _, err = Resolve(context.Background(), gazetteer, "Atlantis")
+	if !errors.Is(err, ErrNoGeocodeResults) {
+		t.Errorf("expected no results, got %v", err)
+	}
+}
+
+func TestNominatimGeocoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != nominatimUserAgent {
+			t.Errorf("unexpected request %s", r.URL.RawQuery)
+		}
+		if r.URL.Query().Get("q") == "Main St, 30332" {
+			// The town is more important, but the street is a closer match
+			io.WriteString(w, `[
+				{"display_name": "Atlanta, Georgia, United States", "lat": "33.7490", "lon": "-84.3880", "importance": 0.8, "place_rank": 16, "addresstype": "city"},
+				{"display_name": "Main Street, Atlanta, Georgia, 30332, United States", "lat": "33.7760", "lon": "-84.3900", "importance": 0.3, "place_rank": 26, "addresstype": "road"}
+			]`)
+			return
+		}
+		io.WriteString(w, `[
+			{"display_name": "123, Main Street, Atlanta, Georgia, 30332, United States", "lat": "33.7765", "lon": "-84.3898", "importance": 0.61, "place_rank": 30, "addresstype": "house"},
+			{"display_name": "123, Main Street, Athens, Georgia, 30601, United States", "lat": "33.9519", "lon": "-83.3576", "importance": 0.08, "place_rank": 30, "addresstype": "house"}
+		]`)
+	}))
+	defer server.Close()
+
+	geocoder := &NominatimGeocoder{URL: server.URL}
+	result, candidates, err := Resolve(context.Background(), geocoder, "123 Main St")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both houses match exactly, however much less known one is
+	if result != nil || len(candidates) != 2 || candidates[1].Coordinates.Latitude != 33.9519 {
+		t.Errorf("expected 2 candidates, got %+v and %+v", result, candidates)
+	}
+
+	result, _, err = Resolve(context.Background(), geocoder, "Main St, 30332")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil || result.Coordinates.Latitude != 33.7760 {
+		t.Errorf("expected the street to be certain, got %+v", result)
+	}
+}
+
+func TestGoogleGeocoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "test-key" {
+			io.WriteString(w, `{"results": [], "status": "REQUEST_DENIED", "error_message": "The provided API key is invalid."}`)
+			return
+		}
+		io.WriteString(w, `{"results": [
+			{"formatted_address": "North Ave NW, Atlanta, GA 30332, USA", "geometry": {"location": {"lat": 33.7713, "lng": -84.3917}, "location_type": "ROOFTOP"}},
+			{"formatted_address": "North Ave, Atlanta, GA, USA", "partial_match": true, "geometry": {"location": {"lat": 33.7710, "lng": -84.3800}, "location_type": "GEOMETRIC_CENTER"}}
+		], "status": "OK"}`)
+	}))
+	defer server.Close()
+
+	geocoder := &GoogleGeocoder{APIKey: "test-key", BaseURL: server.URL}
+	result, _, err := Resolve(context.Background(), geocoder, "North Ave, 30332")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil || result.Label != "North Ave NW, Atlanta, GA 30332, USA" {
+		t.Errorf("expected the rooftop match to be certain, got %+v", result)
+	}
+	if location := result.Location(); location.Label != result.Label || location.Latitude != 33.7713 {
+		t.Errorf("expected the location to have the label, got %+v", location)
+	}
+
+	geocoder.APIKey = "wrong"
+	_, err = geocoder.Geocode(context.Background(), "North Ave")
+	var googleError *GoogleError
+	if !errors.As(err, &googleError) || googleError.Status != "REQUEST_DENIED" {
+		t.Errorf("expected a REQUEST_DENIED error, got %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+package locations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+const (
+	googleNearbySearchURL = "https://maps.googleapis.com/maps/api/place/nearbysearch/json"
+	googlePlaceDetailsURL = "https://maps.googleapis.com/maps/api/place/details/json"
+	// Google returns at most 3 pages (of 20 results each)
+	googleMaxPages = 3
+	// How long it takes for a next_page_token to become valid
+	googlePageTokenDelay = 2 * time.Second
+	// How many times to retry a page whose token isn't valid yet
+	googlePageTokenRetries = 3
+)
+
+var googleLocationParam = regexp.MustCompile(`location=[^&\s]*`)
+
+// GoogleProvider finds places with the Google Places Nearby Search API
+type GoogleProvider struct {
+	APIKey string
+	// If nil, then a client with a 10 second timeout is used
+	HTTPClient *http.Client
+	// If empty, then the Google Places API is used
+	BaseURL string
+	// If empty, then the Google Place Details API is used (to look up opening hours)
+	DetailsURL string
+	// If empty, then the Google Place Photos API is used
+	PhotoURL string
+	// How long to wait before requesting the next page.
+	// If 0, then 2 seconds (which is how long Google takes to make the token valid)
+	PageTokenDelay time.Duration
+	// If set, then requests and responses are logged (with the API key and coordinates removed)
+	Debug bool
+}
+
+// GoogleError is an error status returned by the Google Places API
+// (such as OVER_QUERY_LIMIT or REQUEST_DENIED)
+type GoogleError struct {
+	Status  string
+	Message string
+}
+
+func (e *GoogleError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("google places search failed with status %s", e.Status)
+	}
+	return fmt.Sprintf("google places search failed with status %s: %s", e.Status, e.Message)
+}
+
+type googleNearbyResponse struct {
+	Results       []googlePlace `json:"results"`
+	Status        string        `json:"status"`
+	ErrorMessage  string        `json:"error_message"`
+	NextPageToken string        `json:"next_page_token"`
+}
+
+type googlePlace struct {
+	PlaceID          string  `json:"place_id"`
+	Name             string  `json:"name"`
+	Vicinity         string  `json:"vicinity"`
+	Icon             string  `json:"icon"`
+	Rating           float64 `json:"rating"`
+	UserRatingsTotal int     `json:"user_ratings_total"`
+	PriceLevel       int     `json:"price_level"`
+	BusinessStatus   string  `json:"business_status"`
+	Photos           []struct {
+		PhotoReference string `json:"photo_reference"`
+		Width          int    `json:"width"`
+		Height         int    `json:"height"`
+	} `json:"photos"`
+	Geometry struct {
+		Location struct {
+			Lat float64 `json:"lat"`
+			Lng float64 `json:"lng"`
+		} `json:"location"`
+	} `json:"geometry"`
+}
+
+func (p *GoogleProvider) Nearby(ctx context.Context, query PlaceQuery) ([]types.Location, error) {
+	params := url.Values{}
+	params.Set("location", fmt.Sprintf("%v,%v", query.Center.Latitude, query.Center.Longitude))
+	params.Set("radius", fmt.Sprint(query.Radius))
+	params.Set("type", query.Category)
+	if query.Keyword != "" {
+		params.Set("keyword", query.Keyword)
+	}
+	if query.MaxPriceLevel > 0 {
+		params.Set("maxprice", fmt.Sprint(query.MaxPriceLevel))
+	}
+	// Google can only find places that are open now (rather than at a later time),
+	// so OpenAt is ignored
+
+	locations := []types.Location{}
+	for page := 0; page < googleMaxPages && len(locations) < query.Limit; page++ {
+		response, err := p.search(ctx, params, page > 0)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, place := range response.Results {
+			// Places that have closed can't be voted on
+			if place.BusinessStatus == "CLOSED_TEMPORARILY" || place.BusinessStatus == "CLOSED_PERMANENTLY" {
+				continue
+			}
+			locations = append(locations, p.location(place))
+		}
+
+		if response.NextPageToken == "" {
+			break
+		}
+		// The next page is only found from its token
+		params = url.Values{}
+		params.Set("pagetoken", response.NextPageToken)
+	}
+
+	if len(locations) > query.Limit {
+		locations = locations[:query.Limit]
+	}
+	return locations, nil
+}
+
+// search requests a single page of results.
+// Pages after the first are requested after a delay (and retried)
+// since their tokens don't become valid right away.
+func (p *GoogleProvider) search(ctx context.Context, params url.Values, nextPage bool) (*googleNearbyResponse, error) {
+	attempts := 1
+	if nextPage {
+		attempts = googlePageTokenRetries
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if nextPage {
+			delay := p.PageTokenDelay
+			if delay == 0 {
+				delay = googlePageTokenDelay
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		var response *googleNearbyResponse
+		response, err = p.request(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+
+		switch response.Status {
+		case "OK", "ZERO_RESULTS":
+			return response, nil
+		case "INVALID_REQUEST":
+			// The page token isn't valid yet
+			err = &GoogleError{Status: response.Status, Message: response.ErrorMessage}
+			continue
+		default:
+			return nil, &GoogleError{Status: response.Status, Message: response.ErrorMessage}
+		}
+	}
+	return nil, err
+}
+
+func (p *GoogleProvider) request(ctx context.Context, params url.Values) (*googleNearbyResponse, error) {
+	baseURL := p.BaseURL
+	if baseURL == "" {
+		baseURL = googleNearbySearchURL
+	}
+	var response googleNearbyResponse
+	err := p.get(ctx, baseURL, params, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// get requests the Google Places API endpoint with the API key added to the params,
+// decoding the response into the value
+func (p *GoogleProvider) get(ctx context.Context, endpoint string, params url.Values, response interface{}) error {
+	withKey := url.Values{}
+	for key, values := range params {
+		withKey[key] = values
+	}
+	withKey.Set("key", p.APIKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+withKey.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if p.Debug {
+		dump, err := httputil.DumpRequestOut(req, false)
+		if err == nil {
+			log.Printf("Google Places request:\n%s", p.redact(string(dump)))
+		}
+	}
+
+	client := p.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to search Google Places: %w", withoutURL(err))
+	}
+	defer res.Body.Close()
+	if p.Debug {
+		dump, err := httputil.DumpResponse(res, true)
+		if err == nil {
+			log.Printf("Google Places response:\n%s", p.redact(string(dump)))
+		}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("google places search failed with HTTP status %d", res.StatusCode)
+	}
+	err = json.NewDecoder(res.Body).Decode(response)
+	if err != nil {
+		return fmt.Errorf("invalid Google Places response: %w", err)
+	}
+	return nil
+}
+
+// redact removes the API key and the coordinates being searched around from the text
+func (p *GoogleProvider) redact(text string) string {
+	text = googleLocationParam.ReplaceAllString(text, "location=REDACTED")
+	if p.APIKey == "" {
+		return text
+	}
+	return strings.ReplaceAll(text, url.QueryEscape(p.APIKey), "REDACTED")
+}
+
+func (p *GoogleProvider) location(place googlePlace) types.Location {
+	location := types.Location{
+		Name:       place.Name,
+		Address:    place.Vicinity,
+		Rating:     place.Rating,
+		Image:      place.Icon,
+		Latitude:   place.Geometry.Location.Lat,
+		Longitude:  place.Geometry.Location.Lng,
+		Provider:   ProviderGoogle,
+		PlaceID:    place.PlaceID,
+		PriceLevel: place.PriceLevel,
+		URL:        fmt.Sprintf("https://www.google.com/maps/place/?q=place_id:%s", place.PlaceID),
+	}
+	// Photos can only be downloaded with the API key,
+	// so only the reference is kept (and the photo is served through the photo proxy)
+	if len(place.Photos) > 0 {
+		location.Photo = place.Photos[0].PhotoReference
+	}
+	return location
+}
+
+type googleDetailsResponse struct {
+	Result struct {
+		OpeningHours *struct {
+			Periods []struct {
+				Open googleWeeklyTime `json:"open"`
+				// Not set if the place is always open
+				Close *googleWeeklyTime `json:"close"`
+			} `json:"periods"`
+		} `json:"opening_hours"`
+		// Not set if the offset isn't known
+		UTCOffsetMinutes *int `json:"utc_offset_minutes"`
+	} `json:"result"`
+	Status       string `json:"status"`
+	ErrorMessage string `json:"error_message"`
+}
+
+type googleWeeklyTime struct {
+	// From 0 (Sunday) to 6 (Saturday)
+	Day int `json:"day"`
+	// Such as "1730"
+	Time string `json:"time"`
+}
+
+// OpeningHours looks up the opening hours of a place with the Place Details API
+func (p *GoogleProvider) OpeningHours(ctx context.Context, location types.Location) (*types.OpeningHours, error) {
+	if location.Provider != ProviderGoogle || location.PlaceID == "" {
+		return nil, nil
+	}
+	detailsURL := p.DetailsURL
+	if detailsURL == "" {
+		detailsURL = googlePlaceDetailsURL
+	}
+
+	params := url.Values{}
+	params.Set("place_id", location.PlaceID)
+	// Only the opening hours (and the offset of the local time they are in) are requested,
+	// since each field is billed
+	params.Set("fields", "opening_hours,utc_offset")
+	var response googleDetailsResponse
+	err := p.get(ctx, detailsURL, params, &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Status != "OK" {
+		return nil, &GoogleError{Status: response.Status, Message: response.ErrorMessage}
+	}
+	if response.Result.OpeningHours == nil {
+		return nil, nil
+	}
+
+	hours := &types.OpeningHours{Periods: []types.OpeningPeriod{}, UTCOffsetMinutes: response.Result.UTCOffsetMinutes}
+	for _, period := range response.Result.OpeningHours.Periods {
+		if period.Close == nil {
+			return &types.OpeningHours{AlwaysOpen: true, UTCOffsetMinutes: response.Result.UTCOffsetMinutes}, nil
+		}
+		open, err := parseHHMM(time.Weekday(period.Open.Day), period.Open.Time)
+		if err != nil {
+			return nil, err
+		}
+		close, err := parseHHMM(time.Weekday(period.Close.Day), period.Close.Time)
+		if err != nil {
+			return nil, err
+		}
+		hours.Periods = append(hours.Periods, types.OpeningPeriod{Open: open, Close: close})
+	}
+	return hours, nil
+}
@@ -0,0 +1,101 @@
+package locations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+const googleGeocodeURL = "https://maps.googleapis.com/maps/api/geocode/json"
+
+// How precise each of Google's location types is
+var googleLocationTypeConfidence = map[string]float64{
+	"ROOFTOP":            1,
+	"RANGE_INTERPOLATED": 0.8,
+	"GEOMETRIC_CENTER":   0.6,
+	"APPROXIMATE":        0.5,
+}
+
+// GoogleGeocoder finds addresses with the Google Geocoding API
+type GoogleGeocoder struct {
+	APIKey string
+	// If nil, then a client with a 10 second timeout is used
+	HTTPClient *http.Client
+	// If empty, then the Google Geocoding API is used
+	BaseURL string
+}
+
+type googleGeocodeResponse struct {
+	Results []struct {
+		FormattedAddress string `json:"formatted_address"`
+		PartialMatch     bool   `json:"partial_match"`
+		Geometry         struct {
+			Location struct {
+				Lat float64 `json:"lat"`
+				Lng float64 `json:"lng"`
+			} `json:"location"`
+			LocationType string `json:"location_type"`
+		} `json:"geometry"`
+	} `json:"results"`
+	Status       string `json:"status"`
+	ErrorMessage string `json:"error_message"`
+}
+
+func (g *GoogleGeocoder) Geocode(ctx context.Context, address string) ([]GeocodeResult, error) {
+	baseURL := g.BaseURL
+	if baseURL == "" {
+		baseURL = googleGeocodeURL
+	}
+
+	params := url.Values{}
+	params.Set("address", address)
+	params.Set("key", g.APIKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := g.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to geocode with Google: %w", withoutURL(err))
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("google geocoding failed with HTTP status %d", res.StatusCode)
+	}
+
+	var response googleGeocodeResponse
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Google Geocoding response: %w", err)
+	}
+	switch response.Status {
+	case "OK", "ZERO_RESULTS":
+	default:
+		return nil, &GoogleError{Status: response.Status, Message: response.ErrorMessage}
+	}
+
+	results := make([]GeocodeResult, 0, len(response.Results))
+	for _, result := range response.Results {
+		confidence := googleLocationTypeConfidence[result.Geometry.LocationType]
+		if result.PartialMatch {
+			// Only part of the address matched
+			confidence /= 2
+		}
+		results = append(results, GeocodeResult{
+			Label:       result.FormattedAddress,
+			Coordinates: types.Coordinates{Latitude: result.Geometry.Location.Lat, Longitude: result.Geometry.Location.Lng},
+			Confidence:  confidence,
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,144 @@
+package locations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+const (
+	googleDistanceMatrixURL = "https://maps.googleapis.com/maps/api/distancematrix/json"
+	// Google allows at most 25 destinations and 100 elements (origins times destinations) per request
+	googleMaxDestinations = 25
+	googleMaxElements     = 100
+)
+
+// Google's names for each mode
+var googleModes = map[string]string{
+	ModeWalk:    "walking",
+	ModeTransit: "transit",
+	ModeDrive:   "driving",
+}
+
+// GoogleRouter finds travel times with the Google Distance Matrix API
+type GoogleRouter struct {
+	APIKey string
+	// If nil, then a client with a 10 second timeout is used
+	HTTPClient *http.Client
+	// If empty, then the Google Distance Matrix API is used
+	BaseURL string
+}
+
+type googleDistanceMatrixResponse struct {
+	Rows []struct {
+		Elements []struct {
+			Status   string `json:"status"`
+			Duration struct {
+				// In seconds
+				Value float64 `json:"value"`
+			} `json:"duration"`
+		} `json:"elements"`
+	} `json:"rows"`
+	Status       string `json:"status"`
+	ErrorMessage string `json:"error_message"`
+}
+
+func (r *GoogleRouter) TravelTimes(ctx context.Context, origins []types.Coordinates, destinations []types.Coordinates, mode string) ([][]time.Duration, error) {
+	googleMode, ok := googleModes[mode]
+	if !ok {
+		return nil, ErrUnsupportedMode
+	}
+
+	times := make([][]time.Duration, len(origins))
+	for i := range times {
+		times[i] = make([]time.Duration, 0, len(destinations))
+	}
+	// The destinations and then the origins are split into requests that stay under Google's limits
+	for destinationStart := 0; destinationStart < len(destinations); destinationStart += googleMaxDestinations {
+		destinationBatch := destinations[destinationStart:min(destinationStart+googleMaxDestinations, len(destinations))]
+		batchSize := googleMaxElements / len(destinationBatch)
+		for start := 0; start < len(origins); start += batchSize {
+			batch := origins[start:min(start+batchSize, len(origins))]
+			batchTimes, err := r.request(ctx, batch, destinationBatch, googleMode)
+			if err != nil {
+				return nil, err
+			}
+			for i, row := range batchTimes {
+				times[start+i] = append(times[start+i], row...)
+			}
+		}
+	}
+	return times, nil
+}
+
+func (r *GoogleRouter) request(ctx context.Context, origins []types.Coordinates, destinations []types.Coordinates, googleMode string) ([][]time.Duration, error) {
+	baseURL := r.BaseURL
+	if baseURL == "" {
+		baseURL = googleDistanceMatrixURL
+	}
+
+	params := url.Values{}
+	params.Set("origins", googlePoints(origins))
+	params.Set("destinations", googlePoints(destinations))
+	params.Set("mode", googleMode)
+	params.Set("key", r.APIKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	client := r.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the Google Distance Matrix: %w", withoutURL(err))
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("google distance matrix failed with HTTP status %d", res.StatusCode)
+	}
+
+	var response googleDistanceMatrixResponse
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Google Distance Matrix response: %w", err)
+	}
+	if response.Status != "OK" {
+		return nil, &GoogleError{Status: response.Status, Message: response.ErrorMessage}
+	}
+	if len(response.Rows) != len(origins) {
+		return nil, fmt.Errorf("google returned %d rows for %d origins", len(response.Rows), len(origins))
+	}
+
+	times := make([][]time.Duration, len(origins))
+	for i, row := range response.Rows {
+		if len(row.Elements) != len(destinations) {
+			return nil, fmt.Errorf("google returned %d elements for %d destinations", len(row.Elements), len(destinations))
+		}
+		times[i] = make([]time.Duration, len(destinations))
+		for j, element := range row.Elements {
+			times[i][j] = NoRoute
+			if element.Status == "OK" {
+				times[i][j] = time.Duration(element.Duration.Value * float64(time.Second))
+			}
+		}
+	}
+	return times, nil
+}
+
+// googlePoints formats the points as a list of latitude,longitude pairs separated by "|"
+func googlePoints(points []types.Coordinates) string {
+	formatted := make([]string, len(points))
+	for i, point := range points {
+		formatted[i] = fmt.Sprintf("%f,%f", point.Latitude, point.Longitude)
+	}
+	return strings.Join(formatted, "|")
+}
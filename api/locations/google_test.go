@@ -0,0 +1,155 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+// googleServer serves the recorded Google Places responses in testdata
+type googleServer struct {
+	// How many requests for the second page fail before its token becomes valid
+	pageTokenFailures int
+	requests          []string
+}
+
+func (s *googleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests = append(s.requests, r.URL.RawQuery)
+
+	fixture := "google_nearby_page1.json"
+	switch {
+	case r.URL.Query().Get("key") != "test-key":
+		fixture = "google_request_denied.json"
+	case r.URL.Query().Get("pagetoken") == "page-2-token" && s.pageTokenFailures > 0:
+		s.pageTokenFailures--
+		fixture = "google_invalid_request.json"
+	case r.URL.Query().Get("pagetoken") == "page-2-token":
+		fixture = "google_nearby_page2.json"
+	}
+	http.ServeFile(w, r, filepath.Join("testdata", fixture))
+}
+
+func TestGoogleProvider(t *testing.T) {
+	stub := &googleServer{pageTokenFailures: 1}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	provider := &GoogleProvider{APIKey: "test-key", BaseURL: server.URL, PageTokenDelay: time.Millisecond}
+	locations, err := provider.Nearby(context.Background(), testQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The closed place is skipped, and the place without a rating is still included
+	expected := []types.Location{
+		{
+			Name:       "Pizza Place",
+			Address:    "123 Main St, Atlanta",
+			Rating:     4.4,
+			Image:      "https://maps.gstatic.com/mapfiles/place_api/icons/v1/png_71/restaurant-71.png",
+			Photo:      "photo-1",
+			Latitude:   33.7765,
+			Longitude:  -84.3898,
+			Provider:   ProviderGoogle,
+			PlaceID:    "place-1",
+			URL:        "https://www.google.com/maps/place/?q=place_id:place-1",
+			PriceLevel: 1,
+		},
+		{
+			Name:      "New Taco Stand",
+			Address:   "7 Peachtree St, Atlanta",
+			Image:     "https://maps.gstatic.com/mapfiles/place_api/icons/v1/png_71/restaurant-71.png",
+			Latitude:  33.7789,
+			Longitude: -84.4011,
+			Provider:  ProviderGoogle,
+			PlaceID:   "place-3",
+			URL:       "https://www.google.com/maps/place/?q=place_id:place-3",
+		},
+		{
+			Name:      "Noodle House",
+			Address:   "9 West Peachtree St, Atlanta",
+			Rating:    4.1,
+			Image:     "https://maps.gstatic.com/mapfiles/place_api/icons/v1/png_71/restaurant-71.png",
+			Latitude:  33.7712,
+			Longitude: -84.3876,
+			Provider:  ProviderGoogle,
+			PlaceID:   "place-4",
+			URL:       "https://www.google.com/maps/place/?q=place_id:place-4",
+		},
+	}
+	if len(locations) != len(expected) {
+		t.Fatalf("expected %d locations, got %+v", len(expected), locations)
+	}
+	for i := range expected {
+		if locations[i] != expected[i] {
+			t.Errorf("location %d: expected %+v, got %+v", i, expected[i], locations[i])
+		}
+	}
+
+	// The first page, the second page before its token was valid, and the second page again
+	if len(stub.requests) != 3 {
+		t.Fatalf("expected 3 requests, got %v", stub.requests)
+	}
+	if !strings.Contains(stub.requests[0], "type=restaurant") || !strings.Contains(stub.requests[0], "radius=1500") {
+		t.Errorf("unexpected first request %s", stub.requests[0])
+	}
+}
+
+func TestGoogleProviderLimit(t *testing.T) {
+	stub := &googleServer{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	query := testQuery
+	query.Limit = 2
+	provider := &GoogleProvider{APIKey: "test-key", BaseURL: server.URL, PageTokenDelay: time.Millisecond}
+	locations, err := provider.Nearby(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 2 || len(stub.requests) != 1 {
+		t.Errorf("expected 2 locations from a single page, got %+v from %d requests", locations, len(stub.requests))
+	}
+}
+
+func TestGoogleProviderError(t *testing.T) {
+	server := httptest.NewServer(&googleServer{})
+	defer server.Close()
+
+	provider := &GoogleProvider{APIKey: "wrong-key", BaseURL: server.URL}
+	_, err := provider.Nearby(context.Background(), testQuery)
+	var googleError *GoogleError
+	if !errors.As(err, &googleError) || googleError.Status != "REQUEST_DENIED" || googleError.Message != "The provided API key is invalid." {
+		t.Errorf("expected a REQUEST_DENIED error, got %v", err)
+	}
+}
+
+func TestGoogleProviderTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	provider := &GoogleProvider{
+		APIKey:     "secret-key",
+		BaseURL:    server.URL,
+		HTTPClient: &http.Client{Timeout: 10 * time.Millisecond},
+	}
+	_, err := provider.Nearby(context.Background(), testQuery)
+	if err == nil {
+		t.Fatal("expected the request to time out")
+	}
+	if strings.Contains(err.Error(), "secret-key") || strings.Contains(err.Error(), "33.7756") {
+		t.Errorf("expected the API key and coordinates to be removed from the error, got %v", err)
+	}
+	if redacted := provider.redact("GET /?key=secret-key&location=33.7756%2C-84.3963&radius=1500 HTTP/1.1"); strings.Contains(redacted, "secret-key") || strings.Contains(redacted, "33.7756") {
+		t.Errorf("expected the API key and coordinates to be redacted, got %s", redacted)
+	}
+}
@@ -0,0 +1,272 @@
+package locations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+const minutesPerWeek = 7 * 24 * 60
+
+// HoursProvider is implemented by place providers that can look up when places are open
+type HoursProvider interface {
+	// OpeningHours returns the opening hours of a location that the provider found,
+	// or nil if they aren't known (such as for locations found by other providers)
+	OpeningHours(ctx context.Context, location types.Location) (*types.OpeningHours, error)
+}
+
+// WithOpeningHours looks up the opening hours of the locations that don't have them
+// (if the provider can look them up).
+// Locations whose hours can't be looked up are left with unknown hours.
+func WithOpeningHours(ctx context.Context, provider PlaceProvider, locations []types.Location) []types.Location {
+	hoursProvider, ok := Unwrap(provider).(HoursProvider)
+	if !ok {
+		return locations
+	}
+
+	withHours := make([]types.Location, len(locations))
+	for i, location := range locations {
+		withHours[i] = location
+		if location.Hours != nil {
+			continue
+		}
+		hours, err := hoursProvider.OpeningHours(ctx, location)
+		if err != nil {
+			log.Printf("Failed to get the opening hours of place_id=%s: %v", location.PlaceID, err)
+			continue
+		}
+		withHours[i].Hours = hours
+	}
+	return withHours
+}
+
+// OpenTimes returns the indexes of the times that the location is open for the whole of
+// (or nil if its opening hours aren't known)
+func OpenTimes(location types.Location, times []types.TimePair) []int {
+	if location.Hours == nil {
+		return nil
+	}
+	open := []int{}
+	for i, pair := range times {
+		if OpenFor(*location.Hours, pair.Start, pair.End) {
+			open = append(open, i)
+		}
+	}
+	return open
+}
+
+// OnlyOpen leaves out the locations that are closed for part of every one of the times.
+// Locations with unknown opening hours and venues suggested by users are kept
+// (so users can still vote for the venues they suggested).
+func OnlyOpen(locations []types.Location, times []types.TimePair) []types.Location {
+	open := []types.Location{}
+	for _, location := range locations {
+		if location.Hours == nil || location.SuggestedBy != "" || len(OpenTimes(location, times)) > 0 {
+			open = append(open, location)
+		}
+	}
+	return open
+}
+
+// OnlyOpenDuring leaves out the locations that aren't known to be open for the whole of the time,
+// for events that only want places open at the chosen time.
+// Unlike OnlyOpen, locations with unknown opening hours are left out,
+// but venues suggested by users are still kept.
+func OnlyOpenDuring(locations []types.Location, pair types.TimePair) []types.Location {
+	open := []types.Location{}
+	for _, location := range locations {
+		if location.SuggestedBy != "" || (location.Hours != nil && OpenFor(*location.Hours, pair.Start, pair.End)) {
+			open = append(open, location)
+		}
+	}
+	return open
+}
+
+// OpenFor returns whether the location is open for the whole time from start to end.
+// The times are converted into the location's local time (if its UTC offset is known)
+// and then compared by their day of the week and time of day.
+func OpenFor(hours types.OpeningHours, start time.Time, end time.Time) bool {
+	if hours.AlwaysOpen {
+		return true
+	}
+	if hours.UTCOffsetMinutes != nil {
+		zone := time.FixedZone("", *hours.UTCOffsetMinutes*60)
+		start, end = start.In(zone), end.In(zone)
+	}
+	duration := int(math.Ceil(end.Sub(start).Minutes()))
+	if duration >= minutesPerWeek {
+		return false
+	}
+
+	current := weekMinute(types.WeeklyTime{Day: start.Weekday(), Hour: start.Hour(), Minute: start.Minute()})
+	until := current + max(duration, 0)
+	// Providers split hours that cross midnight (or the end of the week) into periods that follow each other,
+	// so the time can be covered by more than one period
+	for i := 0; i <= len(hours.Periods); i++ {
+		next := -1
+		for _, period := range hours.Periods {
+			open, close := weekMinute(period.Open), weekMinute(period.Close)
+			if close <= open {
+				close += minutesPerWeek
+			}
+			// The period can also cover the time in the previous or next week
+			for _, shift := range []int{-minutesPerWeek, 0, minutesPerWeek} {
+				if open+shift <= current && current < close+shift {
+					next = max(next, close+shift)
+				}
+			}
+		}
+		if next == -1 {
+			return false
+		}
+		if next >= until {
+			return true
+		}
+		current = next
+	}
+	return false
+}
+
+// estimatedUTCOffset estimates the UTC offset (in minutes) of a place from its longitude,
+// for providers that don't give it.
+// It is the offset of the place's nautical time zone, so it can be off by an hour or two
+// where the local time zone is wider or observes daylight saving time.
+func estimatedUTCOffset(longitude float64) *int {
+	offset := int(math.Round(longitude/15)) * 60
+	return &offset
+}
+
+// weekMinute returns the minutes from the start of the week (midnight on Sunday) to the time
+func weekMinute(t types.WeeklyTime) int {
+	return int(t.Day)*24*60 + t.Hour*60 + t.Minute
+}
+
+// parseHHMM parses times such as "0930" (as Google and Yelp format them)
+func parseHHMM(day time.Weekday, hhmm string) (types.WeeklyTime, error) {
+	if len(hhmm) != 4 {
+		return types.WeeklyTime{}, fmt.Errorf("invalid time '%s'", hhmm)
+	}
+	hour, hourErr := strconv.Atoi(hhmm[:2])
+	minute, minuteErr := strconv.Atoi(hhmm[2:])
+	if hourErr != nil || minuteErr != nil || hour > 24 || minute > 59 {
+		return types.WeeklyTime{}, fmt.Errorf("invalid time '%s'", hhmm)
+	}
+	return types.WeeklyTime{Day: day, Hour: hour, Minute: minute}, nil
+}
+
+var (
+	osmDays       = []string{"Su", "Mo", "Tu", "We", "Th", "Fr", "Sa"}
+	osmRule       = regexp.MustCompile(`^((?:Mo|Tu|We|Th|Fr|Sa|Su)(?:[-,](?:Mo|Tu|We|Th|Fr|Sa|Su))*)?\s*(off|closed|(?:\d{1,2}:\d{2}-\d{1,2}:\d{2}(?:\s*,\s*)?)+)$`)
+	osmTimeRange  = regexp.MustCompile(`(\d{1,2}):(\d{2})-(\d{1,2}):(\d{2})`)
+	osmDayIndexes = func() map[string]int {
+		indexes := make(map[string]int)
+		for i, day := range osmDays {
+			indexes[day] = i
+		}
+		return indexes
+	}()
+)
+
+// parseOSMOpeningHours parses the common forms of OpenStreetMap's opening_hours tag,
+// such as "24/7" or "Mo-Fr 08:00-18:00; Sa 10:00-14:00,17:00-22:00; Su off".
+// It returns nil for hours that it can't parse (such as ones with holidays or months),
+// since they are better treated as unknown than guessed.
+func parseOSMOpeningHours(value string) *types.OpeningHours {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	if value == "24/7" {
+		return &types.OpeningHours{AlwaysOpen: true}
+	}
+
+	// Later rules replace the hours of the days they name
+	dayRanges := make(map[int][][2]int)
+	for _, rule := range strings.Split(value, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		match := osmRule.FindStringSubmatch(rule)
+		if match == nil {
+			return nil
+		}
+
+		days := []int{0, 1, 2, 3, 4, 5, 6}
+		if match[1] != "" {
+			days = osmRuleDays(match[1])
+		}
+		var ranges [][2]int
+		if match[2] != "off" && match[2] != "closed" {
+			for _, timeRange := range osmTimeRange.FindAllStringSubmatch(match[2], -1) {
+				// The pattern only matches digits, so these can't fail
+				openHour, _ := strconv.Atoi(timeRange[1])
+				openMinute, _ := strconv.Atoi(timeRange[2])
+				closeHour, _ := strconv.Atoi(timeRange[3])
+				closeMinute, _ := strconv.Atoi(timeRange[4])
+				if openHour > 23 || closeHour > 48 || openMinute > 59 || closeMinute > 59 {
+					return nil
+				}
+				ranges = append(ranges, [2]int{openHour*60 + openMinute, closeHour*60 + closeMinute})
+			}
+		}
+		for _, day := range days {
+			dayRanges[day] = ranges
+		}
+	}
+
+	hours := &types.OpeningHours{Periods: []types.OpeningPeriod{}}
+	for day := 0; day < 7; day++ {
+		for _, timeRange := range dayRanges[day] {
+			open := day*24*60 + timeRange[0]
+			close := day*24*60 + timeRange[1]
+			if timeRange[1] <= timeRange[0] {
+				// Such as 18:00-02:00, which closes the next day
+				close += 24 * 60
+			}
+			hours.Periods = append(hours.Periods, types.OpeningPeriod{
+				Open:  weeklyTime(open),
+				Close: weeklyTime(close),
+			})
+		}
+	}
+	return hours
+}
+
+// osmRuleDays returns the days named by a list of days and day ranges, such as "Mo-Fr,Su"
+func osmRuleDays(value string) []int {
+	var days []int
+	for _, part := range strings.Split(value, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		first := osmDayIndexes[bounds[0]]
+		last := first
+		if len(bounds) == 2 {
+			last = osmDayIndexes[bounds[1]]
+		}
+		// Ranges can wrap around the end of the week, such as "Fr-Mo"
+		for day := first; ; day = (day + 1) % 7 {
+			days = append(days, day)
+			if day == last {
+				break
+			}
+		}
+	}
+	return days
+}
+
+// weeklyTime converts minutes from the start of the week back into a day and time
+func weeklyTime(minutes int) types.WeeklyTime {
+	minutes %= minutesPerWeek
+	return types.WeeklyTime{
+		Day:    time.Weekday(minutes / (24 * 60)),
+		Hour:   minutes % (24 * 60) / 60,
+		Minute: minutes % 60,
+	}
+}
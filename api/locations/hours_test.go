@@ -0,0 +1,253 @@
+package locations
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+// monday returns the time on Monday, March 7 2022 (or the days after it)
+func monday(days int, hour int, minute int) time.Time {
+	return time.Date(2022, time.March, 7+days, hour, minute, 0, 0, time.UTC)
+}
+
+func period(openDay time.Weekday, openHour int, closeDay time.Weekday, closeHour int) types.OpeningPeriod {
+	return types.OpeningPeriod{
+		Open:  types.WeeklyTime{Day: openDay, Hour: openHour},
+		Close: types.WeeklyTime{Day: closeDay, Hour: closeHour},
+	}
+}
+
+func TestOpenFor(t *testing.T) {
+	hours := types.OpeningHours{Periods: []types.OpeningPeriod{
+		// Weekday lunches
+		period(time.Monday, 11, time.Monday, 14),
+		period(time.Tuesday, 11, time.Tuesday, 14),
+		// Late on Friday, split at midnight
+		period(time.Friday, 18, time.Saturday, 0),
+		period(time.Saturday, 0, time.Saturday, 2),
+		// Saturday night into Sunday morning, across the end of the week
+		period(time.Saturday, 22, time.Sunday, 3),
+	}}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		expected   bool
+	}{
+		{"whole time", monday(0, 12, 0), monday(0, 13, 0), true},
+		{"until closing", monday(0, 11, 0), monday(0, 14, 0), true},
+		{"closes part-way", monday(0, 13, 0), monday(0, 15, 0), false},
+		{"opens part-way", monday(0, 10, 0), monday(0, 12, 0), false},
+		{"closed day", monday(2, 12, 0), monday(2, 13, 0), false},
+		{"across split periods", monday(4, 23, 0), monday(5, 1, 0), true},
+		{"past the split periods", monday(4, 23, 0), monday(5, 3, 0), false},
+		{"across the end of the week", monday(5, 23, 0), monday(6, 2, 0), true},
+		{"early in the week", monday(6, 1, 0), monday(6, 2, 30), true},
+	}
+	for _, test := range tests {
+		if open := OpenFor(hours, test.start, test.end); open != test.expected {
+			t.Errorf("%s: open = %v, want %v", test.name, open, test.expected)
+		}
+	}
+
+	if !OpenFor(types.OpeningHours{AlwaysOpen: true}, monday(0, 3, 0), monday(1, 3, 0)) {
+		t.Errorf("a place that is always open is closed")
+	}
+}
+
+func TestOpenForNonUTCLocation(t *testing.T) {
+	// A lunch place in Atlanta (UTC-5) and one in Tokyo (UTC+9), in their local times
+	atlanta, tokyo := -5*60, 9*60
+	atlantaHours := types.OpeningHours{Periods: []types.OpeningPeriod{period(time.Monday, 11, time.Monday, 14)}, UTCOffsetMinutes: &atlanta}
+	tokyoHours := types.OpeningHours{Periods: []types.OpeningPeriod{period(time.Tuesday, 11, time.Tuesday, 14)}, UTCOffsetMinutes: &tokyo}
+
+	tests := []struct {
+		name       string
+		hours      types.OpeningHours
+		start, end time.Time
+		expected   bool
+	}{
+		{"lunch in Atlanta", atlantaHours, monday(0, 17, 0), monday(0, 18, 0), true},
+		{"lunch in UTC", atlantaHours, monday(0, 12, 0), monday(0, 13, 0), false},
+		{"closes part-way in Atlanta", atlantaHours, monday(0, 18, 0), monday(0, 20, 0), false},
+		// Tuesday lunch in Tokyo is early on Tuesday morning in UTC
+		{"lunch in Tokyo", tokyoHours, monday(1, 2, 0), monday(1, 4, 0), true},
+		{"Tuesday lunch in UTC", tokyoHours, monday(1, 12, 0), monday(1, 13, 0), false},
+	}
+	for _, test := range tests {
+		if open := OpenFor(test.hours, test.start, test.end); open != test.expected {
+			t.Errorf("%s: open = %v, want %v", test.name, open, test.expected)
+		}
+	}
+}
+
+func TestOnlyOpen(t *testing.T) {
+	lunch := &types.OpeningHours{Periods: []types.OpeningPeriod{period(time.Monday, 11, time.Monday, 14)}}
+	dinner := &types.OpeningHours{Periods: []types.OpeningPeriod{period(time.Monday, 17, time.Monday, 22)}}
+	times := []types.TimePair{
+		{Start: monday(0, 12, 0), End: monday(0, 13, 0)},
+		{Start: monday(0, 13, 0), End: monday(0, 15, 0)},
+	}
+
+	provider := &FakeProvider{Hours: map[string]*types.OpeningHours{"lunch": lunch, "dinner": dinner}}
+	locations := WithOpeningHours(context.Background(), provider, []types.Location{
+		{Name: "lunch", Provider: ProviderFake, PlaceID: "lunch"},
+		{Name: "dinner", Provider: ProviderFake, PlaceID: "dinner"},
+		{Name: "unknown", Provider: ProviderFake, PlaceID: "unknown"},
+		{Name: "suggested dinner", Provider: ProviderSuggested, SuggestedBy: "alice", Hours: dinner},
+	})
+
+	if !reflect.DeepEqual(OpenTimes(locations[0], times), []int{0}) {
+		t.Errorf("lunch open times = %v, want [0]", OpenTimes(locations[0], times))
+	}
+	if OpenTimes(locations[2], times) != nil {
+		t.Errorf("the place with unknown hours has open times")
+	}
+
+	var names []string
+	for _, location := range OnlyOpen(locations, times) {
+		names = append(names, location.Name)
+	}
+	// The dinner place found by the provider is left out, but the one a user suggested is kept
+	expected := []string{"lunch", "unknown", "suggested dinner"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("open locations = %v, want %v", names, expected)
+	}
+}
+
+func TestParseOSMOpeningHours(t *testing.T) {
+	hours := parseOSMOpeningHours("Mo-Fr 08:00-18:00; Sa 10:00-14:00,18:00-02:00; Fr off")
+	if hours == nil {
+		t.Fatal("failed to parse the opening hours")
+	}
+	expected := []types.OpeningPeriod{
+		period(time.Monday, 8, time.Monday, 18),
+		period(time.Tuesday, 8, time.Tuesday, 18),
+		period(time.Wednesday, 8, time.Wednesday, 18),
+		period(time.Thursday, 8, time.Thursday, 18),
+		period(time.Saturday, 10, time.Saturday, 14),
+		period(time.Saturday, 18, time.Sunday, 2),
+	}
+	if !reflect.DeepEqual(hours.Periods, expected) {
+		t.Errorf("periods = %v, want %v", hours.Periods, expected)
+	}
+
+	if hours := parseOSMOpeningHours("24/7"); hours == nil || !hours.AlwaysOpen {
+		t.Errorf("24/7 isn't always open: %v", hours)
+	}
+	for _, unsupported := range []string{"", "Mo-Fr 08:00-18:00; PH off", "sunrise-sunset", "Jan-Mar Mo 10:00-12:00"} {
+		if hours := parseOSMOpeningHours(unsupported); hours != nil {
+			t.Errorf("%q: hours = %v, want unknown", unsupported, hours)
+		}
+	}
+}
+
+func TestGoogleOpeningHours(t *testing.T) {
+	var fields string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fields = r.URL.Query().Get("fields")
+		switch r.URL.Query().Get("place_id") {
+		case "always-open":
+			fmt.Fprint(w, `{"status": "OK", "result": {"opening_hours": {"periods": [{"open": {"day": 0, "time": "0000"}}]}}}`)
+		case "no-hours":
+			fmt.Fprint(w, `{"status": "OK", "result": {}}`)
+		default:
+			fmt.Fprint(w, `{"status": "OK", "result": {"utc_offset_minutes": -300, "opening_hours": {"periods": [`+
+				`{"open": {"day": 5, "time": "1730"}, "close": {"day": 6, "time": "0100"}}]}}}`)
+		}
+	}))
+	defer server.Close()
+
+	provider := &GoogleProvider{APIKey: "test-key", DetailsURL: server.URL}
+	hours, err := provider.OpeningHours(context.Background(), types.Location{Provider: ProviderGoogle, PlaceID: "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []types.OpeningPeriod{{
+		Open:  types.WeeklyTime{Day: time.Friday, Hour: 17, Minute: 30},
+		Close: types.WeeklyTime{Day: time.Saturday, Hour: 1},
+	}}
+	if hours == nil || !reflect.DeepEqual(hours.Periods, expected) {
+		t.Errorf("hours = %v, want %v", hours, expected)
+	}
+	if hours != nil && (hours.UTCOffsetMinutes == nil || *hours.UTCOffsetMinutes != -300) {
+		t.Errorf("UTC offset = %v, want -300", hours.UTCOffsetMinutes)
+	}
+	if fields != "opening_hours,utc_offset" {
+		t.Errorf("requested fields %s, want opening_hours,utc_offset", fields)
+	}
+
+	hours, err = provider.OpeningHours(context.Background(), types.Location{Provider: ProviderGoogle, PlaceID: "always-open"})
+	if err != nil || hours == nil || !hours.AlwaysOpen {
+		t.Errorf("always open place: hours = %v, err = %v", hours, err)
+	}
+	hours, err = provider.OpeningHours(context.Background(), types.Location{Provider: ProviderGoogle, PlaceID: "no-hours"})
+	if err != nil || hours != nil {
+		t.Errorf("place without hours: hours = %v, err = %v", hours, err)
+	}
+	// Places found by other providers can't be looked up
+	hours, err = provider.OpeningHours(context.Background(), types.Location{Provider: ProviderYelp, PlaceID: "bar"})
+	if err != nil || hours != nil {
+		t.Errorf("place from another provider: hours = %v, err = %v", hours, err)
+	}
+}
+
+func TestYelpOpeningHours(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		fmt.Fprint(w, `{"hours": [{"hours_type": "REGULAR", "open": [`+
+			`{"day": 0, "start": "1100", "end": "1400", "is_overnight": false},`+
+			`{"day": 6, "start": "2000", "end": "0200", "is_overnight": true}]}]}`)
+	}))
+	defer server.Close()
+
+	provider := &YelpProvider{APIKey: "test-key", DetailsURL: server.URL}
+	hours, err := provider.OpeningHours(context.Background(), types.Location{Provider: ProviderYelp, PlaceID: "taco-stand", Longitude: -84.3963})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/taco-stand" {
+		t.Errorf("requested %s, want /taco-stand", path)
+	}
+	// Yelp's days start on Monday, and the Sunday night hours close on Monday
+	expected := []types.OpeningPeriod{
+		period(time.Monday, 11, time.Monday, 14),
+		period(time.Sunday, 20, time.Monday, 2),
+	}
+	if hours == nil || !reflect.DeepEqual(hours.Periods, expected) {
+		t.Errorf("hours = %v, want %v", hours, expected)
+	}
+	// Yelp doesn't give the time zone, so it is estimated from the longitude
+	if hours != nil && (hours.UTCOffsetMinutes == nil || *hours.UTCOffsetMinutes != -6*60) {
+		t.Errorf("UTC offset = %v, want %d", hours.UTCOffsetMinutes, -6*60)
+	}
+}
+
+func TestOnlyOpenDuring(t *testing.T) {
+	lunch := &types.OpeningHours{Periods: []types.OpeningPeriod{period(time.Monday, 11, time.Monday, 14)}}
+	dinner := &types.OpeningHours{Periods: []types.OpeningPeriod{period(time.Monday, 17, time.Monday, 22)}}
+	locations := []types.Location{
+		{Name: "lunch", Hours: lunch},
+		{Name: "dinner", Hours: dinner},
+		{Name: "unknown"},
+		{Name: "suggested dinner", SuggestedBy: "alice", Hours: dinner},
+	}
+
+	var names []string
+	for _, location := range OnlyOpenDuring(locations, types.TimePair{Start: monday(0, 12, 0), End: monday(0, 13, 0)}) {
+		names = append(names, location.Name)
+	}
+	// Places with unknown hours aren't known to be open, so they are left out too
+	expected := []string{"lunch", "suggested dinner"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("open locations = %v, want %v", names, expected)
+	}
+}
@@ -0,0 +1,195 @@
+package locations
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+// Strategies for finding the point that places are searched for around
+const (
+	// MidpointCentroid is the center of mass of the users' locations on the globe
+	// (the default)
+	MidpointCentroid = "centroid"
+	// MidpointGeometricMedian minimizes the total distance from every user,
+	// so it isn't pulled as far towards a cluster of users
+	MidpointGeometricMedian = "geometric_median"
+	// MidpointMinimax minimizes the distance from the farthest user
+	MidpointMinimax = "minimax"
+)
+
+// The mean radius of the Earth in meters
+const earthRadius = 6371000
+
+const (
+	// Iterative strategies stop once the point moves less than this (in radians, about 6 meters)
+	midpointTolerance     = 1e-6
+	midpointMaxIterations = 1000
+)
+
+// ValidMidpointStrategy checks that the strategy is one of the supported ones
+// (or empty, for the default)
+func ValidMidpointStrategy(strategy string) error {
+	switch strategy {
+	case "", MidpointCentroid, MidpointGeometricMedian, MidpointMinimax:
+		return nil
+	}
+	return fmt.Errorf("midpoint strategy '%s' must be one of '%s', '%s' or '%s'",
+		strategy, MidpointCentroid, MidpointGeometricMedian, MidpointMinimax)
+}
+
+// EventMidpoint finds the midpoint of the users' locations using the event's strategy
+// (returning false if no users have submitted a location)
+func EventMidpoint(event types.Event) (types.Coordinates, bool) {
+	return Midpoint(event.UserLocations, event.MidpointStrategy)
+}
+
+// Midpoint finds the midpoint of all users that have submitted a location using the strategy
+// (returning false if there are none)
+func Midpoint(userLocations map[string]types.UserLocation, strategy string) (types.Coordinates, bool) {
+	var points []vector
+	for _, location := range userLocations {
+		if HasLocation(location) {
+			points = append(points, toVector(location.Latitude, location.Longitude))
+		}
+	}
+	if len(points) == 0 {
+		return types.Coordinates{}, false
+	}
+
+	var midpoint vector
+	switch strategy {
+	case MidpointGeometricMedian:
+		midpoint = geometricMedian(points)
+	case MidpointMinimax:
+		midpoint = minimax(points)
+	default:
+		midpoint = centroid(points)
+	}
+	return midpoint.coordinates(), true
+}
+
+// HasLocation returns whether the user submitted a location
+// (the zero value is used when they haven't)
+func HasLocation(location types.UserLocation) bool {
+	return location.Latitude != 0 || location.Longitude != 0
+}
+
+// vector is a point on the unit sphere (or, between steps, inside it).
+// Working with vectors rather than latitudes and longitudes
+// means that points on either side of the antimeridian or near a pole are handled correctly.
+type vector struct {
+	x, y, z float64
+}
+
+func toVector(latitude float64, longitude float64) vector {
+	lat := latitude * math.Pi / 180
+	long := longitude * math.Pi / 180
+	return vector{
+		x: math.Cos(lat) * math.Cos(long),
+		y: math.Cos(lat) * math.Sin(long),
+		z: math.Sin(lat),
+	}
+}
+
+func (v vector) coordinates() types.Coordinates {
+	return types.Coordinates{
+		Latitude:  math.Atan2(v.z, math.Hypot(v.x, v.y)) * 180 / math.Pi,
+		Longitude: math.Atan2(v.y, v.x) * 180 / math.Pi,
+	}
+}
+
+func (v vector) add(other vector) vector {
+	return vector{v.x + other.x, v.y + other.y, v.z + other.z}
+}
+
+func (v vector) scale(factor float64) vector {
+	return vector{v.x * factor, v.y * factor, v.z * factor}
+}
+
+func (v vector) length() float64 {
+	return math.Sqrt(v.x*v.x + v.y*v.y + v.z*v.z)
+}
+
+// normalize projects the vector onto the sphere
+// (or returns false if it is too close to the center to have a direction)
+func (v vector) normalize() (vector, bool) {
+	length := v.length()
+	if length < 1e-12 {
+		return v, false
+	}
+	return v.scale(1 / length), true
+}
+
+// angle returns the great circle distance (in radians) between the two points
+func angle(a vector, b vector) float64 {
+	cross := vector{a.y*b.z - a.z*b.y, a.z*b.x - a.x*b.z, a.x*b.y - a.y*b.x}
+	return math.Atan2(cross.length(), a.x*b.x+a.y*b.y+a.z*b.z)
+}
+
+// centroid returns the average of the points projected back onto the sphere.
+// If the points cancel out (such as two users on opposite sides of the globe),
+// then the first point is used.
+func centroid(points []vector) vector {
+	var sum vector
+	for _, point := range points {
+		sum = sum.add(point)
+	}
+	midpoint, ok := sum.normalize()
+	if !ok {
+		return points[0]
+	}
+	return midpoint
+}
+
+// geometricMedian uses Weiszfeld's algorithm (starting from the centroid)
+// to find the point that minimizes the total great circle distance to the points
+func geometricMedian(points []vector) vector {
+	median := centroid(points)
+	for i := 0; i < midpointMaxIterations; i++ {
+		var sum vector
+		for _, point := range points {
+			distance := angle(median, point)
+			if distance < midpointTolerance {
+				// The median is (practically) at one of the points,
+				// which is where it stays
+				return median
+			}
+			sum = sum.add(point.scale(1 / distance))
+		}
+
+		next, ok := sum.normalize()
+		if !ok {
+			return median
+		}
+		moved := angle(median, next)
+		median = next
+		if moved < midpointTolerance {
+			break
+		}
+	}
+	return median
+}
+
+// minimax approximates the point that minimizes the great circle distance to the farthest point
+// by repeatedly stepping towards the farthest point with shrinking steps (Bădoiu-Clarkson)
+func minimax(points []vector) vector {
+	center := centroid(points)
+	for i := 1; i <= midpointMaxIterations; i++ {
+		farthest := points[0]
+		for _, point := range points[1:] {
+			if angle(center, point) > angle(center, farthest) {
+				farthest = point
+			}
+		}
+
+		step := farthest.add(center.scale(-1)).scale(1 / float64(i+1))
+		next, ok := center.add(step).normalize()
+		if !ok {
+			return center
+		}
+		center = next
+	}
+	return center
+}
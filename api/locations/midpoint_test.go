@@ -0,0 +1,72 @@
+package locations
+
+import (
+	"math"
+	"testing"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+// distance returns the great circle distance between the coordinates in kilometers
+func distance(a types.Coordinates, b types.Coordinates) float64 {
+	return angle(toVector(a.Latitude, a.Longitude), toVector(b.Latitude, b.Longitude)) * 6371
+}
+
+func TestMidpointAntimeridian(t *testing.T) {
+	// Fiji and Samoa are on either side of the antimeridian
+	userLocations := map[string]types.UserLocation{
+		"001": {Latitude: -18, Longitude: 179},
+		"002": {Latitude: -14, Longitude: -171},
+	}
+	for _, strategy := range []string{MidpointCentroid, MidpointGeometricMedian, MidpointMinimax} {
+		midpoint, ok := Midpoint(userLocations, strategy)
+		if !ok {
+			t.Fatalf("%s: expected a midpoint", strategy)
+		}
+		if math.Abs(midpoint.Longitude) < 170 {
+			t.Errorf("%s: expected the midpoint to be near the antimeridian, got %+v", strategy, midpoint)
+		}
+	}
+}
+
+func TestMidpointCluster(t *testing.T) {
+	// Three users in one neighborhood and one across town
+	userLocations := map[string]types.UserLocation{
+		"001": {Latitude: 33.770, Longitude: -84.390},
+		"002": {Latitude: 33.771, Longitude: -84.391},
+		"003": {Latitude: 33.769, Longitude: -84.389},
+		"004": {Latitude: 33.850, Longitude: -84.390},
+		// Users without a location are excluded
+		"005": {},
+	}
+	cluster := types.Coordinates{Latitude: 33.770, Longitude: -84.390}
+	farthest := types.Coordinates{Latitude: 33.850, Longitude: -84.390}
+
+	centroid, _ := Midpoint(userLocations, MidpointCentroid)
+	if math.Abs(centroid.Latitude-33.790) > 0.001 {
+		t.Errorf("expected the centroid to be the average, got %+v", centroid)
+	}
+
+	// The median stays with the cluster
+	median, _ := Midpoint(userLocations, MidpointGeometricMedian)
+	if distance(median, cluster) > 0.2 {
+		t.Errorf("expected the geometric median to be near the cluster, got %+v", median)
+	}
+
+	// The minimax point is halfway between the cluster and the farthest user
+	minimaxPoint, _ := Midpoint(userLocations, MidpointMinimax)
+	toCluster, toFarthest := distance(minimaxPoint, cluster), distance(minimaxPoint, farthest)
+	if math.Abs(toCluster-toFarthest) > 0.5 || toFarthest > distance(centroid, farthest) {
+		t.Errorf("expected the minimax point to be about halfway, got %+v (%.2f km and %.2f km)", minimaxPoint, toCluster, toFarthest)
+	}
+}
+
+func TestMidpointNoLocations(t *testing.T) {
+	_, ok := Midpoint(map[string]types.UserLocation{"001": {}}, MidpointGeometricMedian)
+	if ok {
+		t.Error("expected no midpoint when no users have submitted a location")
+	}
+	if err := ValidMidpointStrategy("average"); err == nil {
+		t.Error("expected an unknown strategy to be invalid")
+	}
+}
@@ -0,0 +1,108 @@
+package locations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+const (
+	nominatimURL = "https://nominatim.openstreetmap.org/search"
+	// Nominatim's usage policy requires a user agent that identifies the application
+	nominatimUserAgent = "super-auto-hangouts (https://super-auto-hangouts.netlify.app)"
+)
+
+// NominatimGeocoder finds addresses in OpenStreetMap with the Nominatim search API
+type NominatimGeocoder struct {
+	// If empty, then the public Nominatim instance is used
+	URL string
+	// If nil, then a client with a 10 second timeout is used
+	HTTPClient *http.Client
+}
+
+type nominatimPlace struct {
+	DisplayName string `json:"display_name"`
+	// Nominatim returns the coordinates as strings
+	Lat string `json:"lat"`
+	Lon string `json:"lon"`
+	// From 0 (the whole earth) to 30 (a building), how precise the place is
+	PlaceRank   int    `json:"place_rank"`
+	AddressType string `json:"addresstype"`
+}
+
+// nominatimConfidence is how precise the place is, like the Google geocoder's location types.
+// (Nominatim's importance is how well-known a place is, not how closely it matches the address.)
+func nominatimConfidence(place nominatimPlace) float64 {
+	switch {
+	case place.PlaceRank >= 30 || place.AddressType == "house" || place.AddressType == "building":
+		return 1
+	case place.PlaceRank >= 26:
+		// A street
+		return 0.8
+	case place.PlaceRank >= 16 || place.AddressType == "postcode":
+		// A neighbourhood, postal code or town
+		return 0.6
+	default:
+		return 0.4
+	}
+}
+
+func (g *NominatimGeocoder) Geocode(ctx context.Context, address string) ([]GeocodeResult, error) {
+	searchURL := g.URL
+	if searchURL == "" {
+		searchURL = nominatimURL
+	}
+
+	params := url.Values{}
+	params.Set("q", address)
+	params.Set("format", "jsonv2")
+	params.Set("limit", fmt.Sprint(maxGeocodeCandidates))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, searchURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", nominatimUserAgent)
+
+	client := g.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search Nominatim: %w", withoutURL(err))
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("nominatim search failed with status %d", res.StatusCode)
+	}
+
+	var places []nominatimPlace
+	err = json.NewDecoder(res.Body).Decode(&places)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Nominatim response: %w", err)
+	}
+
+	results := make([]GeocodeResult, 0, len(places))
+	for _, place := range places {
+		latitude, latErr := strconv.ParseFloat(place.Lat, 64)
+		longitude, lonErr := strconv.ParseFloat(place.Lon, 64)
+		if latErr != nil || lonErr != nil {
+			continue
+		}
+		results = append(results, GeocodeResult{
+			Label:       place.DisplayName,
+			Coordinates: types.Coordinates{Latitude: latitude, Longitude: longitude},
+			Confidence:  nominatimConfidence(place),
+		})
+	}
+	// Nominatim sorts places by importance, which is kept for places that are as precise
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Confidence > results[j].Confidence })
+	return results, nil
+}
@@ -0,0 +1,102 @@
+package locations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+const osrmURL = "https://router.project-osrm.org"
+
+// OSRM's profiles for each mode (OSRM doesn't route transit)
+var osrmProfiles = map[string]string{
+	ModeWalk:  "foot",
+	ModeDrive: "driving",
+}
+
+// OSRMRouter finds travel times with the table service of an OSRM server
+// (which only has the profiles it was set up with; the public demo server only has driving)
+type OSRMRouter struct {
+	// If empty, then the public OSRM demo server is used
+	URL string
+	// If nil, then a client with a 10 second timeout is used
+	HTTPClient *http.Client
+}
+
+type osrmTableResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// In seconds, or null if there is no route
+	Durations [][]*float64 `json:"durations"`
+}
+
+func (r *OSRMRouter) TravelTimes(ctx context.Context, origins []types.Coordinates, destinations []types.Coordinates, mode string) ([][]time.Duration, error) {
+	profile, ok := osrmProfiles[mode]
+	if !ok {
+		return nil, ErrUnsupportedMode
+	}
+	baseURL := r.URL
+	if baseURL == "" {
+		baseURL = osrmURL
+	}
+
+	// OSRM takes every point in a single list (as longitude,latitude),
+	// and the indexes of the sources and destinations in it
+	var points, sources, targets []string
+	for i, origin := range origins {
+		points = append(points, fmt.Sprintf("%f,%f", origin.Longitude, origin.Latitude))
+		sources = append(sources, fmt.Sprint(i))
+	}
+	for i, destination := range destinations {
+		points = append(points, fmt.Sprintf("%f,%f", destination.Longitude, destination.Latitude))
+		targets = append(targets, fmt.Sprint(len(origins)+i))
+	}
+	tableURL := fmt.Sprintf("%s/table/v1/%s/%s?sources=%s&destinations=%s&annotations=duration",
+		strings.TrimSuffix(baseURL, "/"), profile, strings.Join(points, ";"), strings.Join(sources, ";"), strings.Join(targets, ";"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tableURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := r.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query OSRM: %w", withoutURL(err))
+	}
+	defer res.Body.Close()
+
+	var response osrmTableResponse
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OSRM response (status %d): %w", res.StatusCode, err)
+	}
+	if response.Code != "Ok" {
+		return nil, fmt.Errorf("osrm table query failed with %s: %s", response.Code, response.Message)
+	}
+	if len(response.Durations) != len(origins) {
+		return nil, fmt.Errorf("osrm returned %d rows for %d origins", len(response.Durations), len(origins))
+	}
+
+	times := make([][]time.Duration, len(origins))
+	for i, row := range response.Durations {
+		if len(row) != len(destinations) {
+			return nil, fmt.Errorf("osrm returned %d durations for %d destinations", len(row), len(destinations))
+		}
+		times[i] = make([]time.Duration, len(destinations))
+		for j, seconds := range row {
+			times[i][j] = NoRoute
+			if seconds != nil {
+				times[i][j] = time.Duration(*seconds * float64(time.Second))
+			}
+		}
+	}
+	return times, nil
+}
@@ -0,0 +1,143 @@
+package locations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+const overpassURL = "https://overpass-api.de/api/interpreter"
+
+// OpenStreetMap tags for the shared categories
+var overpassCategories = map[string]string{
+	CategoryRestaurant: `["amenity"="restaurant"]`,
+	CategoryCafe:       `["amenity"="cafe"]`,
+	CategoryBar:        `["amenity"~"^(bar|pub)$"]`,
+	CategoryPark:       `["leisure"="park"]`,
+}
+
+// OverpassProvider finds places in OpenStreetMap with the Overpass API.
+// OpenStreetMap doesn't have ratings, price levels or photos, so those are always empty
+// (and the price level and opening time of queries are ignored).
+// Opening hours come from the opening_hours tag when it is in a common form.
+type OverpassProvider struct {
+	// If empty, then the public Overpass instance is used
+	URL string
+	// If nil, then a client with a 10 second timeout is used
+	HTTPClient *http.Client
+}
+
+type overpassResponse struct {
+	Elements []overpassElement `json:"elements"`
+	Remark   string            `json:"remark"`
+}
+
+type overpassElement struct {
+	Type string  `json:"type"`
+	ID   int64   `json:"id"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+	// Set instead of Lat and Lon for ways and relations
+	Center *struct {
+		Lat float64 `json:"lat"`
+		Lon float64 `json:"lon"`
+	} `json:"center"`
+	Tags map[string]string `json:"tags"`
+}
+
+func (p *OverpassProvider) Nearby(ctx context.Context, query PlaceQuery) ([]types.Location, error) {
+	interpreterURL := p.URL
+	if interpreterURL == "" {
+		interpreterURL = overpassURL
+	}
+	filter, ok := overpassCategories[query.Category]
+	if !ok {
+		filter = fmt.Sprintf(`["amenity"=%q]`, query.Category)
+	}
+
+	// Places without names can't be voted on, so they are skipped
+	name := `["name"]`
+	if keywords := strings.Fields(query.Keyword); len(keywords) > 0 {
+		// Names that contain any of the words
+		for i := range keywords {
+			keywords[i] = regexp.QuoteMeta(keywords[i])
+		}
+		name = fmt.Sprintf(`["name"~%q,i]`, strings.Join(keywords, "|"))
+	}
+	overpassQuery := fmt.Sprintf(`[out:json][timeout:10];nwr%s%s(around:%d,%f,%f);out center %d;`,
+		filter, name, query.Radius, query.Center.Latitude, query.Center.Longitude, query.Limit)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, interpreterURL,
+		strings.NewReader(url.Values{"data": {overpassQuery}}.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := p.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Overpass: %w", withoutURL(err))
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("overpass query failed with status %d", res.StatusCode)
+	}
+
+	var response overpassResponse
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Overpass response: %w", err)
+	}
+	if response.Remark != "" && len(response.Elements) == 0 {
+		return nil, fmt.Errorf("overpass query failed: %s", response.Remark)
+	}
+
+	locations := make([]types.Location, 0, len(response.Elements))
+	for _, element := range response.Elements {
+		latitude, longitude := element.Lat, element.Lon
+		if element.Center != nil {
+			latitude, longitude = element.Center.Lat, element.Center.Lon
+		}
+		// OpenStreetMap doesn't give the place's time zone, so it is estimated from where the place is
+		hours := parseOSMOpeningHours(element.Tags["opening_hours"])
+		if hours != nil {
+			hours.UTCOffsetMinutes = estimatedUTCOffset(longitude)
+		}
+		locations = append(locations, types.Location{
+			Name:      element.Tags["name"],
+			Address:   overpassAddress(element.Tags),
+			Latitude:  latitude,
+			Longitude: longitude,
+			Provider:  ProviderOverpass,
+			PlaceID:   fmt.Sprintf("%s/%d", element.Type, element.ID),
+			URL:       element.Tags["website"],
+			Hours:     hours,
+		})
+	}
+	if len(locations) > query.Limit {
+		locations = locations[:query.Limit]
+	}
+	return locations, nil
+}
+
+// overpassAddress formats the address from the addr:* tags, such as "123 Main St, Atlanta"
+func overpassAddress(tags map[string]string) string {
+	street := strings.TrimSpace(tags["addr:housenumber"] + " " + tags["addr:street"])
+	var parts []string
+	for _, part := range []string{street, tags["addr:city"]} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
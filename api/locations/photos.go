@@ -0,0 +1,81 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	googlePlacePhotoURL = "https://maps.googleapis.com/maps/api/place/photo"
+	// Google doesn't return photos wider than this
+	MaxPhotoWidth = 1600
+	// Photos larger than this (in bytes) aren't served
+	MaxPhotoSize = 5 << 20
+)
+
+// ErrPhotoTooLarge is returned for photos larger than MaxPhotoSize
+var ErrPhotoTooLarge = errors.New("the photo is too large")
+
+// PhotoProvider is implemented by place providers whose photos can only be downloaded with credentials
+// (so they are served through the photo proxy rather than linked to)
+type PhotoProvider interface {
+	// Photo downloads the photo with the reference (from types.Location.Photo),
+	// scaled down to the width if it is wider
+	Photo(ctx context.Context, reference string, maxWidth int) (*Photo, error)
+}
+
+// Photo is a downloaded photo of a place
+type Photo struct {
+	ContentType string
+	Data        []byte
+}
+
+// Photo downloads a photo with the Place Photos API
+func (p *GoogleProvider) Photo(ctx context.Context, reference string, maxWidth int) (*Photo, error) {
+	photoURL := p.PhotoURL
+	if photoURL == "" {
+		photoURL = googlePlacePhotoURL
+	}
+
+	params := url.Values{}
+	params.Set("photo_reference", reference)
+	params.Set("maxwidth", fmt.Sprint(maxWidth))
+	params.Set("key", p.APIKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, photoURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Google redirects to the image, which the client follows
+	client := p.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Google Places photo: %w", withoutURL(err))
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("google places photo failed with HTTP status %d", res.StatusCode)
+	}
+	contentType := res.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("google places photo has content type '%s'", contentType)
+	}
+
+	// One byte more than the limit is read to tell whether the photo is too large
+	data, err := io.ReadAll(io.LimitReader(res.Body, MaxPhotoSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Google Places photo: %w", err)
+	}
+	if len(data) > MaxPhotoSize {
+		return nil, ErrPhotoTooLarge
+	}
+	return &Photo{ContentType: contentType, Data: data}, nil
+}
@@ -1,121 +1,127 @@
 package locations
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"log"
-	"net/http"
-	"net/http/httputil"
+	"context"
+	"strings"
+	"time"
 
-	"github.com/3-brain-cells/sah-backend/env"
 	"github.com/3-brain-cells/sah-backend/types"
 )
 
-// type Location struct {
-// 	Name    string `json:"name" bson:"name"`
-// 	Address string `json:"address" bson:"address"`
-// 	Rating  int    `json:"rating" bson:"rating"`
-// 	Image   string `json:"image" bson:"image"`
-// }
-
-func GetNearby(event types.Event) ([]types.Location, error) {
-	var midpoint types.Coordinates
-	midpoint.Latitude = 0
-	midpoint.Longitude = 0
+// MaxRadius is the largest distance (in meters) from the midpoint that places are searched for within
+// (the largest radius Google allows)
+const MaxRadius = 50000
+
+const (
+	// Places are searched for within this distance (in meters) of the midpoint
+	// unless the event sets its own radius
+	nearbyRadius = 1500
+	// If fewer places than this are found, then the radius is doubled (up to MaxRadius)
+	nearbyMinResults = 3
+	// NearbyLimit is the most places that are found as vote options
+	NearbyLimit = 10
+	// MaxCategories is the most categories an event's venue filters can have
+	MaxCategories = 4
+	// GetNearby makes at most this many searches in total (across categories and radii),
+	// so widening the radius can't keep the provider busy for long
+	maxNearbySearches = 12
+)
 
-	api_key, err := env.GetEnv("api_key", "GOOGLE_API_KEY")
-	if err != nil {
-		log.Fatal(err)
+// GetNearby finds places near the midpoint of the users' locations
+// that match the event's venue filters.
+// If openAt isn't zero, then it is passed on for the providers that can check opening hours.
+// The radius stops being widened once another round of searches would make more than maxNearbySearches.
+func GetNearby(ctx context.Context, provider PlaceProvider, midpoint types.Coordinates, filters types.VenueFilters, openAt time.Time) ([]types.Location, error) {
+	categories := filters.Categories
+	if len(categories) == 0 {
+		categories = []string{CategoryRestaurant}
 	}
-
-	for _, location := range event.UserLocations {
-		lat := location.Latitude
-		lng := location.Longitude
-
-		fmt.Println("coordinates: %v, %v",lat, lng)
-
-
-		midpoint.Latitude += lat
-		midpoint.Longitude += lng
-
+	radius := filters.RadiusMeters
+	if radius == 0 {
+		radius = nearbyRadius
 	}
 
-	midpoint.Latitude = midpoint.Latitude / float64(len(event.UserLocations))
-	midpoint.Longitude = midpoint.Longitude / float64(len(event.UserLocations))
-
-	fmt.Println("coordinates: %v, %v", midpoint.Latitude, midpoint.Longitude)
-
-	url := fmt.Sprintf("https://maps.googleapis.com/maps/api/place/nearbysearch/json?location=%v,%v&radius=1500&type=restaurant&key=%v", midpoint.Latitude, midpoint.Longitude, api_key)
-	method := "GET"
-
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, nil)
+	for searches := len(categories); ; searches += len(categories) {
+		// Each category is a separate search,
+		// and their results are interleaved so each category is represented
+		results := make([][]types.Location, len(categories))
+		for i, category := range categories {
+			locations, err := provider.Nearby(ctx, PlaceQuery{
+				Center:        midpoint,
+				Radius:        radius,
+				Category:      category,
+				Limit:         NearbyLimit,
+				Keyword:       strings.Join(filters.Keywords, " "),
+				MaxPriceLevel: filters.MaxPriceLevel,
+				OpenAt:        openAt,
+			})
+			if err != nil {
+				return nil, err
+			}
+			results[i] = matchingLocations(locations, filters)
+		}
 
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
-	}
-	// Dump request
-	httputil.DumpRequestOut(req, true)
-	res, err := client.Do(req)
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
+		locations := interleave(results, NearbyLimit)
+		if len(locations) >= nearbyMinResults || radius >= MaxRadius ||
+			searches+len(categories) > maxNearbySearches {
+			return locations, nil
+		}
+		radius = min(radius*2, MaxRadius)
 	}
-	defer res.Body.Close()
-	// Dump response
-	httputil.DumpResponse(res, true)
+}
 
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
+// matchingLocations leaves out the locations that don't match the filters
+// that providers can't apply themselves
+func matchingLocations(locations []types.Location, filters types.VenueFilters) []types.Location {
+	matching := []types.Location{}
+	for _, location := range locations {
+		if location.Rating != 0 && location.Rating < filters.MinRating {
+			continue
+		}
+		if location.PriceLevel != 0 && filters.MaxPriceLevel != 0 && location.PriceLevel > filters.MaxPriceLevel {
+			continue
+		}
+		matching = append(matching, location)
 	}
-	fmt.Println(string(body))
-
-	// parse body into list of locations
-	var woo map[string]interface{}
-
-	json.Unmarshal(body, &woo)
-	results := woo["results"].([]interface{})
-	// get the first 10 results from results
-	// parse these to get out rating, name, address, and image
-	locations := make([]types.Location, min(len(results), 10))
+	return matching
+}
 
-	for i, result := range results {
-		if i < 10 {
-			result := result.(map[string]interface{})
-			locations[i].Name = result["name"].(string)
-			locations[i].Address = result["vicinity"].(string)
-			
-			if result["photos"] != nil {
-				photos := result["photos"].([]interface{})
-				if len(photos) > 0 {
-					photo := photos[0].(map[string]interface{})["photo_reference"].(string)
-					locations[i].Image = fmt.Sprintf("https://maps.googleapis.com/maps/api/place/photo?maxwidth=400&photo_reference=%v&key=%v", photo, api_key)
-				}
-			} else {
-				locations[i].Image = result["icon"].(string)
+// interleave takes the first result of each list, then the second, and so on,
+// skipping places that are in more than one list
+func interleave(results [][]types.Location, limit int) []types.Location {
+	locations := []types.Location{}
+	seen := make(map[string]bool)
+	for i := 0; len(locations) < limit; i++ {
+		added := false
+		for _, list := range results {
+			if i >= len(list) || len(locations) >= limit {
+				continue
+			}
+			added = true
+			key := list[i].Provider + "/" + list[i].PlaceID
+			if list[i].PlaceID != "" && seen[key] {
+				continue
 			}
-			locations[i].Rating = results[i].(map[string]interface{})["rating"].(float64)
-			locations[i].Latitude = result["geometry"].(map[string]interface{})["location"].(map[string]interface{})["lat"].(float64)
-			locations[i].Longitude = result["geometry"].(map[string]interface{})["location"].(map[string]interface{})["lng"].(float64)
+			seen[key] = true
+			locations = append(locations, list[i])
+		}
+		if !added {
+			break
 		}
 	}
-	// for i, result := range results {
-	// 	result := result.(map[string]interface{})
-	// 	locations[i].Name = result["name"].(string)
-	// 	locations[i].Address = result["vicinity"].(string)
-	// 	locations[i].Image = result["icon"].(string)
-	// 	locations[i].Rating = result["rating"].(int)
-	// }
-	return locations, nil
+	return locations
 }
 
 func min(a, b int) int {
-    if a < b {
-        return a
-    }
-    return b
-}
\ No newline at end of file
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,109 @@
+package locations
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+// radiusProvider returns its places that are within the query's radius
+// and in the query's category
+type radiusProvider struct {
+	places  map[string][]types.Location
+	queries []PlaceQuery
+}
+
+func (p *radiusProvider) Nearby(ctx context.Context, query PlaceQuery) ([]types.Location, error) {
+	p.queries = append(p.queries, query)
+	center := toVector(query.Center.Latitude, query.Center.Longitude)
+	locations := []types.Location{}
+	for _, place := range p.places[query.Category] {
+		if angle(center, toVector(place.Latitude, place.Longitude))*6371000 <= float64(query.Radius) {
+			locations = append(locations, place)
+		}
+	}
+	return locations, nil
+}
+
+func TestGetNearby(t *testing.T) {
+	midpoint := types.Coordinates{Latitude: 33.7756, Longitude: -84.3963}
+	// Places the given distance north of the midpoint
+	place := func(name string, kilometers float64, rating float64, priceLevel int) types.Location {
+		return types.Location{
+			Name:       name,
+			Latitude:   midpoint.Latitude + kilometers/111,
+			Longitude:  midpoint.Longitude,
+			Rating:     rating,
+			PriceLevel: priceLevel,
+			Provider:   ProviderFake,
+			PlaceID:    name,
+		}
+	}
+	provider := &radiusProvider{places: map[string][]types.Location{
+		CategoryPark: {place("Park", 1, 0, 0), place("Far Park", 4, 4.8, 0)},
+		CategoryCafe: {place("Cafe", 1, 4.2, 1), place("Bad Cafe", 1, 2.5, 1), place("Fancy Cafe", 2.2, 4.9, 4), place("Park", 1, 0, 0)},
+	}}
+	openAt := time.Date(2022, time.March, 4, 18, 0, 0, 0, time.UTC)
+	filters := types.VenueFilters{
+		Categories:    []string{CategoryPark, CategoryCafe},
+		Keywords:      []string{"outdoor", "seating"},
+		RadiusMeters:  1000 + 100,
+		MinRating:     3,
+		MaxPriceLevel: 2,
+	}
+
+	locations, err := GetNearby(context.Background(), provider, midpoint, filters, openAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The badly rated and expensive cafes are left out, the park without a rating is kept,
+	// and the radius is doubled twice to find a third place
+	expected := []string{"Park", "Cafe", "Far Park"}
+	if len(locations) != len(expected) {
+		t.Fatalf("expected %v, got %+v", expected, locations)
+	}
+	for i, name := range expected {
+		if locations[i].Name != name {
+			t.Errorf("location %d: expected %s, got %s", i, name, locations[i].Name)
+		}
+	}
+
+	if len(provider.queries) != 6 {
+		t.Fatalf("expected 3 searches for each category, got %+v", provider.queries)
+	}
+	last := provider.queries[5]
+	if last.Radius != 4400 || last.Keyword != "outdoor seating" || last.MaxPriceLevel != 2 || !last.OpenAt.Equal(openAt) {
+		t.Errorf("expected the filters to be passed on with a widened radius, got %+v", last)
+	}
+}
+
+func TestGetNearbyMaxRadius(t *testing.T) {
+	provider := &radiusProvider{}
+	locations, err := GetNearby(context.Background(), provider, types.Coordinates{Latitude: 1, Longitude: 1}, types.VenueFilters{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 0 {
+		t.Errorf("expected no locations, got %+v", locations)
+	}
+	last := provider.queries[len(provider.queries)-1]
+	if last.Radius != MaxRadius || last.Category != CategoryRestaurant {
+		t.Errorf("expected restaurants to be searched for up to the max radius, got %+v", last)
+	}
+}
+
+func TestGetNearbySearchLimit(t *testing.T) {
+	provider := &radiusProvider{}
+	filters := types.VenueFilters{Categories: []string{CategoryPark, CategoryCafe, CategoryBar, CategoryRestaurant}}
+	_, err := GetNearby(context.Background(), provider, types.Coordinates{Latitude: 1, Longitude: 1}, filters, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Three rounds of four searches, rather than going on up to the max radius
+	if len(provider.queries) != maxNearbySearches {
+		t.Errorf("expected %d searches, got %d", maxNearbySearches, len(provider.queries))
+	}
+}
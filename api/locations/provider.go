@@ -0,0 +1,191 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/env"
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+const (
+	// Requests to place providers fail if they take longer than this
+	defaultTimeout = 10 * time.Second
+
+	ProviderGoogle   = "google"
+	ProviderYelp     = "yelp"
+	ProviderOverpass = "overpass"
+	ProviderFake     = "fake"
+	// Locations suggested by users rather than found by a provider
+	ProviderSuggested = "suggested"
+)
+
+// PlaceProvider finds places (such as restaurants) near a point
+type PlaceProvider interface {
+	// Nearby returns up to query.Limit places within the radius of the center,
+	// in the order the provider ranks them
+	Nearby(ctx context.Context, query PlaceQuery) ([]types.Location, error)
+}
+
+// PlaceQuery describes the places to search for
+type PlaceQuery struct {
+	Center types.Coordinates
+	// In meters
+	Radius int
+	// One of the categories below (or a provider-specific category)
+	Category string
+	Limit    int
+	// Optional words that the places should match
+	Keyword string
+	// If not 0, then only places with this price level or lower (1 to 4)
+	// are returned by providers that know the price levels
+	MaxPriceLevel int
+	// If not zero, then only places that are open at this time
+	// are returned by providers that support it
+	OpenAt time.Time
+}
+
+// Categories that every provider understands
+const (
+	CategoryRestaurant = "restaurant"
+	CategoryCafe       = "cafe"
+	CategoryBar        = "bar"
+	CategoryPark       = "park"
+)
+
+// Selector chooses the place provider to use for each guild
+type Selector struct {
+	defaultProvider PlaceProvider
+	guildProviders  map[string]PlaceProvider
+	// Maps name => provider, for the providers that are used by any guild
+	// (without the cache, since they are only used for what else they can do, such as photos)
+	namedProviders map[string]PlaceProvider
+}
+
+// NewSelector creates a selector that uses the default provider
+// for guilds that don't have their own provider
+func NewSelector(defaultProvider PlaceProvider, guildProviders map[string]PlaceProvider) *Selector {
+	if guildProviders == nil {
+		guildProviders = make(map[string]PlaceProvider)
+	}
+	return &Selector{
+		defaultProvider: defaultProvider,
+		guildProviders:  guildProviders,
+	}
+}
+
+// NewSelectorFromEnv creates a selector from the environment:
+// PLACE_PROVIDER is the provider for the deployment ("google" if not set),
+// and PLACE_PROVIDER_GUILDS optionally overrides it for some guilds
+// (as a comma-separated list of guild_id:provider pairs).
+// Only the API keys of the providers that are used are required.
+// If the cache isn't nil, then the providers' searches are cached in it.
+func NewSelectorFromEnv(cache *PlaceCache) (*Selector, error) {
+	defaultName := ProviderGoogle
+	if name, ok := os.LookupEnv("PLACE_PROVIDER"); ok && name != "" {
+		defaultName = name
+	}
+
+	guildNames := make(map[string]string)
+	if guilds, ok := os.LookupEnv("PLACE_PROVIDER_GUILDS"); ok && guilds != "" {
+		for _, pair := range strings.Split(guilds, ",") {
+			parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return nil, fmt.Errorf("invalid guild place provider '%s' (expected guild_id:provider)", pair)
+			}
+			guildNames[parts[0]] = parts[1]
+		}
+	}
+
+	// Each provider is only created once, even if it is used by many guilds
+	providers := make(map[string]PlaceProvider)
+	searchProviders := make(map[string]PlaceProvider)
+	providerNamed := func(name string) (PlaceProvider, error) {
+		if provider, ok := searchProviders[name]; ok {
+			return provider, nil
+		}
+		provider, err := newProviderFromEnv(name)
+		if err != nil {
+			return nil, err
+		}
+		providers[name] = provider
+		searchProviders[name] = provider
+		if cache != nil {
+			searchProviders[name] = cache.Wrap(name, provider)
+		}
+		return searchProviders[name], nil
+	}
+
+	defaultProvider, err := providerNamed(defaultName)
+	if err != nil {
+		return nil, err
+	}
+	guildProviders := make(map[string]PlaceProvider)
+	for guildID, name := range guildNames {
+		guildProviders[guildID], err = providerNamed(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	selector := NewSelector(defaultProvider, guildProviders)
+	selector.namedProviders = providers
+	return selector, nil
+}
+
+func newProviderFromEnv(name string) (PlaceProvider, error) {
+	switch name {
+	case ProviderGoogle:
+		apiKey, err := env.GetEnv("Google API key", "GOOGLE_API_KEY")
+		if err != nil {
+			return nil, err
+		}
+		debug := os.Getenv("GOOGLE_PLACES_DEBUG") == "true"
+		return &GoogleProvider{APIKey: apiKey, Debug: debug}, nil
+	case ProviderYelp:
+		apiKey, err := env.GetEnv("Yelp Fusion API key", "YELP_API_KEY")
+		if err != nil {
+			return nil, err
+		}
+		return &YelpProvider{APIKey: apiKey}, nil
+	case ProviderOverpass:
+		// The public Overpass instance is used if no URL is set
+		url, _ := env.GetEnv("Overpass API URL", "OVERPASS_URL")
+		return &OverpassProvider{URL: url}, nil
+	case ProviderFake:
+		return &FakeProvider{}, nil
+	default:
+		return nil, fmt.Errorf("unknown place provider '%s' (expected one of '%s', '%s', '%s' or '%s')",
+			name, ProviderGoogle, ProviderYelp, ProviderOverpass, ProviderFake)
+	}
+}
+
+// ForGuild returns the place provider to use for events in the guild
+func (s *Selector) ForGuild(guildID string) PlaceProvider {
+	if provider, ok := s.guildProviders[guildID]; ok {
+		return provider
+	}
+	return s.defaultProvider
+}
+
+// Named returns the provider with the name (such as "google"),
+// or false if no guild uses it
+func (s *Selector) Named(name string) (PlaceProvider, bool) {
+	provider, ok := s.namedProviders[name]
+	return provider, ok
+}
+
+// withoutURL removes the request URL from an error making a request,
+// since the URL can include coordinates, addresses or API keys that shouldn't be logged
+func withoutURL(err error) error {
+	var urlError *url.Error
+	if errors.As(err, &urlError) {
+		return fmt.Errorf("%s request failed: %w", urlError.Op, urlError.Err)
+	}
+	return err
+}
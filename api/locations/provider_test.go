@@ -0,0 +1,146 @@
+package locations
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+var testQuery = PlaceQuery{
+	Center:   types.Coordinates{Latitude: 33.7756, Longitude: -84.3963},
+	Radius:   1500,
+	Category: CategoryRestaurant,
+	Limit:    10,
+}
+
+func TestYelpProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"error": {"code": "TOKEN_INVALID", "description": "Invalid access token"}}`)
+			return
+		}
+		if r.URL.Query().Get("categories") != "restaurants" || r.URL.Query().Get("radius") != "1500" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		io.WriteString(w, `{"businesses": [{
+			"id": "pizza-place-atlanta",
+			"name": "Pizza Place",
+			"rating": 4.5,
+			"image_url": "https://example.com/pizza.jpg",
+			"url": "https://www.yelp.com/biz/pizza-place-atlanta",
+			"coordinates": {"latitude": 33.776, "longitude": -84.397},
+			"location": {"display_address": ["123 Main St", "Atlanta, GA 30332"]}
+		}]}`)
+	}))
+	defer server.Close()
+
+	provider := &YelpProvider{APIKey: "test-key", BaseURL: server.URL}
+	locations, err := provider.Nearby(context.Background(), testQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := types.Location{
+		Name:      "Pizza Place",
+		Address:   "123 Main St, Atlanta, GA 30332",
+		Rating:    4.5,
+		Image:     "https://example.com/pizza.jpg",
+		Latitude:  33.776,
+		Longitude: -84.397,
+		Provider:  ProviderYelp,
+		PlaceID:   "pizza-place-atlanta",
+		URL:       "https://www.yelp.com/biz/pizza-place-atlanta",
+	}
+	if len(locations) != 1 || locations[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, locations)
+	}
+
+	provider.APIKey = "wrong"
+	_, err = provider.Nearby(context.Background(), testQuery)
+	if err == nil || !strings.Contains(err.Error(), "TOKEN_INVALID") {
+		t.Errorf("expected Yelp's error, got %v", err)
+	}
+}
+
+func TestOverpassProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.FormValue("data")
+		if !strings.Contains(query, `["amenity"="restaurant"]`) || !strings.Contains(query, "around:1500,33.775600,-84.396300") {
+			t.Errorf("unexpected query %s", query)
+		}
+		io.WriteString(w, `{"elements": [
+			{"type": "node", "id": 1, "lat": 33.776, "lon": -84.397,
+			 "tags": {"name": "Taco Place", "addr:housenumber": "5", "addr:street": "Spring St", "addr:city": "Atlanta"}},
+			{"type": "way", "id": 2, "center": {"lat": 33.774, "lon": -84.395},
+			 "tags": {"name": "Noodle Place", "website": "https://noodles.example.com"}}
+		]}`)
+	}))
+	defer server.Close()
+
+	provider := &OverpassProvider{URL: server.URL}
+	locations, err := provider.Nearby(context.Background(), testQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []types.Location{
+		{Name: "Taco Place", Address: "5 Spring St, Atlanta", Latitude: 33.776, Longitude: -84.397, Provider: ProviderOverpass, PlaceID: "node/1"},
+		{Name: "Noodle Place", Latitude: 33.774, Longitude: -84.395, Provider: ProviderOverpass, PlaceID: "way/2", URL: "https://noodles.example.com"},
+	}
+	if len(locations) != len(expected) {
+		t.Fatalf("expected %d locations, got %+v", len(expected), locations)
+	}
+	for i := range expected {
+		if locations[i] != expected[i] {
+			t.Errorf("location %d: expected %+v, got %+v", i, expected[i], locations[i])
+		}
+	}
+}
+
+func TestSelectorFromEnv(t *testing.T) {
+	t.Setenv("PLACE_PROVIDER", "fake")
+	t.Setenv("PLACE_PROVIDER_GUILDS", "1234:overpass, 5678:fake")
+
+	selector, err := NewSelectorFromEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := selector.ForGuild("1234").(*OverpassProvider); !ok {
+		t.Errorf("expected guild 1234 to use Overpass, got %T", selector.ForGuild("1234"))
+	}
+	if selector.ForGuild("5678") != selector.ForGuild("other") {
+		t.Errorf("expected guilds using the same provider to share it")
+	}
+
+	// With a cache, searches go through it, but the named providers are the providers themselves
+	selector, err = NewSelectorFromEnv(NewPlaceCache(time.Hour, 10, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Unwrap(selector.ForGuild("1234")).(*OverpassProvider); !ok {
+		t.Errorf("expected guild 1234 to search with a cached Overpass, got %T", selector.ForGuild("1234"))
+	}
+	if provider, ok := selector.Named(ProviderOverpass); !ok || provider != Unwrap(selector.ForGuild("1234")) {
+		t.Errorf("expected the named Overpass provider to be the one guild 1234 uses, got %T", provider)
+	}
+
+	t.Setenv("PLACE_PROVIDER", "yelp")
+	t.Setenv("YELP_API_KEY", "")
+	os.Unsetenv("YELP_API_KEY")
+	_, err = NewSelectorFromEnv(nil)
+	if err == nil || !strings.Contains(err.Error(), "YELP_API_KEY") {
+		t.Errorf("expected an error about the missing API key, got %v", err)
+	}
+
+	t.Setenv("PLACE_PROVIDER", "carrier-pigeon")
+	_, err = NewSelectorFromEnv(nil)
+	if err == nil {
+		t.Errorf("expected an error for an unknown provider")
+	}
+}
@@ -0,0 +1,198 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/env"
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+// Ways of traveling to an event
+const (
+	ModeWalk    = "walk"
+	ModeTransit = "transit"
+	// ModeDrive is the default
+	ModeDrive = "drive"
+)
+
+const (
+	RouterEstimate = "estimate"
+	RouterOSRM     = "osrm"
+	RouterGoogle   = "google"
+)
+
+// NoRoute is the travel time between points that a router couldn't find a route between
+const NoRoute time.Duration = -1
+
+// ErrUnsupportedMode is returned by routers that can't find routes for a mode
+var ErrUnsupportedMode = errors.New("the router doesn't support the travel mode")
+
+// Router finds how long it takes to travel between points
+type Router interface {
+	// TravelTimes returns the travel time from each origin (the first index)
+	// to each destination (the second index), or NoRoute if there isn't a route
+	TravelTimes(ctx context.Context, origins []types.Coordinates, destinations []types.Coordinates, mode string) ([][]time.Duration, error)
+}
+
+// ValidTravelMode checks that the mode is one of the supported ones
+// (or empty, for the default)
+func ValidTravelMode(mode string) error {
+	switch mode {
+	case "", ModeWalk, ModeTransit, ModeDrive:
+		return nil
+	}
+	return fmt.Errorf("travel mode '%s' must be one of '%s', '%s' or '%s'", mode, ModeWalk, ModeTransit, ModeDrive)
+}
+
+// NewRouterFromEnv creates the router named by ROUTER ("estimate" if not set)
+func NewRouterFromEnv() (Router, error) {
+	name := RouterEstimate
+	if value, ok := os.LookupEnv("ROUTER"); ok && value != "" {
+		name = value
+	}
+
+	switch name {
+	case RouterEstimate:
+		return &EstimateRouter{}, nil
+	case RouterOSRM:
+		// The public OSRM demo server is used if no URL is set
+		url, _ := env.GetEnv("OSRM URL", "OSRM_URL")
+		return &OSRMRouter{URL: url}, nil
+	case RouterGoogle:
+		apiKey, err := env.GetEnv("Google API key", "GOOGLE_API_KEY")
+		if err != nil {
+			return nil, err
+		}
+		return &GoogleRouter{APIKey: apiKey}, nil
+	default:
+		return nil, fmt.Errorf("unknown router '%s' (expected one of '%s', '%s' or '%s')",
+			name, RouterEstimate, RouterOSRM, RouterGoogle)
+	}
+}
+
+// EstimateRouter estimates travel times from the straight-line distance
+// without making any requests
+type EstimateRouter struct{}
+
+const (
+	// Roads and paths are rarely straight,
+	// so the straight-line distance is multiplied by this
+	estimateDetourFactor = 1.3
+	// Time spent waiting for (and walking to) transit
+	estimateTransitWait = 10 * time.Minute
+)
+
+// Average speeds (in km/h) for each mode
+var estimateSpeeds = map[string]float64{
+	ModeWalk:    5,
+	ModeTransit: 25,
+	ModeDrive:   40,
+}
+
+func (r *EstimateRouter) TravelTimes(ctx context.Context, origins []types.Coordinates, destinations []types.Coordinates, mode string) ([][]time.Duration, error) {
+	speed, ok := estimateSpeeds[mode]
+	if !ok {
+		return nil, ErrUnsupportedMode
+	}
+
+	times := make([][]time.Duration, len(origins))
+	for i, origin := range origins {
+		times[i] = make([]time.Duration, len(destinations))
+		for j, destination := range destinations {
+			kilometers := angle(toVector(origin.Latitude, origin.Longitude), toVector(destination.Latitude, destination.Longitude)) *
+				earthRadius / 1000 * estimateDetourFactor
+			travelTime := time.Duration(kilometers / speed * float64(time.Hour))
+			if mode == ModeTransit {
+				travelTime += estimateTransitWait
+			}
+			times[i][j] = travelTime
+		}
+	}
+	return times, nil
+}
+
+// TravelRanking is how long it takes the users to get to each location
+type TravelRanking struct {
+	// The locations sorted by their total travel time (and then their worst travel time)
+	Locations []types.Location
+	// Maps user ID => the travel time to each location (in the same order)
+	UserTimes map[string][]time.Duration
+}
+
+// RankByTravelTime sorts the locations by the total travel time from every user that has a location,
+// breaking ties by the longest travel time of any one user.
+// Locations that some users have no route to are ranked last.
+func RankByTravelTime(ctx context.Context, router Router, userLocations map[string]types.UserLocation, locations []types.Location, mode string) (*TravelRanking, error) {
+	if mode == "" {
+		mode = ModeDrive
+	}
+
+	var userIDs []string
+	var origins []types.Coordinates
+	for userID, location := range userLocations {
+		if HasLocation(location) {
+			userIDs = append(userIDs, userID)
+			origins = append(origins, types.Coordinates{Latitude: location.Latitude, Longitude: location.Longitude})
+		}
+	}
+	destinations := make([]types.Coordinates, len(locations))
+	for i, location := range locations {
+		destinations[i] = types.Coordinates{Latitude: location.Latitude, Longitude: location.Longitude}
+	}
+
+	times := [][]time.Duration{}
+	if len(origins) > 0 && len(destinations) > 0 {
+		var err error
+		times, err = router.TravelTimes(ctx, origins, destinations, mode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	type ranked struct {
+		index int
+		total time.Duration
+		worst time.Duration
+	}
+	rankings := make([]ranked, len(locations))
+	for j := range locations {
+		rankings[j].index = j
+		for i := range origins {
+			travelTime := times[i][j]
+			if travelTime == NoRoute {
+				travelTime = time.Duration(math.MaxInt64 / int64(len(origins)+1))
+			}
+			rankings[j].total += travelTime
+			if travelTime > rankings[j].worst {
+				rankings[j].worst = travelTime
+			}
+		}
+	}
+	sort.SliceStable(rankings, func(a, b int) bool {
+		if rankings[a].total != rankings[b].total {
+			return rankings[a].total < rankings[b].total
+		}
+		return rankings[a].worst < rankings[b].worst
+	})
+
+	ranking := &TravelRanking{
+		Locations: make([]types.Location, len(locations)),
+		UserTimes: make(map[string][]time.Duration),
+	}
+	for i := range userIDs {
+		ranking.UserTimes[userIDs[i]] = make([]time.Duration, len(locations))
+	}
+	for newIndex, r := range rankings {
+		ranking.Locations[newIndex] = locations[r.index]
+		for i, userID := range userIDs {
+			ranking.UserTimes[userID][newIndex] = times[i][r.index]
+		}
+	}
+	return ranking, nil
+}
@@ -0,0 +1,193 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+// fixedRouter returns the same travel times for every request
+type fixedRouter struct {
+	times [][]time.Duration
+}
+
+func (r *fixedRouter) TravelTimes(ctx context.Context, origins []types.Coordinates, destinations []types.Coordinates, mode string) ([][]time.Duration, error) {
+	return r.times, nil
+}
+
+func TestEstimateRouter(t *testing.T) {
+	router := &EstimateRouter{}
+	origins := []types.Coordinates{{Latitude: 33.7756, Longitude: -84.3963}}
+	// About 11.1 km north
+	destinations := []types.Coordinates{{Latitude: 33.8756, Longitude: -84.3963}}
+
+	expected := map[string]time.Duration{
+		// 11.1 km * 1.3 at 5 km/h is about 173 minutes
+		ModeWalk: 173 * time.Minute,
+		// at 40 km/h it is about 22 minutes
+		ModeDrive: 22 * time.Minute,
+		// at 25 km/h it is about 35 minutes, plus the wait
+		ModeTransit: 45 * time.Minute,
+	}
+	for mode, want := range expected {
+		times, err := router.TravelTimes(context.Background(), origins, destinations, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := times[0][0] - want; diff < -time.Minute || diff > time.Minute {
+			t.Errorf("%s: travel time = %v, want about %v", mode, times[0][0], want)
+		}
+	}
+
+	_, err := router.TravelTimes(context.Background(), origins, destinations, "teleport")
+	if !errors.Is(err, ErrUnsupportedMode) {
+		t.Errorf("unknown mode: err = %v, want ErrUnsupportedMode", err)
+	}
+}
+
+func TestRankByTravelTime(t *testing.T) {
+	userLocations := map[string]types.UserLocation{
+		"alice": {Latitude: 33.7, Longitude: -84.4},
+		// Users without a location aren't routed
+		"bob": {},
+	}
+	locations := []types.Location{{Name: "far"}, {Name: "unreachable"}, {Name: "near"}}
+	router := &fixedRouter{times: [][]time.Duration{{30 * time.Minute, NoRoute, 10 * time.Minute}}}
+
+	ranking, err := RankByTravelTime(context.Background(), router, userLocations, locations, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, location := range ranking.Locations {
+		names = append(names, location.Name)
+	}
+	if strings.Join(names, ",") != "near,far,unreachable" {
+		t.Errorf("ranked locations = %v, want [near far unreachable]", names)
+	}
+	if _, ok := ranking.UserTimes["bob"]; ok {
+		t.Errorf("the user without a location has travel times")
+	}
+	expected := []time.Duration{10 * time.Minute, 30 * time.Minute, NoRoute}
+	for i, travelTime := range ranking.UserTimes["alice"] {
+		if travelTime != expected[i] {
+			t.Errorf("alice's travel time to location %d = %v, want %v", i, travelTime, expected[i])
+		}
+	}
+}
+
+func TestRankByTravelTimeBreaksTiesByWorstTime(t *testing.T) {
+	userLocations := map[string]types.UserLocation{
+		"alice": {Latitude: 33.7, Longitude: -84.4},
+		"bob":   {Latitude: 33.8, Longitude: -84.3},
+	}
+	locations := []types.Location{{Name: "uneven"}, {Name: "even"}}
+	// Both locations take 40 minutes in total, but one user travels 35 minutes to the uneven one
+	router := &fixedRouter{times: [][]time.Duration{
+		{35 * time.Minute, 20 * time.Minute},
+		{5 * time.Minute, 20 * time.Minute},
+	}}
+
+	ranking, err := RankByTravelTime(context.Background(), router, userLocations, locations, ModeWalk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ranking.Locations[0].Name != "even" {
+		t.Errorf("first location = %s, want even", ranking.Locations[0].Name)
+	}
+}
+
+func TestOSRMRouter(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path + "?" + r.URL.RawQuery
+		fmt.Fprint(w, `{"code": "Ok", "durations": [[600, null]]}`)
+	}))
+	defer server.Close()
+
+	router := &OSRMRouter{URL: server.URL}
+	origins := []types.Coordinates{{Latitude: 33.7, Longitude: -84.4}}
+	destinations := []types.Coordinates{{Latitude: 33.8, Longitude: -84.3}, {Latitude: 40, Longitude: -80}}
+	times, err := router.TravelTimes(context.Background(), origins, destinations, ModeWalk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if times[0][0] != 10*time.Minute || times[0][1] != NoRoute {
+		t.Errorf("travel times = %v, want [[10m0s -1ns]]", times)
+	}
+	expectedPath := "/table/v1/foot/-84.400000,33.700000;-84.300000,33.800000;-80.000000,40.000000?sources=0&destinations=1;2&annotations=duration"
+	if path != expectedPath {
+		t.Errorf("request = %s, want %s", path, expectedPath)
+	}
+
+	_, err = router.TravelTimes(context.Background(), origins, destinations, ModeTransit)
+	if !errors.Is(err, ErrUnsupportedMode) {
+		t.Errorf("transit: err = %v, want ErrUnsupportedMode", err)
+	}
+}
+
+func TestGoogleRouterBatches(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Query().Get("mode") != "transit" || r.URL.Query().Get("key") != "test-key" {
+			fmt.Fprint(w, `{"status": "REQUEST_DENIED", "error_message": "bad request"}`)
+			return
+		}
+		origins := strings.Split(r.URL.Query().Get("origins"), "|")
+		destinations := strings.Split(r.URL.Query().Get("destinations"), "|")
+		var rows []string
+		for range origins {
+			elements := make([]string, len(destinations))
+			for j := range destinations {
+				elements[j] = fmt.Sprintf(`{"status": "OK", "duration": {"value": %d}}`, (j+1)*60)
+			}
+			elements[len(elements)-1] = `{"status": "ZERO_RESULTS"}`
+			rows = append(rows, `{"elements": [`+strings.Join(elements, ",")+`]}`)
+		}
+		fmt.Fprint(w, `{"status": "OK", "rows": [`+strings.Join(rows, ",")+`]}`)
+	}))
+	defer server.Close()
+
+	// 30 origins and 10 destinations is 300 elements, which takes 3 requests
+	origins := make([]types.Coordinates, 30)
+	destinations := make([]types.Coordinates, 10)
+	router := &GoogleRouter{APIKey: "test-key", BaseURL: server.URL}
+	times, err := router.TravelTimes(context.Background(), origins, destinations, ModeTransit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 {
+		t.Errorf("made %d requests, want 3", requests)
+	}
+	if len(times) != 30 || times[29][1] != 2*time.Minute || times[29][9] != NoRoute {
+		t.Errorf("unexpected travel times: %v", times)
+	}
+
+	// 30 destinations are more than Google allows in one request, so they are split too
+	requests = 0
+	times, err = router.TravelTimes(context.Background(), origins[:2], make([]types.Coordinates, 30), ModeTransit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("made %d requests, want 2", requests)
+	}
+	if len(times) != 2 || len(times[1]) != 30 || times[1][24] != NoRoute || times[1][25] != time.Minute {
+		t.Errorf("unexpected travel times: %v", times)
+	}
+
+	router.APIKey = "wrong-key"
+	_, err = router.TravelTimes(context.Background(), origins[:1], destinations, ModeTransit)
+	var googleErr *GoogleError
+	if !errors.As(err, &googleErr) || googleErr.Status != "REQUEST_DENIED" {
+		t.Errorf("err = %v, want a REQUEST_DENIED GoogleError", err)
+	}
+}
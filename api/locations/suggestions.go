@@ -0,0 +1,91 @@
+package locations
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+// Locations closer than this (in meters) with similar names are treated as the same place
+const duplicateDistance = 100
+
+// MergeSuggestions combines the venues that users suggested with the places a provider found.
+// Suggestions come first, and then found places are added, up to the limit in total.
+// A found place that is the same as a suggestion isn't added again,
+// but fills in the details (such as the rating and photo) that the suggestion doesn't have.
+func MergeSuggestions(suggested []types.Location, found []types.Location, limit int) []types.Location {
+	merged := []types.Location{}
+	for _, location := range suggested {
+		if duplicate(merged, location) == -1 && len(merged) < limit {
+			merged = append(merged, location)
+		}
+	}
+	for _, location := range found {
+		i := duplicate(merged, location)
+		switch {
+		case i != -1 && merged[i].Provider == ProviderSuggested:
+			merged[i] = fillDetails(merged[i], location)
+		case i == -1 && len(merged) < limit:
+			merged = append(merged, location)
+		}
+	}
+	return merged
+}
+
+// duplicate returns the index of the location that is the same venue, or -1 if there isn't one
+func duplicate(locations []types.Location, location types.Location) int {
+	for i := range locations {
+		if sameVenue(locations[i], location) {
+			return i
+		}
+	}
+	return -1
+}
+
+// fillDetails fills in the details of a suggested venue from the same place found by a provider
+// (including the provider's ID, so the place can be looked up later)
+func fillDetails(suggestion types.Location, found types.Location) types.Location {
+	if suggestion.Address == "" {
+		suggestion.Address = found.Address
+	}
+	if suggestion.URL == "" {
+		suggestion.URL = found.URL
+	}
+	suggestion.Rating = found.Rating
+	suggestion.Image = found.Image
+	suggestion.Photo = found.Photo
+	suggestion.PriceLevel = found.PriceLevel
+	suggestion.Provider = found.Provider
+	suggestion.PlaceID = found.PlaceID
+	return suggestion
+}
+
+// sameVenue checks whether the locations are near each other with similar names
+// (where one name contains the other, ignoring case, punctuation and spaces)
+func sameVenue(a types.Location, b types.Location) bool {
+	if a.Provider == b.Provider && a.PlaceID != "" && a.PlaceID == b.PlaceID {
+		return true
+	}
+	distance := angle(toVector(a.Latitude, a.Longitude), toVector(b.Latitude, b.Longitude)) * earthRadius
+	if distance > duplicateDistance {
+		return false
+	}
+	aName, bName := normalizeName(a.Name), normalizeName(b.Name)
+	if aName == "" || bName == "" {
+		return false
+	}
+	return strings.Contains(aName, bName) || strings.Contains(bName, aName)
+}
+
+// normalizeName lowercases the name and removes a leading "the"
+// and everything but letters and digits
+func normalizeName(name string) string {
+	var normalized strings.Builder
+	for _, r := range strings.TrimPrefix(strings.ToLower(name), "the ") {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			normalized.WriteRune(r)
+		}
+	}
+	return normalized.String()
+}
@@ -0,0 +1,48 @@
+package locations
+
+import (
+	"testing"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+func TestMergeSuggestions(t *testing.T) {
+	suggested := []types.Location{
+		{Name: "The Pizza Place", Latitude: 33.7760, Longitude: -84.3970, Provider: ProviderSuggested, PlaceID: "a", SuggestedBy: "001"},
+		// Suggested again by someone else, a few meters away
+		{Name: "pizza place!", Latitude: 33.7761, Longitude: -84.3970, Provider: ProviderSuggested, PlaceID: "b", SuggestedBy: "002"},
+		{Name: "Bowling Alley", Address: "1 Lane Rd", Latitude: 33.8000, Longitude: -84.4000, Provider: ProviderSuggested, PlaceID: "c", SuggestedBy: "002"},
+	}
+	found := []types.Location{
+		{Name: "Pizza Place Midtown", Address: "123 Main St", Rating: 4.4, Image: "photo", Latitude: 33.7762, Longitude: -84.3971, Provider: ProviderGoogle, PlaceID: "place-1"},
+		// The same name, but across town
+		{Name: "Bowling Alley", Latitude: 33.7000, Longitude: -84.4000, Provider: ProviderGoogle, PlaceID: "place-2"},
+		{Name: "Taco Stand", Latitude: 33.7750, Longitude: -84.3960, Provider: ProviderGoogle, PlaceID: "place-3"},
+		{Name: "Noodle House", Latitude: 33.7740, Longitude: -84.3950, Provider: ProviderGoogle, PlaceID: "place-4"},
+	}
+
+	merged := MergeSuggestions(suggested, found, 4)
+	expected := []string{"The Pizza Place", "Bowling Alley", "Bowling Alley", "Taco Stand"}
+	if len(merged) != len(expected) {
+		t.Fatalf("expected %v, got %+v", expected, merged)
+	}
+	for i, name := range expected {
+		if merged[i].Name != name {
+			t.Errorf("location %d: expected %s, got %s", i, name, merged[i].Name)
+		}
+	}
+
+	// The suggestion keeps its name and who suggested it, but gets the found place's details
+	pizza := merged[0]
+	if pizza.SuggestedBy != "001" || pizza.Rating != 4.4 || pizza.Address != "123 Main St" || pizza.Provider != ProviderGoogle || pizza.PlaceID != "place-1" {
+		t.Errorf("expected the suggestion to be filled in from the found place, got %+v", pizza)
+	}
+	if merged[1].Address != "1 Lane Rd" || merged[1].Provider != ProviderSuggested {
+		t.Errorf("expected the suggestion across town to be unchanged, got %+v", merged[1])
+	}
+
+	// Suggestions count toward the limit too
+	if merged := MergeSuggestions(suggested, found, 1); len(merged) != 1 || merged[0].Name != "The Pizza Place" {
+		t.Errorf("expected only the first suggestion, got %+v", merged)
+	}
+}
@@ -0,0 +1,230 @@
+package locations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+const (
+	yelpSearchURL  = "https://api.yelp.com/v3/businesses/search"
+	yelpDetailsURL = "https://api.yelp.com/v3/businesses"
+	// Yelp rejects larger radiuses and limits
+	yelpMaxRadius = 40000
+	yelpMaxLimit  = 50
+)
+
+// Yelp's names for the shared categories
+var yelpCategories = map[string]string{
+	CategoryRestaurant: "restaurants",
+	CategoryCafe:       "cafes",
+	CategoryBar:        "bars",
+	CategoryPark:       "parks",
+}
+
+// YelpProvider finds places with the Yelp Fusion business search API
+type YelpProvider struct {
+	APIKey string
+	// If nil, then a client with a 10 second timeout is used
+	HTTPClient *http.Client
+	// If empty, then the Yelp Fusion API is used
+	BaseURL string
+	// If empty, then the Yelp Fusion business details API is used (to look up opening hours)
+	DetailsURL string
+}
+
+type yelpSearchResponse struct {
+	Businesses []yelpBusiness `json:"businesses"`
+	Error      *struct {
+		Code        string `json:"code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+type yelpBusiness struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Rating   float64 `json:"rating"`
+	ImageURL string  `json:"image_url"`
+	URL      string  `json:"url"`
+	// Such as "$$"
+	Price       string `json:"price"`
+	Coordinates struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	} `json:"coordinates"`
+	Location struct {
+		DisplayAddress []string `json:"display_address"`
+	} `json:"location"`
+}
+
+func (p *YelpProvider) Nearby(ctx context.Context, query PlaceQuery) ([]types.Location, error) {
+	baseURL := p.BaseURL
+	if baseURL == "" {
+		baseURL = yelpSearchURL
+	}
+	category, ok := yelpCategories[query.Category]
+	if !ok {
+		category = query.Category
+	}
+
+	params := url.Values{}
+	params.Set("latitude", fmt.Sprint(query.Center.Latitude))
+	params.Set("longitude", fmt.Sprint(query.Center.Longitude))
+	params.Set("radius", fmt.Sprint(min(query.Radius, yelpMaxRadius)))
+	params.Set("categories", category)
+	params.Set("limit", fmt.Sprint(min(query.Limit, yelpMaxLimit)))
+	if query.Keyword != "" {
+		params.Set("term", query.Keyword)
+	}
+	if query.MaxPriceLevel > 0 {
+		// Yelp takes every allowed price level, such as "1,2"
+		var levels []string
+		for level := 1; level <= query.MaxPriceLevel; level++ {
+			levels = append(levels, fmt.Sprint(level))
+		}
+		params.Set("price", strings.Join(levels, ","))
+	}
+	if !query.OpenAt.IsZero() {
+		params.Set("open_at", fmt.Sprint(query.OpenAt.Unix()))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.APIKey)
+
+	client := p.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search Yelp: %w", withoutURL(err))
+	}
+	defer res.Body.Close()
+
+	var response yelpSearchResponse
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Yelp response (status %d): %w", res.StatusCode, err)
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("yelp search failed with %s: %s", response.Error.Code, response.Error.Description)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("yelp search failed with status %d", res.StatusCode)
+	}
+
+	locations := make([]types.Location, 0, len(response.Businesses))
+	for _, business := range response.Businesses {
+		locations = append(locations, types.Location{
+			Name:       business.Name,
+			Address:    strings.Join(business.Location.DisplayAddress, ", "),
+			Rating:     business.Rating,
+			Image:      business.ImageURL,
+			Latitude:   business.Coordinates.Latitude,
+			Longitude:  business.Coordinates.Longitude,
+			Provider:   ProviderYelp,
+			PlaceID:    business.ID,
+			URL:        business.URL,
+			PriceLevel: len(business.Price),
+		})
+	}
+	if len(locations) > query.Limit {
+		locations = locations[:query.Limit]
+	}
+	return locations, nil
+}
+
+type yelpDetailsResponse struct {
+	Hours []struct {
+		// Such as "REGULAR"
+		HoursType string `json:"hours_type"`
+		Open      []struct {
+			// From 0 (Monday) to 6 (Sunday)
+			Day int `json:"day"`
+			// Such as "1730"
+			Start string `json:"start"`
+			End   string `json:"end"`
+		} `json:"open"`
+	} `json:"hours"`
+	Error *struct {
+		Code        string `json:"code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+// OpeningHours looks up the regular opening hours of a business with the business details API
+func (p *YelpProvider) OpeningHours(ctx context.Context, location types.Location) (*types.OpeningHours, error) {
+	if location.Provider != ProviderYelp || location.PlaceID == "" {
+		return nil, nil
+	}
+	detailsURL := p.DetailsURL
+	if detailsURL == "" {
+		detailsURL = yelpDetailsURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(detailsURL, "/")+"/"+url.PathEscape(location.PlaceID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.APIKey)
+
+	client := p.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Yelp business: %w", withoutURL(err))
+	}
+	defer res.Body.Close()
+
+	var response yelpDetailsResponse
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Yelp response (status %d): %w", res.StatusCode, err)
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("yelp business lookup failed with %s: %s", response.Error.Code, response.Error.Description)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("yelp business lookup failed with status %d", res.StatusCode)
+	}
+
+	for _, hours := range response.Hours {
+		if hours.HoursType != "REGULAR" {
+			continue
+		}
+		// Yelp doesn't give the business's time zone, so it is estimated from where the business is
+		openingHours := &types.OpeningHours{Periods: []types.OpeningPeriod{}, UTCOffsetMinutes: estimatedUTCOffset(location.Longitude)}
+		for _, open := range hours.Open {
+			// Yelp's weeks start on Monday
+			day := time.Weekday((open.Day + 1) % 7)
+			start, err := parseHHMM(day, open.Start)
+			if err != nil {
+				return nil, err
+			}
+			end, err := parseHHMM(day, open.End)
+			if err != nil {
+				return nil, err
+			}
+			if weekMinute(end) <= weekMinute(start) {
+				// Such as bars that close after midnight
+				end = weeklyTime(weekMinute(end) + 24*60)
+			}
+			openingHours.Periods = append(openingHours.Periods, types.OpeningPeriod{Open: start, Close: end})
+		}
+		return openingHours, nil
+	}
+	return nil, nil
+}
@@ -31,7 +31,7 @@ import (
 // but in real apps you must provide a proper function that generates a state.
 var state = "random"
 
-func Routes(database db.Provider) *chi.Mux {
+func Routes(database db.Provider, sessions *Sessions) *chi.Mux {
 	router := chi.NewRouter()
 
 	clientID, err := env.GetEnv("token", "BOT_ID")
@@ -44,12 +44,12 @@ func Routes(database db.Provider) *chi.Mux {
 		log.Fatal(err)
 	}
 
-	oath_config(clientID, secret, router)
+	oath_config(clientID, secret, sessions, router)
 
 	return router
 }
 
-func oath_config(id string, secret string, router *chi.Mux) {
+func oath_config(id string, secret string, sessions *Sessions, router *chi.Mux) {
 	// Create a config.
 	conf := oauth2.Config{
 		RedirectURL:  "https://kairosaio.com/auth/callback",
@@ -118,9 +118,17 @@ func oath_config(id string, secret string, router *chi.Mux) {
 			return
 		}
 
+		userID, ok := decodedJson["id"].(string)
+		if !ok || userID == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Discord didn't return the user's ID"))
+			return
+		}
+
 		// Step 7: the SAH backend redirects the user's tab to the SAH frontend
-		// and tells it who the user is
-		userID := decodedJson["id"]
-		http.Redirect(w, r, fmt.Sprintf("https://super-auto-hangouts.netlify.app/%s/%s?user_id=%s", src, eventID, userID), http.StatusTemporaryRedirect)
+		// and tells it who the user is, along with a session token that proves it
+		// (in the fragment, so it isn't sent on to any server)
+		http.Redirect(w, r, fmt.Sprintf("https://super-auto-hangouts.netlify.app/%s/%s?user_id=%s#session=%s",
+			src, eventID, userID, sessions.Issue(userID)), http.StatusTemporaryRedirect)
 	})
 }
@@ -0,0 +1,145 @@
+package oauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/util"
+	"github.com/go-chi/chi"
+)
+
+// Sessions last this long unless SESSION_MAX_AGE is set
+const defaultSessionMaxAge = 30 * 24 * time.Hour
+
+var (
+	errNoSession      = errors.New("log in with Discord to continue")
+	errInvalidSession = errors.New("the session is invalid or has expired (log in with Discord again)")
+	errOtherUser      = errors.New("the session belongs to a different user")
+)
+
+// Sessions issues and checks the signed tokens that identify users who logged in with Discord.
+// The token is given to the frontend by the OAuth callback,
+// which sends it back as an "Authorization: Bearer <token>" header.
+type Sessions struct {
+	key    []byte
+	maxAge time.Duration
+}
+
+// NewSessions creates sessions signed with the key that last for maxAge
+func NewSessions(key []byte, maxAge time.Duration) *Sessions {
+	return &Sessions{key: key, maxAge: maxAge}
+}
+
+// NewSessionsFromEnv creates sessions from the environment:
+// SESSION_KEY is the signing key (at least 32 bytes encoded as base64)
+// and SESSION_MAX_AGE is how long sessions last ("720h" if not set).
+func NewSessionsFromEnv() (*Sessions, error) {
+	key, err := base64.StdEncoding.DecodeString(os.Getenv("SESSION_KEY"))
+	if err != nil || len(key) < 32 {
+		return nil, errors.New("SESSION_KEY must be at least 32 bytes encoded as base64")
+	}
+
+	maxAge := defaultSessionMaxAge
+	if value, ok := os.LookupEnv("SESSION_MAX_AGE"); ok && value != "" {
+		maxAge, err = time.ParseDuration(value)
+		if err != nil || maxAge <= 0 {
+			return nil, fmt.Errorf("invalid SESSION_MAX_AGE '%s' (expected a duration such as '720h')", value)
+		}
+	}
+	return NewSessions(key, maxAge), nil
+}
+
+// Issue creates a session token for the user
+func (s *Sessions) Issue(userID string) string {
+	payload := fmt.Sprintf("%s.%d",
+		base64.RawURLEncoding.EncodeToString([]byte(userID)), time.Now().Add(s.maxAge).Unix())
+	return payload + "." + s.sign(payload)
+}
+
+// UserID returns the ID of the user whose session token the request has
+func (s *Sessions) UserID(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", errNoSession
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errInvalidSession
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(payload))) {
+		return "", errInvalidSession
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() >= expiresAt {
+		return "", errInvalidSession
+	}
+	userID, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(userID) == 0 {
+		return "", errInvalidSession
+	}
+	return string(userID), nil
+}
+
+// IsUser returns whether the request has a session for the user
+func (s *Sessions) IsUser(r *http.Request, userID string) bool {
+	sessionUserID, err := s.UserID(r)
+	return err == nil && sessionUserID == userID
+}
+
+// RequireUser only lets requests through if they have a session
+// for the user in the user_id URL parameter
+func (s *Sessions) RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := s.UserID(r)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusUnauthorized)
+			return
+		}
+		if userID != chi.URLParam(r, "user_id") {
+			util.ErrorWithCode(r, w, errOtherUser, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sessionUserKey is the context key for the ID of the user whose session a request has
+type sessionUserKey struct{}
+
+// RequireSession only lets requests through if they have a session,
+// making the session's user ID available to the handler through SessionUser
+func (s *Sessions) RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := s.UserID(r)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionUserKey{}, userID)))
+	})
+}
+
+// SessionUser returns the ID of the user whose session was checked by RequireSession
+// (or an empty string if it wasn't)
+func SessionUser(ctx context.Context) string {
+	userID, _ := ctx.Value(sessionUserKey{}).(string)
+	return userID
+}
+
+func (s *Sessions) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,102 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+var testSessionKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestSessions(t *testing.T) {
+	sessions := NewSessions(testSessionKey, time.Hour)
+	token := sessions.Issue("001")
+
+	request := func(token string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		return r
+	}
+
+	userID, err := sessions.UserID(request(token))
+	if err != nil || userID != "001" {
+		t.Errorf("user ID = %q (err = %v), want 001", userID, err)
+	}
+
+	parts := strings.Split(token, ".")
+	invalid := map[string]string{
+		"no token": "",
+		// Changing the user invalidates the signature
+		"other user":  "MDAy." + parts[1] + "." + parts[2],
+		"other key":   NewSessions([]byte("another key that is 32 bytes long"), time.Hour).Issue("001"),
+		"expired":     NewSessions(testSessionKey, -time.Minute).Issue("001"),
+		"not a token": "001",
+	}
+	for name, token := range invalid {
+		if userID, err := sessions.UserID(request(token)); err == nil {
+			t.Errorf("%s: user ID = %q, want an error", name, userID)
+		}
+	}
+}
+
+func TestRequireUser(t *testing.T) {
+	sessions := NewSessions(testSessionKey, time.Hour)
+	router := chi.NewRouter()
+	router.With(sessions.RequireUser).Get("/{user_id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	expectedCodes := map[string]int{
+		"":                        http.StatusUnauthorized,
+		sessions.Issue("001"):     http.StatusNoContent,
+		sessions.Issue("002"):     http.StatusForbidden,
+		sessions.Issue("001")[1:]: http.StatusUnauthorized,
+	}
+	for token, expected := range expectedCodes {
+		r := httptest.NewRequest(http.MethodGet, "/001", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		if recorder.Code != expected {
+			t.Errorf("token %q: status = %d, want %d", token, recorder.Code, expected)
+		}
+	}
+}
+
+func TestRequireSession(t *testing.T) {
+	sessions := NewSessions(testSessionKey, time.Hour)
+	var userID string
+	handler := sessions.RequireSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID = SessionUser(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	expectedCodes := map[string]int{
+		"":                        http.StatusUnauthorized,
+		sessions.Issue("002"):     http.StatusNoContent,
+		sessions.Issue("002")[1:]: http.StatusUnauthorized,
+	}
+	for token, expected := range expectedCodes {
+		userID = ""
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		if recorder.Code != expected {
+			t.Errorf("token %q: status = %d, want %d", token, recorder.Code, expected)
+		}
+		if expected == http.StatusNoContent && userID != "002" {
+			t.Errorf("token %q: session user = %q, want 002", token, userID)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package photos
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/3-brain-cells/sah-backend/api/locations"
+)
+
+// Cache keeps the most recently served photos in memory, up to a total size in bytes
+// (evicting the least recently used photos first)
+type Cache struct {
+	maxBytes int
+	// Photos larger than this aren't cached (so one photo can't evict all of the others)
+	maxPhotoBytes int
+
+	mu      sync.Mutex
+	bytes   int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key   string
+	photo *locations.Photo
+}
+
+// NewCache creates a cache that holds up to maxBytes of photos,
+// each of which can be at most a quarter of that
+func NewCache(maxBytes int) *Cache {
+	return &Cache{
+		maxBytes:      maxBytes,
+		maxPhotoBytes: maxBytes / 4,
+		order:         list.New(),
+		entries:       make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached photo (or false if it isn't cached)
+func (c *Cache) Get(key string) (*locations.Photo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).photo, true
+}
+
+// Add caches the photo, evicting the least recently used photos to make room.
+// Photos that are too large to cache are ignored.
+func (c *Cache) Add(key string, photo *locations.Photo) {
+	size := len(photo.Data)
+	if size > c.maxPhotoBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	for c.bytes+size > c.maxBytes && c.order.Len() > 0 {
+		c.remove(c.order.Back())
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, photo: photo})
+	c.bytes += size
+}
+
+func (c *Cache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= len(entry.photo.Data)
+}
@@ -0,0 +1,132 @@
+package photos
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/3-brain-cells/sah-backend/api/locations"
+	"github.com/3-brain-cells/sah-backend/db"
+	"github.com/3-brain-cells/sah-backend/util"
+	"github.com/go-chi/chi"
+)
+
+const (
+	// How many bytes of photos are cached unless PHOTO_CACHE_BYTES is set
+	defaultCacheBytes = 64 << 20
+	// The width photos are scaled down to unless the request asks for another one of photoWidths
+	defaultPhotoWidth = 400
+	// Clients can cache photos for this long (in seconds)
+	photoMaxAge = 7 * 24 * 60 * 60
+)
+
+// The widths that photos can be scaled down to,
+// so that each photo is downloaded and cached at only a few sizes
+var photoWidths = []int{200, defaultPhotoWidth, 800, locations.MaxPhotoWidth}
+
+// NewCacheFromEnv creates a photo cache that holds PHOTO_CACHE_BYTES of photos (64 MiB if not set)
+func NewCacheFromEnv() (*Cache, error) {
+	maxBytes := defaultCacheBytes
+	if value, ok := os.LookupEnv("PHOTO_CACHE_BYTES"); ok && value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid PHOTO_CACHE_BYTES '%s' (expected a number of bytes)", value)
+		}
+		maxBytes = parsed
+	}
+	return NewCache(maxBytes), nil
+}
+
+func Routes(database db.PhotoReferenceProvider, places *locations.Selector, cache *Cache) *chi.Mux {
+	router := chi.NewRouter()
+
+	router.Get("/{provider}/{reference}", GetPhoto(database, places.Named, cache))
+
+	return router
+}
+
+// GetPhoto serves a photo of a place from its provider,
+// so that the provider's API key is never sent to clients.
+// Only the photos of venues that are being voted on are served,
+// so the proxy can't be used to download any photo with the API key.
+// The photo is scaled down to the optional max_width query parameter (400 pixels if not set).
+// The provider is found by its name (such as with locations.Selector.Named).
+func GetPhoto(photoReferenceProvider db.PhotoReferenceProvider, namedProvider func(name string) (locations.PlaceProvider, bool), cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		providerName := chi.URLParam(r, "provider")
+		if providerName == "" {
+			util.ErrorWithCode(r, w, errors.New("the provider URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+		reference := chi.URLParam(r, "reference")
+		if reference == "" {
+			util.ErrorWithCode(r, w, errors.New("the photo reference URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		maxWidth := defaultPhotoWidth
+		if value := r.URL.Query().Get("max_width"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || !validWidth(parsed) {
+				util.ErrorWithCode(r, w, fmt.Errorf("max_width must be one of %v pixels", photoWidths),
+					http.StatusBadRequest)
+				return
+			}
+			maxWidth = parsed
+		}
+
+		provider, ok := namedProvider(providerName)
+		photoProvider, hasPhotos := provider.(locations.PhotoProvider)
+		if !ok || !hasPhotos {
+			util.ErrorWithCode(r, w, fmt.Errorf("photos from provider '%s' aren't served", providerName),
+				http.StatusNotFound)
+			return
+		}
+
+		key := fmt.Sprintf("%s/%s/%d", providerName, reference, maxWidth)
+		photo, cached := cache.Get(key)
+		log.Printf("GetPhoto provider=%s max_width=%d cached=%t", providerName, maxWidth, cached)
+		if !cached {
+			stored, err := photoReferenceProvider.HasVenuePhoto(r.Context(), providerName, reference)
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+			if !stored {
+				util.ErrorWithCode(r, w, errors.New("the photo isn't of a venue being voted on"),
+					http.StatusNotFound)
+				return
+			}
+			photo, err = photoProvider.Photo(r.Context(), reference, maxWidth)
+			if err != nil {
+				util.ErrorWithCode(r, w, err, http.StatusBadGateway)
+				return
+			}
+			cache.Add(key, photo)
+		}
+
+		// Photos don't change, so clients can cache them
+		// (which replaces the headers that prevent caching for the rest of the API)
+		w.Header().Del("Expires")
+		w.Header().Del("Pragma")
+		w.Header().Del("X-Accel-Expires")
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", photoMaxAge))
+		w.Header().Set("Content-Type", photo.ContentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(photo.Data)
+	}
+}
+
+func validWidth(width int) bool {
+	for _, photoWidth := range photoWidths {
+		if width == photoWidth {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package photos
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/3-brain-cells/sah-backend/api/locations"
+	"github.com/go-chi/chi"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewCache(100)
+	photo := func(size int) *locations.Photo {
+		return &locations.Photo{ContentType: "image/jpeg", Data: make([]byte, size)}
+	}
+
+	cache.Add("a", photo(25))
+	cache.Add("b", photo(25))
+	cache.Add("c", photo(25))
+	// Using a makes b the least recently used
+	cache.Get("a")
+	cache.Add("d", photo(25))
+	cache.Add("e", photo(25))
+
+	for key, expected := range map[string]bool{"a": true, "b": false, "c": true, "d": true, "e": true} {
+		if _, ok := cache.Get(key); ok != expected {
+			t.Errorf("%s cached = %v, want %v", key, ok, expected)
+		}
+	}
+
+	// Photos larger than a quarter of the cache aren't cached
+	cache.Add("large", photo(26))
+	if _, ok := cache.Get("large"); ok {
+		t.Errorf("the large photo was cached")
+	}
+	if cache.bytes > 100 {
+		t.Errorf("the cache has %d bytes, want at most 100", cache.bytes)
+	}
+}
+
+// storedPhotos has the photos of venues being voted on (by provider and reference)
+type storedPhotos map[string]bool
+
+func (s storedPhotos) HasVenuePhoto(ctx context.Context, provider string, reference string) (bool, error) {
+	return s[provider+"/"+reference], nil
+}
+
+func TestGetPhoto(t *testing.T) {
+	image := []byte("jpeg bytes")
+	var requests []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+		if r.URL.Query().Get("key") != "secret-key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(image)
+	}))
+	defer upstream.Close()
+
+	google := &locations.GoogleProvider{APIKey: "secret-key", PhotoURL: upstream.URL}
+	namedProvider := func(name string) (locations.PlaceProvider, bool) {
+		switch name {
+		case locations.ProviderGoogle:
+			return google, true
+		case locations.ProviderFake:
+			return &locations.FakeProvider{}, true
+		}
+		return nil, false
+	}
+	stored := storedPhotos{
+		locations.ProviderGoogle + "/photo-1": true,
+		locations.ProviderGoogle + "/photo-2": true,
+		locations.ProviderFake + "/photo-1":   true,
+	}
+	router := chi.NewRouter()
+	router.Get("/{provider}/{reference}", GetPhoto(stored, namedProvider, NewCache(1024)))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	for i := 0; i < 2; i++ {
+		res := get("/google/photo-1?max_width=200")
+		if res.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", res.Code, res.Body)
+		}
+		if !bytes.Equal(res.Body.Bytes(), image) || res.Header().Get("Content-Type") != "image/jpeg" {
+			t.Errorf("served %q (%s), want the photo", res.Body, res.Header().Get("Content-Type"))
+		}
+		if bytes.Contains(res.Body.Bytes(), []byte("secret-key")) {
+			t.Errorf("the response includes the API key")
+		}
+	}
+	// The second request is served from the cache
+	if len(requests) != 1 {
+		t.Errorf("made %d requests to the provider, want 1", len(requests))
+	}
+
+	expectedCodes := map[string]int{
+		// Providers without photos (and unknown providers) aren't proxied
+		"/fake/photo-1":                 http.StatusNotFound,
+		"/yelp/photo-1":                 http.StatusNotFound,
+		"/google/photo-1?max_width=0":   http.StatusBadRequest,
+		"/google/photo-1?max_width=199": http.StatusBadRequest,
+		// Photos that aren't of a venue being voted on aren't proxied
+		"/google/other-photo": http.StatusNotFound,
+	}
+	for path, expected := range expectedCodes {
+		if res := get(path); res.Code != expected {
+			t.Errorf("%s: status = %d, want %d", path, res.Code, expected)
+		}
+	}
+
+	google.APIKey = "wrong-key"
+	if res := get("/google/photo-2"); res.Code != http.StatusBadGateway {
+		t.Errorf("failed photo: status = %d, want 502", res.Code)
+	}
+}
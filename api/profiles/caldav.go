@@ -0,0 +1,162 @@
+package profiles
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/caldav"
+	"github.com/3-brain-cells/sah-backend/db"
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+	"github.com/go-chi/chi"
+)
+
+type getCalDAVAccountResponseBody struct {
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	// The password is never returned
+}
+
+// GetCalDAVAccount returns the CalDAV account that a user's availability is pre-filled from
+func GetCalDAVAccount(profileProvider db.ProfileProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := chi.URLParam(r, "user_id")
+		if userID == "" {
+			util.ErrorWithCode(r, w, errors.New("the user ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("GetCalDAVAccount user_id=%s", userID)
+		account, err := profileProvider.GetCalDAVAccount(r.Context(), userID)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		responseBody := getCalDAVAccountResponseBody{
+			URL:      account.URL,
+			Username: account.Username,
+		}
+
+		jsonResponse, err := json.Marshal(&responseBody)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+type putCalDAVAccountRequestBody struct {
+	// URL of the calendar collection
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// PutCalDAVAccount stores the CalDAV account that a user's availability is pre-filled from,
+// after checking that the calendar can be queried with the credentials
+func PutCalDAVAccount(profileProvider db.ProfileProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := chi.URLParam(r, "user_id")
+		if userID == "" {
+			util.ErrorWithCode(r, w, errors.New("the user ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		var body putCalDAVAccountRequestBody
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusBadRequest)
+			return
+		}
+
+		validationError := &util.ValidationError{}
+		calendarURL, err := url.Parse(body.URL)
+		if err != nil || (calendarURL.Scheme != "https" && calendarURL.Scheme != "http") || calendarURL.Host == "" {
+			validationError.Add("url", "url must be an http or https link to the calendar")
+		} else if err := caldav.CheckPublicURL(r.Context(), body.URL); err != nil {
+			validationError.Add("url", "%s", calendarURLError(err))
+		}
+		if err := validationError.OrNil(); err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Query a day of busy times to check the URL and credentials
+		client := caldav.Client{
+			URL:      body.URL,
+			Username: body.Username,
+			Password: body.Password,
+		}
+		now := time.Now()
+		_, err = client.FreeBusy(r.Context(), now, now.Add(24*time.Hour))
+		if err != nil {
+			log.Printf("Failed to query CalDAV account (user_id=%s): %v", userID, err)
+			util.Error(r, w, &util.ValidationError{Fields: []types.FieldError{{
+				Field:   "url",
+				Message: calendarURLError(err),
+			}}})
+			return
+		}
+
+		log.Printf("PutCalDAVAccount user_id=%s", userID)
+		err = profileProvider.PutCalDAVAccount(r.Context(), types.CalDAVAccount{
+			UserID:   userID,
+			URL:      body.URL,
+			Username: body.Username,
+			Password: body.Password,
+		})
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteCalDAVAccount stops pre-filling a user's availability from their CalDAV account
+// (and forgets its credentials)
+func DeleteCalDAVAccount(profileProvider db.ProfileProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := chi.URLParam(r, "user_id")
+		if userID == "" {
+			util.ErrorWithCode(r, w, errors.New("the user ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("DeleteCalDAVAccount user_id=%s", userID)
+		err := profileProvider.DeleteCalDAVAccount(r.Context(), userID)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// calendarURLError describes why a calendar couldn't be queried,
+// without the details of the CalDAV server's response
+// (which would let the endpoint be used to probe other servers)
+func calendarURLError(err error) string {
+	switch {
+	case errors.Is(err, caldav.ErrPrivateAddress):
+		return "the calendar must be on a public address"
+	case errors.Is(err, caldav.ErrUnauthorized):
+		return "the calendar server rejected the username or password"
+	default:
+		return "couldn't query the calendar (check that the URL is a CalDAV calendar collection)"
+	}
+}
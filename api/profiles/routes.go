@@ -0,0 +1,209 @@
+package profiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/api/oauth"
+	"github.com/3-brain-cells/sah-backend/db"
+	"github.com/3-brain-cells/sah-backend/types"
+	"github.com/3-brain-cells/sah-backend/util"
+	"github.com/go-chi/chi"
+)
+
+// Subscribable link to a user's calendar feed (given its token)
+const feedURL = "webcal://kairosaio.com/api/v1/feeds/%s.ics"
+
+func Routes(database db.Provider, sessions *oauth.Sessions) *chi.Mux {
+	router := chi.NewRouter()
+
+	// Profiles have the user's home location, so they are only given to the user they belong to
+	router.With(sessions.RequireUser).Get("/{user_id}", GetProfile(database))
+	router.With(sessions.RequireUser).Put("/{user_id}", PutProfile(database))
+	// The feed's link is a secret, so it is only given to the user it belongs to
+	router.With(sessions.RequireUser).Get("/{user_id}/feed", GetFeedURL(database))
+	router.With(sessions.RequireUser).Post("/{user_id}/feed/reset", ResetFeedURL(database))
+	router.With(sessions.RequireUser).Get("/{user_id}/caldav", GetCalDAVAccount(database))
+	router.With(sessions.RequireUser).Put("/{user_id}/caldav", PutCalDAVAccount(database))
+	router.With(sessions.RequireUser).Delete("/{user_id}/caldav", DeleteCalDAVAccount(database))
+
+	return router
+}
+
+// GetProfile returns a user's saved profile
+func GetProfile(profileProvider db.ProfileProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := chi.URLParam(r, "user_id")
+		if userID == "" {
+			util.ErrorWithCode(r, w, errors.New("the user ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("GetProfile user_id=%s", userID)
+		profile, err := profileProvider.GetProfile(r.Context(), userID)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		jsonResponse, err := json.Marshal(profile)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+type putProfileRequestBody struct {
+	WeeklyAvailability []types.WeeklyBlock `json:"weekly_availability"`
+	// If null, then the home location is cleared
+	HomeLocation *types.UserLocation `json:"home_location"`
+	// IANA time zone name (such as "America/New_York")
+	TimeZone string `json:"time_zone"`
+}
+
+// PutProfile creates or replaces a user's saved profile
+func PutProfile(profileProvider db.ProfileProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := chi.URLParam(r, "user_id")
+		if userID == "" {
+			util.ErrorWithCode(r, w, errors.New("the user ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		var body putProfileRequestBody
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusBadRequest)
+			return
+		}
+
+		err = validateProfile(body)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		weeklyAvailability := body.WeeklyAvailability
+		if weeklyAvailability == nil {
+			weeklyAvailability = []types.WeeklyBlock{}
+		}
+
+		log.Printf("PutProfile user_id=%s", userID)
+		err = profileProvider.PutProfile(r.Context(), types.Profile{
+			UserID:             userID,
+			WeeklyAvailability: weeklyAvailability,
+			HomeLocation:       body.HomeLocation,
+			TimeZone:           body.TimeZone,
+		})
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func validateProfile(body putProfileRequestBody) error {
+	validationError := &util.ValidationError{}
+
+	if body.TimeZone == "" {
+		validationError.Add("time_zone", "time zone is required")
+	} else if _, err := time.LoadLocation(body.TimeZone); err != nil {
+		validationError.Add("time_zone", "unknown time zone %q", body.TimeZone)
+	}
+
+	for i, block := range body.WeeklyAvailability {
+		field := fmt.Sprintf("weekly_availability[%d]", i)
+		if block.Weekday < time.Sunday || block.Weekday > time.Saturday {
+			validationError.Add(field+".weekday", "weekday %d must be between 0 (Sunday) and 6 (Saturday)", block.Weekday)
+		}
+		util.ValidateDailyWindow(validationError, field, block.DailyWindow)
+	}
+
+	if body.HomeLocation != nil {
+		if body.HomeLocation.Latitude < -90 || body.HomeLocation.Latitude > 90 {
+			validationError.Add("home_location.latitude", "latitude %f must be between -90 and 90", body.HomeLocation.Latitude)
+		}
+		if body.HomeLocation.Longitude < -180 || body.HomeLocation.Longitude > 180 {
+			validationError.Add("home_location.longitude", "longitude %f must be between -180 and 180", body.HomeLocation.Longitude)
+		}
+	}
+
+	return validationError.OrNil()
+}
+
+type feedURLResponseBody struct {
+	URL string `json:"url"`
+}
+
+// GetFeedURL returns the private link to a user's calendar feed
+// of all the events they take part in
+func GetFeedURL(profileProvider db.ProfileProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := chi.URLParam(r, "user_id")
+		if userID == "" {
+			util.ErrorWithCode(r, w, errors.New("the user ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("GetFeedURL user_id=%s", userID)
+		token, err := profileProvider.GetFeedToken(r.Context(), userID)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		writeFeedURL(w, r, token)
+	}
+}
+
+// ResetFeedURL replaces the link to a user's calendar feed
+// (for when the old link was shared by accident)
+func ResetFeedURL(profileProvider db.ProfileProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := chi.URLParam(r, "user_id")
+		if userID == "" {
+			util.ErrorWithCode(r, w, errors.New("the user ID URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("ResetFeedURL user_id=%s", userID)
+		token, err := profileProvider.ResetFeedToken(r.Context(), userID)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		writeFeedURL(w, r, token)
+	}
+}
+
+func writeFeedURL(w http.ResponseWriter, r *http.Request, token string) {
+	responseBody := feedURLResponseBody{
+		URL: fmt.Sprintf(feedURL, token),
+	}
+
+	jsonResponse, err := json.Marshal(&responseBody)
+	if err != nil {
+		util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse)
+}
@@ -0,0 +1,157 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/3-brain-cells/sah-backend/ical"
+)
+
+const (
+	// Requests to CalDAV servers fail if they take longer than this
+	defaultTimeout = 10 * time.Second
+	// Responses larger than this are rejected
+	maxResponseSize = 4 << 20
+
+	timeRangeLayout = "20060102T150405Z"
+)
+
+// ErrUnauthorized is returned when the CalDAV server rejects the username or password
+var ErrUnauthorized = errors.New("the CalDAV server rejected the username or password")
+
+// Client queries the busy times of a single calendar on a CalDAV (RFC 4791) server
+type Client struct {
+	// URL of the calendar collection,
+	// such as https://cloud.example.com/remote.php/dav/calendars/alice/personal/
+	URL      string
+	Username string
+	Password string
+	// If nil, then a client with a 10 second timeout that only connects to public addresses is used
+	HTTPClient *http.Client
+}
+
+// FreeBusy returns the busy periods in the calendar that overlap the range.
+// It uses a free-busy-query REPORT, and falls back to fetching the events with a calendar-query REPORT
+// for servers that don't support free-busy queries.
+func (c *Client) FreeBusy(ctx context.Context, from time.Time, to time.Time) ([]ical.Period, error) {
+	timeRange := fmt.Sprintf(`<C:time-range start="%s" end="%s"/>`,
+		from.UTC().Format(timeRangeLayout), to.UTC().Format(timeRangeLayout))
+
+	freeBusyQuery := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<C:free-busy-query xmlns:C="urn:ietf:params:xml:ns:caldav">` + timeRange + `</C:free-busy-query>`
+	status, body, err := c.report(ctx, freeBusyQuery)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case status == http.StatusOK:
+		calendar, err := ical.Parse(bytes.NewReader(body), time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("invalid free/busy response: %w", err)
+		}
+		return calendar.BusyPeriods(from, to)
+	case !unsupported(status):
+		return nil, fmt.Errorf("free/busy query failed with status %d", status)
+	}
+
+	calendarQuery := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">` +
+		`<D:prop><C:calendar-data/></D:prop>` +
+		`<C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT">` + timeRange +
+		`</C:comp-filter></C:comp-filter></C:filter>` +
+		`</C:calendar-query>`
+	status, body, err = c.report(ctx, calendarQuery)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusMultiStatus {
+		return nil, fmt.Errorf("calendar query failed with status %d", status)
+	}
+
+	var response multistatus
+	err = xml.Unmarshal(body, &response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid calendar query response: %w", err)
+	}
+
+	// Each calendar object resource is a separate iCalendar file
+	calendar := &ical.Calendar{}
+	for _, resource := range response.Responses {
+		for _, propstat := range resource.Propstats {
+			if propstat.CalendarData == "" || !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+			object, err := ical.Parse(strings.NewReader(propstat.CalendarData), time.UTC)
+			if err != nil {
+				return nil, fmt.Errorf("invalid calendar data for %s: %w", resource.Href, err)
+			}
+			calendar.Events = append(calendar.Events, object.Events...)
+		}
+	}
+	return calendar.BusyPeriods(from, to)
+}
+
+// multistatus is the body of a 207 Multi-Status response
+type multistatus struct {
+	Responses []struct {
+		Href      string `xml:"href"`
+		Propstats []struct {
+			Status       string `xml:"status"`
+			CalendarData string `xml:"prop>calendar-data"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// report sends a REPORT request with the given XML body,
+// returning the response status and body
+func (c *Client) report(ctx context.Context, body string) (int, []byte, error) {
+	request, err := http.NewRequestWithContext(ctx, "REPORT", c.URL, strings.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	request.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	request.Header.Set("Depth", "1")
+	if c.Username != "" || c.Password != "" {
+		request.SetBasicAuth(c.Username, c.Password)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = publicHTTPClient()
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to query CalDAV server: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusUnauthorized {
+		return 0, nil, ErrUnauthorized
+	}
+
+	responseBody, err := io.ReadAll(io.LimitReader(response.Body, maxResponseSize+1))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read CalDAV response: %w", err)
+	}
+	if len(responseBody) > maxResponseSize {
+		return 0, nil, fmt.Errorf("the CalDAV response is larger than %d bytes", maxResponseSize)
+	}
+	return response.StatusCode, responseBody, nil
+}
+
+// unsupported checks whether the status means that the server doesn't support the REPORT
+func unsupported(status int) bool {
+	switch status {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusMethodNotAllowed,
+		http.StatusUnsupportedMediaType, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
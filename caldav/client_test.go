@@ -0,0 +1,156 @@
+package caldav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// calendarServer is an in-process stand-in for a CalDAV server
+// with a single calendar
+type calendarServer struct {
+	supportsFreeBusy bool
+	// The time ranges of the REPORT requests it received
+	requests []string
+}
+
+func (s *calendarServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	username, password, ok := r.BasicAuth()
+	if !ok || username != "alice" || password != "app-password" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.Method != "REPORT" || r.URL.Path != "/calendars/alice/personal/" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	s.requests = append(s.requests, string(body))
+	switch {
+	case strings.Contains(string(body), "free-busy-query") && s.supportsFreeBusy:
+		w.Header().Set("Content-Type", "text/calendar")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "BEGIN:VCALENDAR\r\n"+
+			"VERSION:2.0\r\n"+
+			"BEGIN:VFREEBUSY\r\n"+
+			"DTSTART:20220301T000000Z\r\n"+
+			"DTEND:20220308T000000Z\r\n"+
+			"FREEBUSY:20220301T180000Z/20220301T193000Z\r\n"+
+			"FREEBUSY;FBTYPE=BUSY-TENTATIVE:20220302T200000Z/PT1H\r\n"+
+			"END:VFREEBUSY\r\n"+
+			"END:VCALENDAR\r\n")
+	case strings.Contains(string(body), "free-busy-query"):
+		w.WriteHeader(http.StatusNotImplemented)
+	case strings.Contains(string(body), "calendar-query"):
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>
+<d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">
+  <d:response>
+    <d:href>/calendars/alice/personal/gym.ics</d:href>
+    <d:propstat>
+      <d:prop>
+        <cal:calendar-data>BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:gym
+DTSTART:20220301T180000Z
+DTEND:20220301T193000Z
+RRULE:FREQ=DAILY;COUNT=2
+END:VEVENT
+END:VCALENDAR
+</cal:calendar-data>
+      </d:prop>
+      <d:status>HTTP/1.1 200 OK</d:status>
+    </d:propstat>
+  </d:response>
+</d:multistatus>`)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestFreeBusy(t *testing.T) {
+	from := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+
+	for _, supportsFreeBusy := range []bool{true, false} {
+		stub := &calendarServer{supportsFreeBusy: supportsFreeBusy}
+		server := httptest.NewServer(stub)
+
+		client := Client{
+			URL:      server.URL + "/calendars/alice/personal/",
+			Username: "alice",
+			Password: "app-password",
+			// The test server is on localhost, which the default client doesn't connect to
+			HTTPClient: server.Client(),
+		}
+		periods, err := client.FreeBusy(context.Background(), from, to)
+		server.Close()
+		if err != nil {
+			t.Fatalf("supportsFreeBusy=%t: %v", supportsFreeBusy, err)
+		}
+
+		expectedRequests := 1
+		expectedSecond := time.Date(2022, time.March, 2, 20, 0, 0, 0, time.UTC)
+		if !supportsFreeBusy {
+			// Falls back to fetching the (recurring) events
+			expectedRequests = 2
+			expectedSecond = time.Date(2022, time.March, 2, 18, 0, 0, 0, time.UTC)
+		}
+		if len(stub.requests) != expectedRequests {
+			t.Errorf("supportsFreeBusy=%t: expected %d requests, got %d", supportsFreeBusy, expectedRequests, len(stub.requests))
+		}
+		if !strings.Contains(stub.requests[0], `start="20220301T000000Z" end="20220308T000000Z"`) {
+			t.Errorf("supportsFreeBusy=%t: expected the time range in the request, got %s", supportsFreeBusy, stub.requests[0])
+		}
+		if len(periods) != 2 {
+			t.Fatalf("supportsFreeBusy=%t: expected 2 busy periods, got %+v", supportsFreeBusy, periods)
+		}
+		if !periods[0].Start.Equal(time.Date(2022, time.March, 1, 18, 0, 0, 0, time.UTC)) || !periods[1].Start.Equal(expectedSecond) {
+			t.Errorf("supportsFreeBusy=%t: unexpected busy periods %+v", supportsFreeBusy, periods)
+		}
+	}
+}
+
+func TestFreeBusyWrongPassword(t *testing.T) {
+	server := httptest.NewServer(&calendarServer{supportsFreeBusy: true})
+	defer server.Close()
+
+	client := Client{
+		URL:        server.URL + "/calendars/alice/personal/",
+		Username:   "alice",
+		Password:   "wrong",
+		HTTPClient: server.Client(),
+	}
+	_, err := client.FreeBusy(context.Background(), time.Now(), time.Now().Add(time.Hour))
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestFreeBusyPrivateAddress(t *testing.T) {
+	server := httptest.NewServer(&calendarServer{supportsFreeBusy: true})
+	defer server.Close()
+
+	client := Client{URL: server.URL + "/calendars/alice/personal/"}
+	_, err := client.FreeBusy(context.Background(), time.Now(), time.Now().Add(time.Hour))
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("expected ErrPrivateAddress for a server on localhost, got %v", err)
+	}
+
+	for _, rawURL := range []string{"http://127.0.0.1/", "http://10.0.0.5/dav/", "http://169.254.169.254/latest/", "http://[::1]:8080/"} {
+		if err := CheckPublicURL(context.Background(), rawURL); !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("%s: expected ErrPrivateAddress, got %v", rawURL, err)
+		}
+	}
+	if err := CheckPublicURL(context.Background(), "https://93.184.216.34/dav/"); err != nil {
+		t.Errorf("expected a public address to be allowed, got %v", err)
+	}
+}
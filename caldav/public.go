@@ -0,0 +1,66 @@
+package caldav
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+)
+
+// ErrPrivateAddress is returned for calendars on hosts that aren't public
+// (such as localhost, private networks or cloud metadata addresses),
+// so that users can't make the server send requests inside its own network
+var ErrPrivateAddress = errors.New("the calendar's host isn't a public address")
+
+// CheckPublicURL returns ErrPrivateAddress if the URL's host resolves to an address that isn't public
+func CheckPublicURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		if !isPublic(address.IP) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// publicHTTPClient returns a client that only connects to public addresses.
+// The address is checked when connecting (including for redirects),
+// so hosts can't resolve to a public address when checked and a private one when used.
+func publicHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: defaultTimeout,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// A proxy would connect to the host instead, without the address being checked
+	transport.Proxy = nil
+	return &http.Client{Timeout: defaultTimeout, Transport: transport}
+}
+
+// Shared address space used by carrier-grade NAT (RFC 6598)
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isPublic(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() && !sharedAddressSpace.Contains(ip)
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/3-brain-cells/sah-backend/types"
 )
@@ -12,6 +13,9 @@ type Provider interface {
 	Disconnect(ctx context.Context) error
 
 	EventProvider
+	ProfileProvider
+	PlaceCacheProvider
+	PhotoReferenceProvider
 }
 
 // EventProvider provides CRUD operations for types.Event structs
@@ -30,6 +34,9 @@ type EventProvider interface {
 	// - populated
 	// - voteOptions
 	// - userVotes
+	// - userPollAnswers
+	// - finalized, finalTime, finalLocation
+	// - suggestedVenues
 	// If userID is not the creator ID of the event, an error is returned.
 	PopulateEvent(ctx context.Context, event types.Event, userID string) error
 
@@ -37,14 +44,94 @@ type EventProvider interface {
 	PostVotes(ctx context.Context, userID string, votes types.UserVotes, eventID string) error
 
 	// PutUserAvailabilityAndLocation updates the user availability and location
+	// (the location is coarsened and encrypted before it is stored)
 	PutUserAvailabilityAndLocation(ctx context.Context, userID string, availability types.UserAvailability, location types.UserLocation, eventID string) error
 
+	// PutUserPollAnswersAndLocation updates the user's answers to the candidate times and location
+	PutUserPollAnswersAndLocation(ctx context.Context, userID string, answers types.PollAnswers, location types.UserLocation, eventID string) error
+
 	// GetAllEvents returns all events in the database
 	GetAllEvents(ctx context.Context) ([]*types.Event, error)
 
 	// UpdateVoteOptions updates the vote options for times and locations
 	UpdateVoteOptions(ctx context.Context, voteOptions types.VoteOption, eventID string) error
 
+	// FinalizeEvent marks the event as finalized with the winning time and location,
+	// and deletes the users' locations
+	FinalizeEvent(ctx context.Context, eventID string, finalTime types.TimePair, finalLocation types.Location) error
+
+	// DeleteLocations deletes the users' locations and the midpoint found from them,
+	// for events that end without being finalized or cancelled
+	DeleteLocations(ctx context.Context, eventID string) error
+
+	// GetFinalizedEventsForUsers returns all finalized events in the guild
+	// that any of the given users are attending
+	GetFinalizedEventsForUsers(ctx context.Context, guildID string, userIDs []string) ([]*types.Event, error)
+
+	// CancelEvent marks the event as cancelled, and deletes the users' locations and the midpoint
+	CancelEvent(ctx context.Context, eventID string) error
+
+	// AddSuggestedVenue adds a venue that a user suggested to the event
+	AddSuggestedVenue(ctx context.Context, eventID string, venue types.Location) error
+
+	// RemoveSuggestedVenue removes the suggested venue with the given place ID from the event
+	RemoveSuggestedVenue(ctx context.Context, eventID string, placeID string) error
+
+	// GetEventsForUser returns all events (in any guild)
+	// that the user has submitted availability, votes or poll answers for,
+	// or is attending
+	GetEventsForUser(ctx context.Context, userID string) ([]*types.Event, error)
+
 	// Delete deletes an existing event
 	// Delete(ctx context.Context, eventID int) error
 }
+
+// ProfileProvider provides operations for types.Profile structs
+type ProfileProvider interface {
+	// GetProfile returns a user's profile,
+	// or a NotFoundError if they haven't saved one
+	GetProfile(ctx context.Context, userID string) (*types.Profile, error)
+
+	// PutProfile creates or replaces a user's profile
+	PutProfile(ctx context.Context, profile types.Profile) error
+
+	// GetFeedToken returns the secret token for the user's calendar feed,
+	// creating one if they don't have one yet
+	GetFeedToken(ctx context.Context, userID string) (string, error)
+
+	// ResetFeedToken replaces the token for the user's calendar feed
+	// (so that the old feed URL stops working)
+	ResetFeedToken(ctx context.Context, userID string) (string, error)
+
+	// GetFeedUser returns the ID of the user that the calendar feed token belongs to,
+	// or a NotFoundError if it doesn't belong to anyone
+	GetFeedUser(ctx context.Context, token string) (string, error)
+
+	// GetCalDAVAccount returns the CalDAV account that a user's availability is pre-filled from,
+	// or a NotFoundError if they haven't configured one
+	GetCalDAVAccount(ctx context.Context, userID string) (*types.CalDAVAccount, error)
+
+	// PutCalDAVAccount creates or replaces a user's CalDAV account
+	PutCalDAVAccount(ctx context.Context, account types.CalDAVAccount) error
+
+	// DeleteCalDAVAccount removes a user's CalDAV account (if they have one)
+	DeleteCalDAVAccount(ctx context.Context, userID string) error
+}
+
+// PlaceCacheProvider stores place search results so that they can be shared between servers.
+// The keys include where the users are, so they are only stored hashed.
+type PlaceCacheProvider interface {
+	// GetCachedPlaces returns the places stored with the key and when they expire,
+	// or false if there aren't any (or they have expired)
+	GetCachedPlaces(ctx context.Context, key string) ([]types.Location, time.Time, bool, error)
+
+	// PutCachedPlaces stores the places with the key until they expire
+	PutCachedPlaces(ctx context.Context, key string, places []types.Location, expiresAt time.Time) error
+}
+
+// PhotoReferenceProvider checks which photos the photo proxy may serve
+type PhotoReferenceProvider interface {
+	// HasVenuePhoto returns whether a venue in any event's vote options
+	// has the photo with the reference from the provider
+	HasVenuePhoto(ctx context.Context, provider string, reference string) (bool, error)
+}
@@ -0,0 +1,63 @@
+package mongo
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+// legacyAvailabilityBlock is how availability blocks were stored
+// before they were stored as absolute instants
+// (as times of day on the date of the day they are in)
+type legacyAvailabilityBlock struct {
+	StartHour   *int `bson:"start_hour"`
+	StartMinute int  `bson:"start_minute"`
+	EndHour     int  `bson:"end_hour"`
+	EndMinute   int  `bson:"end_minute"`
+}
+
+// convertLegacyAvailability converts the availability blocks that were stored in the legacy format
+// (which otherwise decode to zero times) into absolute instants on their day's date.
+// If the end time is not after the start time, the block ends on the following day.
+func convertLegacyAvailability(raw bson.Raw, event *types.Event) error {
+	var stored struct {
+		UserAvailability map[string]struct {
+			DayAvailability []struct {
+				AvailableBlocks []legacyAvailabilityBlock `bson:"available_blocks"`
+			} `bson:"day_availability"`
+		} `bson:"user_availability"`
+	}
+	err := bson.Unmarshal(raw, &stored)
+	if err != nil {
+		return err
+	}
+
+	for userID, storedAvailability := range stored.UserAvailability {
+		availability := event.UserAvailability[userID]
+		for i, storedDay := range storedAvailability.DayAvailability {
+			if i >= len(availability.DayAvailability) {
+				break
+			}
+			day := availability.DayAvailability[i]
+			for j, legacy := range storedDay.AvailableBlocks {
+				if legacy.StartHour == nil || j >= len(day.AvailableBlocks) || !day.AvailableBlocks[j].Start.IsZero() {
+					continue
+				}
+				day.AvailableBlocks[j] = legacyBlockOnDate(day.Date, legacy)
+			}
+		}
+	}
+	return nil
+}
+
+func legacyBlockOnDate(date time.Time, legacy legacyAvailabilityBlock) types.AvailabilityBlock {
+	year, month, day := date.Date()
+	start := time.Date(year, month, day, *legacy.StartHour, legacy.StartMinute, 0, 0, date.Location())
+	end := time.Date(year, month, day, legacy.EndHour, legacy.EndMinute, 0, 0, date.Location())
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return types.AvailabilityBlock{Start: start, End: end}
+}
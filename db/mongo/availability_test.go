@@ -0,0 +1,55 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDecodeLegacyAvailability(t *testing.T) {
+	date := time.Date(2022, time.March, 4, 0, 0, 0, 0, time.UTC)
+	start := date.Add(20 * time.Hour)
+	raw, err := bson.Marshal(bson.M{
+		"id": "abcde",
+		"user_availability": bson.M{
+			// Stored as times of day before blocks were stored as instants
+			"001": bson.M{"day_availability": bson.A{bson.M{
+				"date": date,
+				"available_blocks": bson.A{
+					bson.M{"start_hour": 9, "start_minute": 30, "end_hour": 12, "end_minute": 0},
+					bson.M{"start_hour": 22, "start_minute": 0, "end_hour": 1, "end_minute": 0},
+				},
+			}}},
+			"002": bson.M{"day_availability": bson.A{bson.M{
+				"date":             date,
+				"available_blocks": bson.A{bson.M{"start": start, "end": start.Add(time.Hour)}},
+			}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := (&Provider{}).decodeEvent(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := event.UserAvailability["001"].DayAvailability[0].AvailableBlocks
+	expected := []struct{ start, end time.Time }{
+		{date.Add(9*time.Hour + 30*time.Minute), date.Add(12 * time.Hour)},
+		// Blocks that end before they start end on the following day
+		{date.Add(22 * time.Hour), date.Add(25 * time.Hour)},
+	}
+	for i, block := range legacy {
+		if !block.Start.Equal(expected[i].start) || !block.End.Equal(expected[i].end) {
+			t.Errorf("legacy block %d = %v - %v, want %v - %v", i, block.Start, block.End, expected[i].start, expected[i].end)
+		}
+	}
+
+	current := event.UserAvailability["002"].DayAvailability[0].AvailableBlocks[0]
+	if !current.Start.Equal(start) || !current.End.Equal(start.Add(time.Hour)) {
+		t.Errorf("block = %v - %v, want it unchanged", current.Start, current.End)
+	}
+}
@@ -0,0 +1,126 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The provider that legacy photo links are from (the same as locations.ProviderGoogle)
+const legacyPhotoProvider = "google"
+
+func (p *Provider) HasVenuePhoto(ctx context.Context, provider string, reference string) (bool, error) {
+	collection := p.events()
+
+	filter := bson.M{"vote_options.address": bson.M{"$elemMatch": bson.M{
+		"provider": provider,
+		"photo":    reference,
+	}}}
+	count, err := collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, fmt.Errorf("failed to find photo reference: %w", err)
+	}
+	return count > 0, nil
+}
+
+// legacyImageFilter matches images that are links with an API key
+// (which locations stored before the photo proxy have)
+var legacyImageFilter = bson.M{"$regex": "key="}
+
+// migrateLegacyPhotos replaces the images of stored locations that are Google Places photo links,
+// which include the API key, with their photo references (which are served through the photo proxy).
+// Cached places with those images are deleted, since they can be searched for again.
+func (p *Provider) migrateLegacyPhotos(ctx context.Context) error {
+	collection := p.events()
+
+	cursor, err := collection.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"vote_options.address.image": legacyImageFilter},
+		bson.M{"suggested_venues.image": legacyImageFilter},
+		bson.M{"final_location.image": legacyImageFilter},
+	}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var stored struct {
+			EventID     string `bson:"id"`
+			VoteOptions struct {
+				Locations []bson.M `bson:"address"`
+			} `bson:"vote_options"`
+			SuggestedVenues []bson.M `bson:"suggested_venues"`
+			FinalLocation   bson.M   `bson:"final_location"`
+		}
+		err := cursor.Decode(&stored)
+		if err != nil {
+			return err
+		}
+
+		update := bson.M{}
+		if withoutLegacyImages(stored.VoteOptions.Locations...) {
+			update["vote_options.address"] = stored.VoteOptions.Locations
+		}
+		if withoutLegacyImages(stored.SuggestedVenues...) {
+			update["suggested_venues"] = stored.SuggestedVenues
+		}
+		if stored.FinalLocation != nil && withoutLegacyImages(stored.FinalLocation) {
+			update["final_location"] = stored.FinalLocation
+		}
+		if len(update) == 0 {
+			continue
+		}
+		_, err = collection.UpdateOne(ctx, bson.M{"id": stored.EventID}, bson.M{"$set": update})
+		if err != nil {
+			return fmt.Errorf("failed to migrate photos for eventID=%s: %w", stored.EventID, err)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	_, err = p.placeCache().DeleteMany(ctx, bson.M{"places.image": legacyImageFilter})
+	return err
+}
+
+// withoutLegacyImages replaces the locations' images that are links with an API key
+// with photo references, returning whether any were replaced.
+// Images with an API key that aren't Google Places photo links are removed.
+func withoutLegacyImages(locations ...bson.M) bool {
+	changed := false
+	for _, location := range locations {
+		image, _ := location["image"].(string)
+		if !strings.Contains(image, "key=") {
+			continue
+		}
+		changed = true
+		location["image"] = ""
+		reference, ok := legacyPhotoReference(image)
+		if !ok {
+			continue
+		}
+		provider, _ := location["provider"].(string)
+		if provider == "" {
+			location["provider"] = legacyPhotoProvider
+			provider = legacyPhotoProvider
+		}
+		if provider == legacyPhotoProvider {
+			location["photo"] = reference
+		}
+	}
+	return changed
+}
+
+// legacyPhotoReference returns the photo reference from a Google Places photo link
+func legacyPhotoReference(image string) (string, bool) {
+	parsed, err := url.Parse(image)
+	if err != nil || parsed.Host != "maps.googleapis.com" || parsed.Path != "/maps/api/place/photo" {
+		return "", false
+	}
+	reference := parsed.Query().Get("photo_reference")
+	return reference, reference != ""
+}
@@ -0,0 +1,29 @@
+package mongo
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWithoutLegacyImages(t *testing.T) {
+	legacy := bson.M{"name": "Cafe", "image": "https://maps.googleapis.com/maps/api/place/photo?maxwidth=400&photo_reference=abc&key=secret"}
+	otherKey := bson.M{"name": "Bar", "provider": "yelp", "image": "https://example.com/photo.jpg?key=secret"}
+	current := bson.M{"name": "Park", "provider": "yelp", "image": "https://example.com/park.jpg"}
+
+	if !withoutLegacyImages(legacy, otherKey, current) {
+		t.Fatal("expected the images to be replaced")
+	}
+	if legacy["image"] != "" || legacy["photo"] != "abc" || legacy["provider"] != legacyPhotoProvider {
+		t.Errorf("expected the Google photo link to be replaced with its reference, got %+v", legacy)
+	}
+	if otherKey["image"] != "" || otherKey["photo"] != nil {
+		t.Errorf("expected the other link with a key to be removed, got %+v", otherKey)
+	}
+	if current["image"] != "https://example.com/park.jpg" {
+		t.Errorf("expected the image without a key to be kept, got %+v", current)
+	}
+	if withoutLegacyImages(current) {
+		t.Errorf("expected nothing to be replaced the second time")
+	}
+}
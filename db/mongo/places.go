@@ -0,0 +1,60 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+func (p *Provider) placeCache() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("place_cache")
+}
+
+type cachedPlaces struct {
+	// The hash of the search's cache key,
+	// since the key has the (rounded) midpoint of the users' locations
+	Key    string           `bson:"key"`
+	Places []types.Location `bson:"places"`
+	// MongoDB deletes the document after this time
+	// (though not right away, so it is also checked when the places are read)
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+func (p *Provider) GetCachedPlaces(ctx context.Context, key string) ([]types.Location, time.Time, bool, error) {
+	collection := p.placeCache()
+
+	var cached cachedPlaces
+	err := collection.FindOne(ctx, bson.M{
+		"key":        p.secrets.hash(key),
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&cached)
+	if err == mongo.ErrNoDocuments {
+		return nil, time.Time{}, false, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, false, fmt.Errorf("failed to get cached places: %w", err)
+	}
+	return cached.Places, cached.ExpiresAt, true, nil
+}
+
+func (p *Provider) PutCachedPlaces(ctx context.Context, key string, places []types.Location, expiresAt time.Time) error {
+	collection := p.placeCache()
+
+	hashedKey := p.secrets.hash(key)
+	filter := bson.M{"key": hashedKey}
+	_, err := collection.ReplaceOne(ctx, filter, cachedPlaces{
+		Key:       hashedKey,
+		Places:    places,
+		ExpiresAt: expiresAt,
+	}, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to cache places: %w", err)
+	}
+	return nil
+}
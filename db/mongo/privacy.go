@@ -0,0 +1,146 @@
+package mongo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+// Users' locations are snapped to a grid of this size (in meters) unless LOCATION_GRID_METERS is set
+const defaultLocationGrid = 1000
+
+// Meters per degree of latitude (and of longitude at the equator)
+const metersPerDegree = 111320
+
+// encryptedLocation is how a location is stored
+type encryptedLocation struct {
+	Encrypted string `bson:"encrypted"`
+}
+
+// encrypt encrypts a location so the database never has the user's coordinates in plain text
+func (c *secretCipher) encrypt(location types.UserLocation) (encryptedLocation, error) {
+	plaintext, err := json.Marshal(location)
+	if err != nil {
+		return encryptedLocation{}, err
+	}
+	sealed, err := c.seal(plaintext)
+	if err != nil {
+		return encryptedLocation{}, err
+	}
+	return encryptedLocation{Encrypted: sealed}, nil
+}
+
+func (c *secretCipher) decrypt(stored encryptedLocation) (types.UserLocation, error) {
+	plaintext, err := c.open(stored.Encrypted)
+	if err != nil {
+		return types.UserLocation{}, fmt.Errorf("failed to decrypt location: %w", err)
+	}
+
+	var location types.UserLocation
+	err = json.Unmarshal(plaintext, &location)
+	return location, err
+}
+
+// protectLocation snaps the location to the grid and encrypts it for storage
+func (p *Provider) protectLocation(location types.UserLocation) (encryptedLocation, error) {
+	return p.secrets.encrypt(snapToGrid(location, p.locationGrid))
+}
+
+// snapToGrid rounds the coordinates to the nearest point on a grid of the given size (in meters),
+// so the exact location isn't stored.
+// Locations that weren't given (the zero value) are left as they are.
+func snapToGrid(location types.UserLocation, gridMeters float64) types.UserLocation {
+	if gridMeters <= 0 || (location.Latitude == 0 && location.Longitude == 0) {
+		return location
+	}
+
+	latitudeStep := gridMeters / metersPerDegree
+	location.Latitude = math.Round(location.Latitude/latitudeStep) * latitudeStep
+	location.Latitude = math.Max(-90, math.Min(90, location.Latitude))
+
+	// Degrees of longitude get shorter away from the equator
+	longitudeStep := gridMeters / (metersPerDegree * math.Max(math.Cos(location.Latitude*math.Pi/180), 0.01))
+	location.Longitude = math.Round(location.Longitude/longitudeStep) * longitudeStep
+	if location.Longitude > 180 {
+		location.Longitude -= 360
+	} else if location.Longitude < -180 {
+		location.Longitude += 360
+	}
+	return location
+}
+
+// decodeEvent decodes a stored event, decrypting the users' locations and the midpoint
+// (and converting availability stored in the legacy format).
+// Locations are snapped to the grid again, in case they were stored before they were snapped.
+func (p *Provider) decodeEvent(raw bson.Raw) (*types.Event, error) {
+	var event types.Event
+	err := bson.Unmarshal(raw, &event)
+	if err != nil {
+		return nil, err
+	}
+	err = convertLegacyAvailability(raw, &event)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored struct {
+		UserLocations map[string]encryptedLocation `bson:"user_locations"`
+		VoteOptions   struct {
+			Midpoint *encryptedLocation `bson:"midpoint"`
+		} `bson:"vote_options"`
+	}
+	err = bson.Unmarshal(raw, &stored)
+	if err != nil {
+		return nil, err
+	}
+	for userID, storedLocation := range stored.UserLocations {
+		// Locations stored before they were encrypted are used as they are
+		location := event.UserLocations[userID]
+		if storedLocation.Encrypted != "" {
+			location, err = p.secrets.decrypt(storedLocation)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt location for userID=%s eventID=%s: %w", userID, event.EventID, err)
+			}
+		}
+		event.UserLocations[userID] = snapToGrid(location, p.locationGrid)
+	}
+	if midpoint := stored.VoteOptions.Midpoint; midpoint != nil && midpoint.Encrypted != "" {
+		location, err := p.secrets.decrypt(*midpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt midpoint for eventID=%s: %w", event.EventID, err)
+		}
+		event.VoteOptions.Midpoint = &types.Coordinates{Latitude: location.Latitude, Longitude: location.Longitude}
+	}
+
+	return &event, nil
+}
+
+// decodeProfile decodes a stored profile, decrypting the user's home location
+func (p *Provider) decodeProfile(raw bson.Raw) (*types.Profile, error) {
+	var profile types.Profile
+	err := bson.Unmarshal(raw, &profile)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored struct {
+		HomeLocation *encryptedLocation `bson:"home_location"`
+	}
+	err = bson.Unmarshal(raw, &stored)
+	if err != nil {
+		return nil, err
+	}
+	if stored.HomeLocation != nil && stored.HomeLocation.Encrypted != "" {
+		homeLocation, err := p.secrets.decrypt(*stored.HomeLocation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt home location for userID=%s: %w", profile.UserID, err)
+		}
+		profile.HomeLocation = &homeLocation
+	}
+
+	return &profile, nil
+}
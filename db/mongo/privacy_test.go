@@ -0,0 +1,94 @@
+package mongo
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+func TestSnapToGrid(t *testing.T) {
+	location := types.UserLocation{Latitude: 33.77563, Longitude: -84.39628, Label: "North Ave"}
+	snapped := snapToGrid(location, 1000)
+
+	// The snapped point is at most half a grid cell away in each direction
+	northSouth := math.Abs(snapped.Latitude-location.Latitude) * metersPerDegree
+	eastWest := math.Abs(snapped.Longitude-location.Longitude) * metersPerDegree * math.Cos(location.Latitude*math.Pi/180)
+	if northSouth > 501 || eastWest > 501 || snapped == location {
+		t.Errorf("expected the location to be snapped to the grid, got %+v", snapped)
+	}
+	// Nearby locations snap to the same point
+	if nearby := snapToGrid(types.UserLocation{Latitude: 33.77570, Longitude: -84.39620}, 1000); nearby.Latitude != snapped.Latitude || nearby.Longitude != snapped.Longitude {
+		t.Errorf("expected nearby locations to be snapped to %+v, got %+v", snapped, nearby)
+	}
+
+	// The label is encrypted along with the coordinates, so it is kept
+	if snapped.Label != location.Label {
+		t.Errorf("expected the label to be kept, got %q", snapped.Label)
+	}
+
+	if unset := snapToGrid(types.UserLocation{}, 1000); unset != (types.UserLocation{}) {
+		t.Errorf("expected a location that wasn't given to be unchanged, got %+v", unset)
+	}
+	if exact := snapToGrid(location, 0); exact != location {
+		t.Errorf("expected a grid of 0 to leave the location as it is, got %+v", exact)
+	}
+}
+
+func TestLocationEncryption(t *testing.T) {
+	secrets, err := newSecretCipher(testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &Provider{secrets: secrets, locationGrid: 1000}
+
+	protected, err := provider.protectLocation(types.UserLocation{Latitude: 33.77563, Longitude: -84.39628})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(protected.Encrypted, "33.7") {
+		t.Errorf("expected the coordinates to be encrypted, got %s", protected.Encrypted)
+	}
+
+	midpoint, err := secrets.encrypt(types.UserLocation{Latitude: 33.7, Longitude: -84.4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A stored event with an encrypted location and a location from before encryption
+	raw, err := bson.Marshal(bson.M{
+		"id": "abcde",
+		"user_locations": bson.M{
+			"001": protected,
+			"002": bson.M{"latitude": 40.0, "longitude": -74.0, "label": "1 Main St"},
+		},
+		"vote_options": bson.M{"midpoint": midpoint},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := provider.decodeEvent(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location := event.UserLocations["001"]; math.Abs(location.Latitude-33.77563) > 0.01 || math.Abs(location.Longitude+84.39628) > 0.01 {
+		t.Errorf("expected the decrypted location to be near the original, got %+v", location)
+	}
+	if location := event.UserLocations["002"]; math.Abs(location.Latitude-40) > 0.01 || math.Abs(location.Longitude+74) > 0.01 || location.Label != "1 Main St" {
+		t.Errorf("expected the unencrypted location to be kept on the grid with its label, got %+v", location)
+	}
+	if event.VoteOptions.Midpoint == nil || event.VoteOptions.Midpoint.Latitude != 33.7 {
+		t.Errorf("expected the midpoint to be decrypted, got %+v", event.VoteOptions.Midpoint)
+	}
+
+	otherKey, err := newSecretCipher("ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := otherKey.decrypt(protected); err == nil {
+		t.Error("expected decrypting with a different key to fail")
+	}
+}
@@ -0,0 +1,261 @@
+package mongo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/3-brain-cells/sah-backend/db"
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+func (p *Provider) profiles() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("profiles")
+}
+
+func (p *Provider) GetProfile(ctx context.Context, userID string) (*types.Profile, error) {
+	collection := p.profiles()
+
+	result := collection.FindOne(ctx, bson.M{"user_id": userID})
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, db.NewNotFoundError(userID)
+	}
+
+	raw, err := result.DecodeBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.decodeProfile(raw)
+}
+
+func (p *Provider) PutProfile(ctx context.Context, profile types.Profile) error {
+	collection := p.profiles()
+
+	encoded, err := bson.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to marshal profile: %w", err)
+	}
+	document := bson.M{}
+	err = bson.Unmarshal(encoded, &document)
+	if err != nil {
+		return fmt.Errorf("failed to marshal profile: %w", err)
+	}
+	if profile.HomeLocation != nil {
+		document["home_location"], err = p.protectLocation(*profile.HomeLocation)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt home location: %w", err)
+		}
+	}
+
+	filter := bson.D{{Key: "user_id", Value: profile.UserID}}
+	_, err = collection.ReplaceOne(ctx, filter, document, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to put profile for userID=%s: %w", profile.UserID, err)
+	}
+
+	return nil
+}
+
+func (p *Provider) feedTokens() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("feed_tokens")
+}
+
+type feedToken struct {
+	UserID string `bson:"user_id"`
+	Token  string `bson:"token"`
+}
+
+// newFeedToken generates a random token that can't be guessed
+func newFeedToken() (string, error) {
+	bytes := make([]byte, 24)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func (p *Provider) GetFeedToken(ctx context.Context, userID string) (string, error) {
+	collection := p.feedTokens()
+
+	token, err := newFeedToken()
+	if err != nil {
+		return "", err
+	}
+
+	// Only uses the new token if the user doesn't have one yet
+	filter := bson.D{{Key: "user_id", Value: userID}}
+	updateQuery := bson.M{"$setOnInsert": bson.M{"token": token}}
+	result := collection.FindOneAndUpdate(ctx, filter, updateQuery,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After))
+
+	var existing feedToken
+	err = result.Decode(&existing)
+	if err != nil {
+		return "", fmt.Errorf("failed to get feed token for userID=%s: %w", userID, err)
+	}
+
+	return existing.Token, nil
+}
+
+func (p *Provider) ResetFeedToken(ctx context.Context, userID string) (string, error) {
+	collection := p.feedTokens()
+
+	token, err := newFeedToken()
+	if err != nil {
+		return "", err
+	}
+
+	filter := bson.D{{Key: "user_id", Value: userID}}
+	updateQuery := bson.M{"$set": bson.M{"token": token}}
+	_, err = collection.UpdateOne(ctx, filter, updateQuery, options.Update().SetUpsert(true))
+	if err != nil {
+		return "", fmt.Errorf("failed to reset feed token for userID=%s: %w", userID, err)
+	}
+
+	return token, nil
+}
+
+func (p *Provider) GetFeedUser(ctx context.Context, token string) (string, error) {
+	collection := p.feedTokens()
+
+	result := collection.FindOne(ctx, bson.M{"token": token})
+	if result.Err() == mongo.ErrNoDocuments {
+		// The token is a secret, so it isn't included in the error
+		return "", db.NewNotFoundError("feed token")
+	}
+
+	var existing feedToken
+	err := result.Decode(&existing)
+	if err != nil {
+		return "", err
+	}
+
+	return existing.UserID, nil
+}
+
+func (p *Provider) calDAVAccounts() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("caldav_accounts")
+}
+
+// storedCalDAVAccount is how a CalDAV account is stored, with its password encrypted
+type storedCalDAVAccount struct {
+	UserID            string `bson:"user_id"`
+	URL               string `bson:"url"`
+	Username          string `bson:"username"`
+	EncryptedPassword string `bson:"encrypted_password"`
+	// Only set for accounts stored before passwords were encrypted
+	Password string `bson:"password,omitempty"`
+}
+
+func (p *Provider) GetCalDAVAccount(ctx context.Context, userID string) (*types.CalDAVAccount, error) {
+	collection := p.calDAVAccounts()
+
+	result := collection.FindOne(ctx, bson.M{"user_id": userID})
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, db.NewNotFoundError(userID)
+	}
+
+	var stored storedCalDAVAccount
+	err := result.Decode(&stored)
+	if err != nil {
+		return nil, err
+	}
+
+	account := types.CalDAVAccount{
+		UserID:   stored.UserID,
+		URL:      stored.URL,
+		Username: stored.Username,
+		Password: stored.Password,
+	}
+	if stored.EncryptedPassword != "" {
+		account.Password, err = p.secrets.decryptSecret(stored.EncryptedPassword)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt CalDAV password for userID=%s: %w", userID, err)
+		}
+	}
+
+	return &account, nil
+}
+
+func (p *Provider) PutCalDAVAccount(ctx context.Context, account types.CalDAVAccount) error {
+	collection := p.calDAVAccounts()
+
+	stored, err := p.protectCalDAVAccount(account)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.D{{Key: "user_id", Value: account.UserID}}
+	_, err = collection.ReplaceOne(ctx, filter, stored, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to put CalDAV account for userID=%s: %w", account.UserID, err)
+	}
+
+	return nil
+}
+
+// protectCalDAVAccount encrypts the account's password for storage
+func (p *Provider) protectCalDAVAccount(account types.CalDAVAccount) (storedCalDAVAccount, error) {
+	encryptedPassword, err := p.secrets.encryptSecret(account.Password)
+	if err != nil {
+		return storedCalDAVAccount{}, fmt.Errorf("failed to encrypt CalDAV password for userID=%s: %w", account.UserID, err)
+	}
+	return storedCalDAVAccount{
+		UserID:            account.UserID,
+		URL:               account.URL,
+		Username:          account.Username,
+		EncryptedPassword: encryptedPassword,
+	}, nil
+}
+
+// encryptCalDAVPasswords encrypts the passwords of the accounts
+// that were stored before passwords were encrypted
+func (p *Provider) encryptCalDAVPasswords(ctx context.Context) error {
+	collection := p.calDAVAccounts()
+
+	cursor, err := collection.Find(ctx, bson.M{"password": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var legacy storedCalDAVAccount
+		err := cursor.Decode(&legacy)
+		if err != nil {
+			return err
+		}
+		stored, err := p.protectCalDAVAccount(types.CalDAVAccount{
+			UserID:   legacy.UserID,
+			URL:      legacy.URL,
+			Username: legacy.Username,
+			Password: legacy.Password,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = collection.ReplaceOne(ctx, bson.D{{Key: "user_id", Value: legacy.UserID}}, stored)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt CalDAV password for userID=%s: %w", legacy.UserID, err)
+		}
+	}
+	return cursor.Err()
+}
+
+func (p *Provider) DeleteCalDAVAccount(ctx context.Context, userID string) error {
+	collection := p.calDAVAccounts()
+
+	_, err := collection.DeleteOne(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return fmt.Errorf("failed to delete CalDAV account for userID=%s: %w", userID, err)
+	}
+
+	return nil
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -29,6 +32,10 @@ type Provider struct {
 	databaseName  string
 	clusterName   string
 	client        *mongo.Client
+	secrets       *secretCipher
+	// Users' locations are snapped to a grid of this size (in meters, or not at all if 0)
+	// and then encrypted before they are stored
+	locationGrid float64
 }
 
 // Make sure Provider implements db.Provider
@@ -56,6 +63,23 @@ func NewProvider(logger zerolog.Logger) (*Provider, error) {
 		return nil, err
 	}
 
+	encryptionKey, err := env.GetEnv("encryption key", "ENCRYPTION_KEY")
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := newSecretCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	locationGrid := float64(defaultLocationGrid)
+	if grid, ok := os.LookupEnv("LOCATION_GRID_METERS"); ok && grid != "" {
+		locationGrid, err = strconv.ParseFloat(grid, 64)
+		if err != nil || locationGrid < 0 {
+			return nil, fmt.Errorf("invalid location grid size '%s' (expected a number of meters)", grid)
+		}
+	}
+
 	connectionURI := fmt.Sprintf("mongodb+srv://%s:%s@%s.6ta2w.mongodb.net/%s?retryWrites=true&w=majority",
 		username, password, clusterName, databaseName)
 	return &Provider{
@@ -64,6 +88,8 @@ func NewProvider(logger zerolog.Logger) (*Provider, error) {
 		databaseName:  databaseName,
 		clusterName:   clusterName,
 		client:        nil,
+		secrets:       secrets,
+		locationGrid:  locationGrid,
 	}, nil
 }
 
@@ -119,6 +145,79 @@ func (p *Provider) initialize(ctx context.Context) error {
 		return err
 	}
 
+	_, err = p.calDAVAccounts().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"user_id": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// CalDAV passwords stored before they were encrypted are encrypted now
+	err = p.encryptCalDAVPasswords(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.feedTokens().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"user_id": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.M{"token": 1},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.profiles().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"user_id": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Cached place searches are deleted once they expire
+	_, err = p.placeCache().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"key": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.M{"expires_at": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Used to find the finalized events that users are attending
+	_, err = p.events().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "guild_id", Value: 1}, {Key: "finalized", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Used to check that the photo proxy only serves the photos of venues being voted on
+	_, err = p.events().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.M{"vote_options.address.photo": 1},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Photo links stored with the API key are replaced with photo references
+	err = p.migrateLegacyPhotos(ctx)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -134,13 +233,12 @@ func (p *Provider) GetSingle(ctx context.Context, eventID string) (*types.Event,
 		return nil, db.NewNotFoundError(eventID)
 	}
 
-	var event types.Event
-	err := result.Decode(&event)
+	raw, err := result.DecodeBytes()
 	if err != nil {
 		return nil, err
 	}
 
-	return &event, nil
+	return p.decodeEvent(raw)
 }
 
 func (p *Provider) CreatePartial(ctx context.Context, event types.Event) error {
@@ -155,6 +253,9 @@ func (p *Provider) CreatePartial(ctx context.Context, event types.Event) error {
 	if event.UserVotes == nil {
 		event.UserVotes = make(map[string]types.UserVotes)
 	}
+	if event.UserPollAnswers == nil {
+		event.UserPollAnswers = make(map[string]types.PollAnswers)
+	}
 	_, err := collection.InsertOne(ctx, event)
 	if err != nil {
 		if writeException, ok := err.(mongo.WriteException); ok && isDuplicate(writeException) {
@@ -175,7 +276,8 @@ func (p *Provider) CreatePartial(ctx context.Context, event types.Event) error {
 // - userVotes
 // - userAvailability
 // - userLocations
-// - userVotes
+// - userPollAnswers
+// - finalized, finalTime, finalLocation
 func (p *Provider) PopulateEvent(ctx context.Context, event types.Event, userID string) error {
 	collection := p.events()
 
@@ -199,7 +301,13 @@ func (p *Provider) PopulateEvent(ctx context.Context, event types.Event, userID
 		// - GuildID
 		// - Populated
 		// - UserVotes
-		if k == "id" || k == "creator_id" || k == "guild_id" || k == "populated" || k == "user_votes" || k == "channel_id" || k == "user_availability" || k == "user_locations" || k == "vote_options" || k == "user_votes" {
+		// - UserPollAnswers
+		// - Finalized, FinalTime, FinalLocation
+		// - Cancelled, Sequence, UpdatedAt
+		// - SuggestedVenues
+		if k == "id" || k == "creator_id" || k == "guild_id" || k == "populated" || k == "user_votes" || k == "channel_id" || k == "user_availability" || k == "user_locations" || k == "vote_options" || k == "user_poll_answers" ||
+			k == "finalized" || k == "final_time" || k == "final_location" || k == "cancelled" || k == "sequence" || k == "updated_at" ||
+			k == "suggested_venues" {
 			continue
 		}
 		updateDocument = append(updateDocument, bson.E{Key: k, Value: v})
@@ -258,13 +366,12 @@ func (p *Provider) GetEvent(ctx context.Context, id string) (*types.Event, error
 		return nil, db.NewNotFoundError(id)
 	}
 
-	var event types.Event
-	err := result.Decode(&event)
+	raw, err := result.DecodeBytes()
 	if err != nil {
 		return nil, err
 	}
 
-	return &event, nil
+	return p.decodeEvent(raw)
 }
 
 // Detects if the given write exception is caused by (in part)
@@ -312,16 +419,16 @@ func (p *Provider) PutUserAvailabilityAndLocation(ctx context.Context, userID st
 		return fmt.Errorf("failed to marshal availability: %w", err)
 	}
 
-	locationJson, err := toRawRepresentation(location)
+	protectedLocation, err := p.protectLocation(location)
 	if err != nil {
-		return fmt.Errorf("failed to marshal location: %w", err)
+		return fmt.Errorf("failed to encrypt location: %w", err)
 	}
 
 	filter := bson.D{{Key: "id", Value: eventID}}
 	updateQuery := bson.M{
 		"$set": bson.M{
 			fmt.Sprintf("user_availability.%s", userID): rawToBson(availabilityJson),
-			fmt.Sprintf("user_locations.%s", userID):   rawToBson(locationJson),
+			fmt.Sprintf("user_locations.%s", userID):    protectedLocation,
 		},
 	}
 
@@ -333,6 +440,37 @@ func (p *Provider) PutUserAvailabilityAndLocation(ctx context.Context, userID st
 	return nil
 }
 
+func (p *Provider) PutUserPollAnswersAndLocation(ctx context.Context, userID string,
+	answers types.PollAnswers, location types.UserLocation, eventID string) error {
+
+	collection := p.events()
+
+	answersJson, err := toRawRepresentation(answers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal poll answers: %w", err)
+	}
+
+	protectedLocation, err := p.protectLocation(location)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt location: %w", err)
+	}
+
+	filter := bson.D{{Key: "id", Value: eventID}}
+	updateQuery := bson.M{
+		"$set": bson.M{
+			fmt.Sprintf("user_poll_answers.%s", userID): rawToBson(answersJson),
+			fmt.Sprintf("user_locations.%s", userID):    protectedLocation,
+		},
+	}
+
+	_, err = collection.UpdateOne(ctx, filter, updateQuery)
+	if err != nil {
+		return fmt.Errorf("failed to update poll answers and location for userID=%s eventID=%s: %w", userID, eventID, err)
+	}
+
+	return nil
+}
+
 func (p *Provider) GetAllEvents(ctx context.Context) ([]*types.Event, error) {
 	collection := p.events()
 	cursor, err := collection.Find(ctx, bson.D{{}})
@@ -342,12 +480,11 @@ func (p *Provider) GetAllEvents(ctx context.Context) ([]*types.Event, error) {
 
 	var events []*types.Event
 	for cursor.Next(ctx) {
-		var event types.Event
-		err := cursor.Decode(&event)
+		event, err := p.decodeEvent(cursor.Current)
 		if err != nil {
 			return nil, err
 		}
-		events = append(events, &event)
+		events = append(events, event)
 	}
 
 	return events, nil
@@ -360,6 +497,15 @@ func (p *Provider) UpdateVoteOptions(ctx context.Context, voteOptions types.Vote
 	if err != nil {
 		return fmt.Errorf("failed to marshal voteOptions: %w", err)
 	}
+	if voteOptions.Midpoint != nil {
+		voteOptionsJson["midpoint"], err = p.secrets.encrypt(types.UserLocation{
+			Latitude:  voteOptions.Midpoint.Latitude,
+			Longitude: voteOptions.Midpoint.Longitude,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encrypt midpoint: %w", err)
+		}
+	}
 
 	filter := bson.D{{Key: "id", Value: eventID}}
 	updateQuery := bson.M{
@@ -375,3 +521,192 @@ func (p *Provider) UpdateVoteOptions(ctx context.Context, voteOptions types.Vote
 
 	return nil
 }
+
+func (p *Provider) FinalizeEvent(ctx context.Context, eventID string, finalTime types.TimePair, finalLocation types.Location) error {
+	collection := p.events()
+
+	finalTimeJson, err := toRawRepresentation(finalTime)
+	if err != nil {
+		return fmt.Errorf("failed to marshal final time: %w", err)
+	}
+
+	finalLocationJson, err := toRawRepresentation(finalLocation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal final location: %w", err)
+	}
+
+	filter := bson.D{{Key: "id", Value: eventID}}
+	updateQuery := bson.M{
+		"$set": bson.M{
+			"finalized":      true,
+			"final_time":     rawToBson(finalTimeJson),
+			"final_location": rawToBson(finalLocationJson),
+			"updated_at":     time.Now(),
+			// Users' locations are only needed to find places to vote on
+			"user_locations": bson.M{},
+		},
+		// The midpoint is near the users' locations, so it is deleted along with them
+		"$unset": bson.M{"vote_options.midpoint": ""},
+		// Finalizing again (with a different time or location) reschedules the event
+		"$inc": bson.M{"sequence": 1},
+	}
+
+	result, err := collection.UpdateOne(ctx, filter, updateQuery)
+	if err != nil {
+		return fmt.Errorf("failed to finalize eventID=%s: %w", eventID, err)
+	}
+	if result.MatchedCount == 0 {
+		return db.NewNotFoundError(eventID)
+	}
+
+	return nil
+}
+
+func (p *Provider) DeleteLocations(ctx context.Context, eventID string) error {
+	collection := p.events()
+
+	filter := bson.D{{Key: "id", Value: eventID}}
+	updateQuery := bson.M{
+		"$set":   bson.M{"user_locations": bson.M{}},
+		"$unset": bson.M{"vote_options.midpoint": ""},
+	}
+
+	result, err := collection.UpdateOne(ctx, filter, updateQuery)
+	if err != nil {
+		return fmt.Errorf("failed to delete locations for eventID=%s: %w", eventID, err)
+	}
+	if result.MatchedCount == 0 {
+		return db.NewNotFoundError(eventID)
+	}
+
+	return nil
+}
+
+func (p *Provider) GetFinalizedEventsForUsers(ctx context.Context, guildID string, userIDs []string) ([]*types.Event, error) {
+	collection := p.events()
+
+	filter := bson.M{
+		"guild_id":            guildID,
+		"finalized":           true,
+		"cancelled":           bson.M{"$ne": true},
+		"final_time.users.id": bson.M{"$in": userIDs},
+	}
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find finalized events for guildID=%s: %w", guildID, err)
+	}
+	defer cursor.Close(ctx)
+
+	var events []*types.Event
+	for cursor.Next(ctx) {
+		event, err := p.decodeEvent(cursor.Current)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (p *Provider) CancelEvent(ctx context.Context, eventID string) error {
+	collection := p.events()
+
+	filter := bson.D{{Key: "id", Value: eventID}}
+	updateQuery := bson.M{
+		"$set": bson.M{
+			"cancelled":      true,
+			"updated_at":     time.Now(),
+			"user_locations": bson.M{},
+		},
+		"$unset": bson.M{"vote_options.midpoint": ""},
+		"$inc":   bson.M{"sequence": 1},
+	}
+
+	result, err := collection.UpdateOne(ctx, filter, updateQuery)
+	if err != nil {
+		return fmt.Errorf("failed to cancel eventID=%s: %w", eventID, err)
+	}
+	if result.MatchedCount == 0 {
+		return db.NewNotFoundError(eventID)
+	}
+
+	return nil
+}
+
+func (p *Provider) AddSuggestedVenue(ctx context.Context, eventID string, venue types.Location) error {
+	collection := p.events()
+
+	venueJson, err := toRawRepresentation(venue)
+	if err != nil {
+		return fmt.Errorf("failed to marshal venue: %w", err)
+	}
+
+	filter := bson.D{{Key: "id", Value: eventID}}
+	updateQuery := bson.M{
+		"$push": bson.M{"suggested_venues": rawToBson(venueJson)},
+	}
+
+	result, err := collection.UpdateOne(ctx, filter, updateQuery)
+	if err != nil {
+		return fmt.Errorf("failed to add suggested venue to eventID=%s: %w", eventID, err)
+	}
+	if result.MatchedCount == 0 {
+		return db.NewNotFoundError(eventID)
+	}
+
+	return nil
+}
+
+func (p *Provider) RemoveSuggestedVenue(ctx context.Context, eventID string, placeID string) error {
+	collection := p.events()
+
+	filter := bson.D{{Key: "id", Value: eventID}}
+	updateQuery := bson.M{
+		"$pull": bson.M{"suggested_venues": bson.M{"place_id": placeID}},
+	}
+
+	result, err := collection.UpdateOne(ctx, filter, updateQuery)
+	if err != nil {
+		return fmt.Errorf("failed to remove suggested venue placeID=%s from eventID=%s: %w", placeID, eventID, err)
+	}
+	if result.MatchedCount == 0 {
+		return db.NewNotFoundError(eventID)
+	}
+
+	return nil
+}
+
+func (p *Provider) GetEventsForUser(ctx context.Context, userID string) ([]*types.Event, error) {
+	collection := p.events()
+
+	// User IDs are used as keys in the field paths below
+	if userID == "" || strings.ContainsAny(userID, ".$") {
+		return nil, fmt.Errorf("invalid userID=%s", userID)
+	}
+
+	filter := bson.M{
+		"$or": bson.A{
+			bson.M{"user_availability." + userID: bson.M{"$exists": true}},
+			bson.M{"user_votes." + userID: bson.M{"$exists": true}},
+			bson.M{"user_poll_answers." + userID: bson.M{"$exists": true}},
+			bson.M{"final_time.users.id": userID},
+		},
+	}
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find events for userID=%s: %w", userID, err)
+	}
+	defer cursor.Close(ctx)
+
+	var events []*types.Event
+	for cursor.Next(ctx) {
+		event, err := p.decodeEvent(cursor.Current)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,83 @@
+package mongo
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// secretCipher encrypts users' secrets (such as CalDAV passwords) before they are stored,
+// so the database never has them in plain text.
+// It also hashes values that are looked up by but shouldn't be stored in plain text.
+type secretCipher struct {
+	aead cipher.AEAD
+	// Derived from the encryption key, so the same key isn't used to encrypt and to hash
+	hashKey []byte
+}
+
+// newSecretCipher creates a cipher from a base64-encoded 256-bit key
+func newSecretCipher(encodedKey string) (*secretCipher, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("the encryption key must be 32 bytes encoded as base64")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	derive := hmac.New(sha256.New, key)
+	derive.Write([]byte("hash"))
+	return &secretCipher{aead: aead, hashKey: derive.Sum(nil)}, nil
+}
+
+// hash returns the HMAC-SHA256 of the value (encoded as hex),
+// which is the same for the same value but can't be reversed without the key
+func (c *secretCipher) hash(value string) string {
+	mac := hmac.New(sha256.New, c.hashKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// encryptSecret encrypts a secret such as a password
+func (c *secretCipher) encryptSecret(secret string) (string, error) {
+	return c.seal([]byte(secret))
+}
+
+func (c *secretCipher) decryptSecret(encrypted string) (string, error) {
+	plaintext, err := c.open(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// seal encrypts the plaintext with a random nonce,
+// returning the nonce and ciphertext encoded as base64
+func (c *secretCipher) seal(plaintext []byte) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, plaintext, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *secretCipher) open(encoded string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return nil, errors.New("invalid ciphertext")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	return c.aead.Open(nil, nonce, ciphertext, nil)
+}
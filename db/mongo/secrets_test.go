@@ -0,0 +1,65 @@
+package mongo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+const testEncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func TestCalDAVPasswordEncryption(t *testing.T) {
+	secrets, err := newSecretCipher(testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &Provider{secrets: secrets}
+
+	stored, err := provider.protectCalDAVAccount(types.CalDAVAccount{UserID: "001", Password: "app-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Password != "" || strings.Contains(stored.EncryptedPassword, "app-password") {
+		t.Errorf("expected only the encrypted password to be stored, got %+v", stored)
+	}
+	password, err := secrets.decryptSecret(stored.EncryptedPassword)
+	if err != nil || password != "app-password" {
+		t.Errorf("decrypted password = %q (err = %v), want app-password", password, err)
+	}
+
+	otherKey, err := newSecretCipher("ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := otherKey.decryptSecret(stored.EncryptedPassword); err == nil {
+		t.Error("expected decryption with a different key to fail")
+	}
+	if _, err := newSecretCipher("too-short"); err == nil {
+		t.Error("expected an invalid key to be rejected")
+	}
+}
+
+func TestHash(t *testing.T) {
+	secrets, err := newSecretCipher(testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "google|33.776,-84.396|1500|restaurant"
+
+	hashed := secrets.hash(key)
+	if hashed != secrets.hash(key) {
+		t.Error("expected the same value to always have the same hash")
+	}
+	if strings.Contains(hashed, "33.776") || hashed == secrets.hash("google|33.777,-84.396|1500|restaurant") {
+		t.Errorf("expected the hash to hide the value, got %s", hashed)
+	}
+
+	otherKey, err := newSecretCipher("ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherKey.hash(key) == hashed {
+		t.Error("expected a different key to give a different hash")
+	}
+}
@@ -0,0 +1,276 @@
+package ical
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Test//Test//EN\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:standup\r\n" +
+	"SUMMARY:Standup\\, daily\r\n" +
+	"DTSTART;TZID=America/New_York:20220301T090000\r\n" +
+	"DURATION:PT30M\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=7\r\n" +
+	"EXDATE;TZID=America/New_York:20220304T090000\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"TRIGGER:-PT15M\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:standup\r\n" +
+	"RECURRENCE-ID;TZID=America/New_York:20220307T090000\r\n" +
+	"DTSTART;TZID=America/New_York:20220307T100000\r\n" +
+	"DTEND;TZID=America/New_York:20220307T103000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:dinner\r\n" +
+	"SUMMARY:Dinner with a very long\r\n" +
+	"  name\r\n" +
+	"DTSTART:20220302T230000Z\r\n" +
+	"DTEND:20220303T010000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:holiday\r\n" +
+	"DTSTART;VALUE=DATE:20220305\r\n" +
+	"TRANSP:TRANSPARENT\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:bad-rule\r\n" +
+	"DTSTART:20220301T120000Z\r\n" +
+	"RRULE:FREQ=SECONDLY\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VFREEBUSY\r\n" +
+	"FREEBUSY;FBTYPE=BUSY:20220303T150000Z/PT1H,20220303T180000Z/20220303T190000Z\r\n" +
+	"FREEBUSY;FBTYPE=FREE:20220303T200000Z/PT1H\r\n" +
+	"END:VFREEBUSY\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParse(t *testing.T) {
+	calendar, err := Parse(strings.NewReader(testCalendar), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calendar.Events) != 5 {
+		t.Fatalf("expected 5 events, got %d", len(calendar.Events))
+	}
+	if summary := calendar.Events[0].Summary; summary != "Standup, daily" {
+		t.Errorf("expected the summary to be unescaped, got %q", summary)
+	}
+	if summary := calendar.Events[2].Summary; summary != "Dinner with a very long name" {
+		t.Errorf("expected the summary to be unfolded, got %q", summary)
+	}
+	if holiday := calendar.Events[3]; !holiday.AllDay || holiday.End.Sub(holiday.Start) != 24*time.Hour {
+		t.Errorf("expected an all-day event, got %+v", holiday)
+	}
+	if len(calendar.Warnings) != 1 || !strings.Contains(calendar.Warnings[0], "SECONDLY") {
+		t.Errorf("expected a warning about the unsupported rule, got %v", calendar.Warnings)
+	}
+	if len(calendar.FreeBusy) != 2 {
+		t.Errorf("expected 2 busy periods, got %+v", calendar.FreeBusy)
+	}
+}
+
+func TestBusyPeriods(t *testing.T) {
+	calendar, err := Parse(strings.NewReader(testCalendar), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2022, time.March, day, hour, minute, 0, 0, newYork)
+	}
+	utc := func(day int, hour int) time.Time {
+		return time.Date(2022, time.March, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	expected := []Period{
+		// The rule starts on a Tuesday, which is its first instance
+		{Start: at(1, 9, 0), End: at(1, 9, 30)},
+		{Start: at(2, 9, 0), End: at(2, 9, 30)},
+		{Start: utc(2, 23), End: utc(3, 1)},
+		{Start: utc(3, 15), End: utc(3, 16)},
+		{Start: utc(3, 18), End: utc(3, 19)},
+		// The 4th is excluded (but still counts towards the 7 instances) and the 7th is moved
+		{Start: at(7, 10, 0), End: at(7, 10, 30)},
+		{Start: at(9, 9, 0), End: at(9, 9, 30)},
+		{Start: at(11, 9, 0), End: at(11, 9, 30)},
+		// Daylight saving time starts on the 13th
+		{Start: at(14, 9, 0), End: at(14, 9, 30)},
+	}
+
+	periods, err := calendar.BusyPeriods(utc(1, 0), utc(31, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != len(expected) {
+		t.Fatalf("expected %d periods, got %+v", len(expected), periods)
+	}
+	for i := range expected {
+		if !periods[i].Start.Equal(expected[i].Start) || !periods[i].End.Equal(expected[i].End) {
+			t.Errorf("period %d: expected %v - %v, got %v - %v", i,
+				expected[i].Start, expected[i].End, periods[i].Start, periods[i].End)
+		}
+	}
+}
+
+func TestMonthlyRule(t *testing.T) {
+	rule, err := parseRule("FREQ=MONTHLY;BYDAY=-1FR;UNTIL=20220630T235959Z", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dtstart := time.Date(2022, time.January, 28, 19, 0, 0, 0, time.UTC)
+	expected := []int{28, 25, 25, 29, 27, 24}
+	starts, err := rule.expand(dtstart, dtstart, time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
+		&expansionBudget{remaining: maxRecurrenceSteps})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(starts) != len(expected) {
+		t.Fatalf("expected %d instances, got %v", len(expected), starts)
+	}
+	for i, start := range starts {
+		if start.Day() != expected[i] || start.Month() != time.Month(i+1) {
+			t.Errorf("instance %d: expected day %d of month %d, got %v", i, expected[i], i+1, start)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	start := time.Date(2022, time.March, 4, 23, 0, 0, 0, time.UTC)
+	event := Event{
+		UID:         "abcde@test",
+		Summary:     "Dinner; then games, maybe",
+		Description: "A description that is long enough that it has to be folded onto more than one line ✓✓✓✓",
+		Location:    "Pizza Place, 123 Main St",
+		Geo:         &Geo{Latitude: 33.7756, Longitude: -84.3963},
+		Organizer:   &Organizer{Name: "Some \"User\"", URI: "https://discord.com/users/1234"},
+		Start:       start,
+		End:         start.Add(2 * time.Hour),
+	}
+
+	var written strings.Builder
+	err := Write(&written, &Calendar{Events: []Event{event}}, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(written.String(), "\r\n") {
+		if len(line) > maxLineLength {
+			t.Errorf("line %q is longer than %d bytes", line, maxLineLength)
+		}
+	}
+	if !strings.Contains(written.String(), "GEO:33.775600;-84.396300\r\n") {
+		t.Errorf("expected the coordinates to be written, got %s", written.String())
+	}
+
+	calendar, err := Parse(strings.NewReader(written.String()), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calendar.Events) != 1 {
+		t.Fatalf("expected 1 event, got %+v", calendar.Events)
+	}
+	parsed := calendar.Events[0]
+	if parsed.UID != event.UID || parsed.Summary != event.Summary || parsed.Description != event.Description || parsed.Location != event.Location {
+		t.Errorf("expected %+v, got %+v", event, parsed)
+	}
+	if !parsed.Start.Equal(event.Start) || !parsed.End.Equal(event.End) {
+		t.Errorf("expected %v - %v, got %v - %v", event.Start, event.End, parsed.Start, parsed.End)
+	}
+}
+
+func TestLongRunningRule(t *testing.T) {
+	// Daily since 1990, which is more than 10000 days before the range
+	calendar, err := Parse(strings.NewReader("BEGIN:VCALENDAR\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"UID:standup\r\n"+
+		"DTSTART:19900101T090000Z\r\n"+
+		"DTEND:19900101T093000Z\r\n"+
+		"RRULE:FREQ=DAILY\r\n"+
+		"END:VEVENT\r\n"+
+		"END:VCALENDAR\r\n"), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+	periods, err := calendar.BusyPeriods(from, from.AddDate(0, 0, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 3 || !periods[0].Start.Equal(from.Add(9*time.Hour)) {
+		t.Errorf("expected an instance on each of the 3 days, got %+v", periods)
+	}
+}
+
+func TestSkippingMatchesFullExpansion(t *testing.T) {
+	dtstart := time.Date(2019, time.January, 30, 19, 0, 0, 0, time.UTC)
+	after := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+	before := after.AddDate(0, 3, 0)
+
+	for _, value := range []string{
+		"FREQ=DAILY;INTERVAL=3",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH",
+		"FREQ=MONTHLY;BYMONTHDAY=-1",
+		"FREQ=YEARLY;BYMONTH=3,4;BYDAY=1SU",
+	} {
+		rule, err := parseRule(value, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		skipped, err := rule.expand(dtstart, after, before, &expansionBudget{remaining: maxRecurrenceSteps})
+		if err != nil {
+			t.Fatal(err)
+		}
+		full, err := rule.expand(dtstart, dtstart, before, &expansionBudget{remaining: maxRecurrenceSteps})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var expected []time.Time
+		for _, start := range full {
+			if !start.Before(after) {
+				expected = append(expected, start)
+			}
+		}
+		if len(skipped) != len(expected) || len(expected) == 0 {
+			t.Fatalf("%s: expected %v, got %v", value, expected, skipped)
+		}
+		for i := range expected {
+			if !skipped[i].Equal(expected[i]) {
+				t.Errorf("%s: instance %d: expected %v, got %v", value, i, expected[i], skipped[i])
+			}
+		}
+	}
+}
+
+func TestTooManyInstances(t *testing.T) {
+	// Every event is expanded from 2000, since rules with a COUNT can't skip ahead
+	var file strings.Builder
+	file.WriteString("BEGIN:VCALENDAR\r\n")
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&file, "BEGIN:VEVENT\r\nUID:%d\r\nDTSTART:20000101T090000Z\r\nDTEND:20000101T093000Z\r\n"+
+			"RRULE:FREQ=DAILY;COUNT=100000\r\nEND:VEVENT\r\n", i)
+	}
+	file.WriteString("END:VCALENDAR\r\n")
+	calendar, err := Parse(strings.NewReader(file.String()), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+	_, err = calendar.BusyPeriods(from, from.AddDate(0, 0, 3))
+	if !errors.Is(err, ErrTooManyInstances) {
+		t.Errorf("expected ErrTooManyInstances, got %v", err)
+	}
+}
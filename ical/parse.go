@@ -0,0 +1,408 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout        = "20060102"
+	dateTimeLayout    = "20060102T150405"
+	utcDateTimeLayout = "20060102T150405Z"
+)
+
+// Calendar is the contents of an iCalendar (RFC 5545) file
+type Calendar struct {
+	// Shown by calendar apps when subscribing to the calendar
+	Name string
+	// How often calendar apps should check for changes to a subscribed calendar
+	// (if 0, then it is up to the calendar app)
+	RefreshInterval time.Duration
+	Events          []Event
+	// Busy periods from VFREEBUSY components
+	FreeBusy []Period
+	// Problems with parts of the file that were skipped
+	Warnings []string
+}
+
+// Event is a single VEVENT component
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	// If nil, then the event has no coordinates
+	Geo *Geo
+	// If nil, then the event has no organizer
+	Organizer *Organizer
+	URL       string
+	Start     time.Time
+	End       time.Time
+	AllDay    bool
+	// If nil, then the event doesn't repeat (other than its RecurrenceDates)
+	Rule            *Rule
+	RecurrenceDates []time.Time
+	ExceptionDates  []time.Time
+	// If set, then this event replaces the instance
+	// of the recurring event with the same UID that starts at this time
+	RecurrenceID time.Time
+	// Transparent events don't make the user busy
+	Transparent bool
+	Cancelled   bool
+	// Incremented each time the event is changed
+	Sequence     int
+	LastModified time.Time
+
+	// Used instead of an end if the event has a DURATION
+	duration *time.Duration
+}
+
+// Geo is the coordinates of an event's location
+type Geo struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Organizer is the person that organized an event
+type Organizer struct {
+	Name string
+	// Such as a mailto: URI or a link to the organizer's profile
+	URI string
+}
+
+// Period is a span of time between two instants
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+// property is a single content line, such as DTSTART;TZID=America/New_York:20220304T180000
+type property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Parse reads the events and free/busy periods from an iCalendar file.
+// Times without a time zone (and all-day dates) are interpreted in the given location.
+func Parse(r io.Reader, location *time.Location) (*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := &Calendar{}
+	var components []string
+	var event *Event
+	for i, line := range lines {
+		prop, err := parseProperty(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		switch prop.Name {
+		case "BEGIN":
+			components = append(components, strings.ToUpper(prop.Value))
+			if strings.EqualFold(prop.Value, "VEVENT") {
+				event = &Event{}
+			}
+			continue
+		case "END":
+			if len(components) == 0 || components[len(components)-1] != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("line %d: END:%s doesn't match a BEGIN", i+1, prop.Value)
+			}
+			components = components[:len(components)-1]
+			if strings.EqualFold(prop.Value, "VEVENT") {
+				if warning := finishEvent(event); warning != "" {
+					calendar.Warnings = append(calendar.Warnings, warning)
+				} else {
+					calendar.Events = append(calendar.Events, *event)
+				}
+				event = nil
+			}
+			continue
+		}
+		if len(components) == 0 {
+			continue
+		}
+
+		// Properties of nested components (such as VALARM) are ignored
+		switch components[len(components)-1] {
+		case "VEVENT":
+			if warning := setEventProperty(event, prop, location); warning != "" {
+				calendar.Warnings = append(calendar.Warnings, warning)
+			}
+		case "VFREEBUSY":
+			if prop.Name != "FREEBUSY" {
+				continue
+			}
+			if fbType, ok := prop.Params["FBTYPE"]; ok && strings.EqualFold(fbType, "FREE") {
+				continue
+			}
+			for _, value := range strings.Split(prop.Value, ",") {
+				period, err := parsePeriod(value, prop.Params, location)
+				if err != nil {
+					calendar.Warnings = append(calendar.Warnings, fmt.Sprintf("skipped free/busy period %q: %v", value, err))
+					continue
+				}
+				calendar.FreeBusy = append(calendar.FreeBusy, period)
+			}
+		}
+	}
+	if len(components) > 0 {
+		return nil, fmt.Errorf("BEGIN:%s is never ended", components[len(components)-1])
+	}
+
+	return calendar, nil
+}
+
+// unfold joins lines that were split by starting the continuation with a space or tab
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// parseProperty splits a content line into its name, parameters and value
+func parseProperty(line string) (property, error) {
+	// The value starts at the first colon that isn't in a quoted parameter value
+	quoted := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon == -1 {
+		return property{}, fmt.Errorf("content line %q has no value", line)
+	}
+
+	parts := splitUnquoted(line[:colon], ';')
+	prop := property{
+		Name:   strings.ToUpper(parts[0]),
+		Params: make(map[string]string),
+		Value:  line[colon+1:],
+	}
+	for _, param := range parts[1:] {
+		equals := strings.Index(param, "=")
+		if equals == -1 {
+			continue
+		}
+		prop.Params[strings.ToUpper(param[:equals])] = strings.Trim(param[equals+1:], `"`)
+	}
+	return prop, nil
+}
+
+func splitUnquoted(s string, separator rune) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i, c := range s {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == separator && !quoted {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// setEventProperty records a property of a VEVENT,
+// returning a warning if it couldn't be understood
+func setEventProperty(event *Event, prop property, location *time.Location) string {
+	var err error
+	switch prop.Name {
+	case "UID":
+		event.UID = prop.Value
+	case "SUMMARY":
+		event.Summary = unescapeText(prop.Value)
+	case "DESCRIPTION":
+		event.Description = unescapeText(prop.Value)
+	case "LOCATION":
+		event.Location = unescapeText(prop.Value)
+	case "DTSTART":
+		event.Start, event.AllDay, err = parseDateTime(prop.Value, prop.Params, location)
+	case "DTEND":
+		event.End, _, err = parseDateTime(prop.Value, prop.Params, location)
+	case "DURATION":
+		var duration time.Duration
+		duration, err = parseDuration(prop.Value)
+		if err == nil {
+			// Applied once the start is known
+			event.duration = &duration
+		}
+	case "RRULE":
+		event.Rule, err = parseRule(prop.Value, location)
+	case "RDATE", "EXDATE":
+		for _, value := range strings.Split(prop.Value, ",") {
+			var date time.Time
+			date, _, err = parseDateTime(value, prop.Params, location)
+			if err != nil {
+				break
+			}
+			if prop.Name == "RDATE" {
+				event.RecurrenceDates = append(event.RecurrenceDates, date)
+			} else {
+				event.ExceptionDates = append(event.ExceptionDates, date)
+			}
+		}
+	case "RECURRENCE-ID":
+		event.RecurrenceID, _, err = parseDateTime(prop.Value, prop.Params, location)
+	case "TRANSP":
+		event.Transparent = strings.EqualFold(prop.Value, "TRANSPARENT")
+	case "STATUS":
+		event.Cancelled = strings.EqualFold(prop.Value, "CANCELLED")
+	}
+	if err != nil {
+		return fmt.Sprintf("ignored %s of event %q: %v", prop.Name, event.UID, err)
+	}
+	return ""
+}
+
+// finishEvent fills in the end of the event,
+// returning a warning if the event can't be used
+func finishEvent(event *Event) string {
+	if event.Start.IsZero() {
+		return fmt.Sprintf("skipped event %q: it has no start", event.UID)
+	}
+
+	switch {
+	case event.End.IsZero() && event.duration != nil:
+		duration := *event.duration
+		if event.AllDay && duration%(24*time.Hour) == 0 {
+			// Whole days keep the same time of day across daylight saving changes
+			event.End = event.Start.AddDate(0, 0, int(duration/(24*time.Hour)))
+		} else {
+			event.End = event.Start.Add(duration)
+		}
+	case event.End.IsZero() && event.AllDay:
+		event.End = event.Start.AddDate(0, 0, 1)
+	case event.End.IsZero():
+		event.End = event.Start
+	case event.End.Before(event.Start):
+		return fmt.Sprintf("skipped event %q: it ends before it starts", event.UID)
+	}
+	return ""
+}
+
+// parseDateTime parses a DATE or DATE-TIME value,
+// returning whether it was a DATE (which is an all-day time)
+func parseDateTime(value string, params map[string]string, location *time.Location) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(dateLayout) {
+		date, err := time.ParseInLocation(dateLayout, value, location)
+		return date, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		dateTime, err := time.Parse(utcDateTimeLayout, value)
+		return dateTime, false, err
+	}
+
+	if tzid, ok := params["TZID"]; ok {
+		// Time zones that Go doesn't know about (such as Windows names)
+		// fall back to the default location
+		if tzLocation, err := time.LoadLocation(tzid); err == nil {
+			location = tzLocation
+		}
+	}
+	dateTime, err := time.ParseInLocation(dateTimeLayout, value, location)
+	return dateTime, false, err
+}
+
+// parsePeriod parses a PERIOD value, which is either start/end or start/duration
+func parsePeriod(value string, params map[string]string, location *time.Location) (Period, error) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return Period{}, fmt.Errorf("period %q must have a start and end", value)
+	}
+
+	start, _, err := parseDateTime(parts[0], params, location)
+	if err != nil {
+		return Period{}, err
+	}
+	if strings.HasPrefix(parts[1], "P") || strings.HasPrefix(parts[1], "+P") {
+		duration, err := parseDuration(parts[1])
+		if err != nil {
+			return Period{}, err
+		}
+		return Period{Start: start, End: start.Add(duration)}, nil
+	}
+	end, _, err := parseDateTime(parts[1], params, location)
+	if err != nil {
+		return Period{}, err
+	}
+	return Period{Start: start, End: end}, nil
+}
+
+// parseDuration parses a DURATION value such as PT1H30M or P1W
+func parseDuration(value string) (time.Duration, error) {
+	rest := strings.TrimPrefix(value, "+")
+	if strings.HasPrefix(rest, "-") {
+		return 0, fmt.Errorf("negative duration %q", value)
+	}
+	if !strings.HasPrefix(rest, "P") {
+		return 0, fmt.Errorf("duration %q must start with P", value)
+	}
+	rest = rest[1:]
+
+	units := map[byte]time.Duration{
+		'W': 7 * 24 * time.Hour,
+		'D': 24 * time.Hour,
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+	}
+	var duration time.Duration
+	number := ""
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case c == 'T':
+			continue
+		case c >= '0' && c <= '9':
+			number += string(c)
+		default:
+			unit, ok := units[c]
+			if !ok || number == "" {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return 0, err
+			}
+			duration += time.Duration(n) * unit
+			number = ""
+		}
+	}
+	if number != "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return duration, nil
+}
+
+func unescapeText(value string) string {
+	replacer := strings.NewReplacer(`\\`, `\`, `\;`, `;`, `\,`, `,`, `\n`, "\n", `\N`, "\n")
+	return replacer.Replace(value)
+}
@@ -0,0 +1,449 @@
+package ical
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence rules are expanded one period (day, week, month or year) at a time,
+// and at most this many periods and instances are expanded for a whole calendar
+// (so that large calendars can't take too long to expand)
+const maxRecurrenceSteps = 100000
+
+// ErrTooManyInstances is returned for calendars whose recurring events take too long to expand
+var ErrTooManyInstances = errors.New("the calendar has too many instances of recurring events")
+
+// Rule is a recurrence rule (RRULE).
+// Only the FREQ, INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH and WKST parts are supported.
+type Rule struct {
+	Frequency string
+	Interval  int
+	// If 0, then the number of instances isn't limited
+	Count int
+	// If zero, then the rule doesn't end
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+// WeekdayNum is a BYDAY entry such as MO or 2TU (the second Tuesday) or -1FR (the last Friday)
+type WeekdayNum struct {
+	Weekday time.Weekday
+	// If 0, then every matching weekday is included
+	Ordinal int
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func parseRule(value string, location *time.Location) (*Rule, error) {
+	rule := &Rule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		equals := strings.Index(part, "=")
+		if equals == -1 {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+		name, partValue := strings.ToUpper(part[:equals]), part[equals+1:]
+
+		var err error
+		switch name {
+		case "FREQ":
+			rule.Frequency = strings.ToUpper(partValue)
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(partValue)
+			if err == nil && rule.Interval < 1 {
+				err = fmt.Errorf("interval %d must be positive", rule.Interval)
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(partValue)
+		case "UNTIL":
+			rule.Until, _, err = parseDateTime(partValue, map[string]string{}, location)
+		case "BYDAY":
+			for _, day := range strings.Split(partValue, ",") {
+				var weekdayNum WeekdayNum
+				weekdayNum, err = parseWeekdayNum(day)
+				if err != nil {
+					break
+				}
+				rule.ByDay = append(rule.ByDay, weekdayNum)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(partValue, ",") {
+				var monthDay int
+				monthDay, err = strconv.Atoi(day)
+				if err != nil {
+					break
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, monthDay)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(partValue, ",") {
+				var monthNumber int
+				monthNumber, err = strconv.Atoi(month)
+				if err != nil {
+					break
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(monthNumber))
+			}
+		case "WKST":
+			// Weeks always start on Monday,
+			// which only changes the result for rules with an interval and multiple days
+		default:
+			return nil, fmt.Errorf("unsupported rule part %s", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule part %q: %w", part, err)
+		}
+	}
+
+	switch rule.Frequency {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	case "":
+		return nil, fmt.Errorf("rule %q has no frequency", value)
+	default:
+		return nil, fmt.Errorf("unsupported frequency %s", rule.Frequency)
+	}
+	return rule, nil
+}
+
+func parseWeekdayNum(value string) (WeekdayNum, error) {
+	if len(value) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid weekday %q", value)
+	}
+	weekday, ok := weekdays[strings.ToUpper(value[len(value)-2:])]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid weekday %q", value)
+	}
+
+	ordinal := 0
+	if len(value) > 2 {
+		var err error
+		ordinal, err = strconv.Atoi(value[:len(value)-2])
+		if err != nil {
+			return WeekdayNum{}, err
+		}
+	}
+	return WeekdayNum{Weekday: weekday, Ordinal: ordinal}, nil
+}
+
+// BusyPeriods returns every busy period that overlaps the range from the file's events
+// (including the instances of recurring events) and free/busy components, sorted by start.
+// It returns ErrTooManyInstances if the recurring events take too long to expand.
+func (c *Calendar) BusyPeriods(from time.Time, to time.Time) ([]Period, error) {
+	// Instances of recurring events that were moved or changed
+	// are replaced by separate events with a RECURRENCE-ID
+	overridden := make(map[string]bool)
+	for _, event := range c.Events {
+		if !event.RecurrenceID.IsZero() {
+			overridden[overrideKey(event.UID, event.RecurrenceID)] = true
+		}
+	}
+
+	budget := &expansionBudget{remaining: maxRecurrenceSteps}
+	var periods []Period
+	for _, event := range c.Events {
+		if event.Transparent || event.Cancelled {
+			continue
+		}
+		duration := event.End.Sub(event.Start)
+		// Instances that start before this end before the range
+		instances, err := event.instances(from.Add(-duration), to, budget)
+		if err != nil {
+			return nil, err
+		}
+		for _, start := range instances {
+			if event.RecurrenceID.IsZero() && overridden[overrideKey(event.UID, start)] {
+				continue
+			}
+			periods = appendOverlapping(periods, Period{Start: start, End: start.Add(duration)}, from, to)
+		}
+	}
+	for _, period := range c.FreeBusy {
+		periods = appendOverlapping(periods, period, from, to)
+	}
+
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Start.Before(periods[j].Start) })
+	return periods, nil
+}
+
+// expansionBudget is how many more periods and instances can be expanded
+type expansionBudget struct {
+	remaining int
+}
+
+// spend uses up one step of the budget, returning false if there were none left
+func (b *expansionBudget) spend() bool {
+	b.remaining--
+	return b.remaining >= 0
+}
+
+func overrideKey(uid string, start time.Time) string {
+	return fmt.Sprintf("%s/%d", uid, start.Unix())
+}
+
+// appendOverlapping appends the period if it overlaps the range (and isn't empty)
+func appendOverlapping(periods []Period, period Period, from time.Time, to time.Time) []Period {
+	if period.End.After(period.Start) && period.Start.Before(to) && period.End.After(from) {
+		return append(periods, period)
+	}
+	return periods
+}
+
+// instances returns the start of every instance of the event that starts in the range [after, before)
+func (e *Event) instances(after time.Time, before time.Time, budget *expansionBudget) ([]time.Time, error) {
+	starts := []time.Time{e.Start}
+	if e.Rule != nil {
+		var err error
+		starts, err = e.Rule.expand(e.Start, after, before, budget)
+		if err != nil {
+			return nil, err
+		}
+	}
+	starts = append(starts, e.RecurrenceDates...)
+
+	var instances []time.Time
+	seen := make(map[int64]bool)
+	for _, start := range starts {
+		if start.Before(after) || !start.Before(before) || seen[start.Unix()] || isException(e.ExceptionDates, start) {
+			continue
+		}
+		seen[start.Unix()] = true
+		instances = append(instances, start)
+	}
+	return instances, nil
+}
+
+func isException(exceptionDates []time.Time, start time.Time) bool {
+	for _, exceptionDate := range exceptionDates {
+		if exceptionDate.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// expand returns the starts of the rule's instances (including dtstart) in the range [after, before),
+// until the rule ends.
+// Rules without a COUNT skip straight to the periods around the range,
+// while rules with one are expanded from dtstart (to count the instances before the range).
+func (r *Rule) expand(dtstart time.Time, after time.Time, before time.Time, budget *expansionBudget) ([]time.Time, error) {
+	starts := []time.Time{}
+	if !dtstart.Before(after) && dtstart.Before(before) {
+		starts = append(starts, dtstart)
+	}
+
+	first := 0
+	if r.Count == 0 {
+		first = r.firstPeriod(dtstart, after)
+	}
+	count := 1
+	for period := first; ; period++ {
+		if !budget.spend() {
+			return nil, ErrTooManyInstances
+		}
+		candidates, periodStart := r.candidates(dtstart, period)
+		if !periodStart.Before(before) || (!r.Until.IsZero() && periodStart.After(r.Until)) {
+			return starts, nil
+		}
+		for _, candidate := range candidates {
+			if !candidate.After(dtstart) {
+				continue
+			}
+			if (!r.Until.IsZero() && candidate.After(r.Until)) || (r.Count > 0 && count >= r.Count) || !candidate.Before(before) {
+				return starts, nil
+			}
+			count++
+			if candidate.Before(after) {
+				continue
+			}
+			if !budget.spend() {
+				return nil, ErrTooManyInstances
+			}
+			starts = append(starts, candidate)
+		}
+	}
+}
+
+// firstPeriod returns the first period that can have instances starting at or after the given time
+// (where period 0 contains dtstart)
+func (r *Rule) firstPeriod(dtstart time.Time, after time.Time) int {
+	if !after.After(dtstart) {
+		return 0
+	}
+	startYear, startMonth, startDay := dtstart.Date()
+	afterYear, afterMonth, afterDay := after.In(dtstart.Location()).Date()
+	days := int(time.Date(afterYear, afterMonth, afterDay, 0, 0, 0, 0, time.UTC).
+		Sub(time.Date(startYear, startMonth, startDay, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+
+	var periods int
+	switch r.Frequency {
+	case "DAILY":
+		periods = days
+	case "WEEKLY":
+		// Weeks start on Monday
+		periods = (days + (int(dtstart.Weekday())+6)%7) / 7
+	case "MONTHLY":
+		periods = (afterYear-startYear)*12 + int(afterMonth-startMonth)
+	case "YEARLY":
+		periods = afterYear - startYear
+	}
+	// Starting a period early is harmless (its instances before the range are skipped)
+	first := periods/r.Interval - 1
+	if first < 0 {
+		return 0
+	}
+	return first
+}
+
+// candidates returns the sorted instances in the given period after dtstart
+// (where period 0 contains dtstart), and the start of the period
+func (r *Rule) candidates(dtstart time.Time, period int) ([]time.Time, time.Time) {
+	year, month, day := dtstart.Date()
+	hour, minute, second := dtstart.Clock()
+	location := dtstart.Location()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, 0, location)
+	}
+
+	var periodStart time.Time
+	var days []time.Time
+	switch r.Frequency {
+	case "DAILY":
+		periodStart = at(year, month, day+period*r.Interval)
+		days = []time.Time{periodStart}
+	case "WEEKLY":
+		// Weeks start on Monday
+		offset := (int(dtstart.Weekday()) + 6) % 7
+		periodStart = at(year, month, day-offset+7*period*r.Interval)
+		if len(r.ByDay) == 0 {
+			days = []time.Time{periodStart.AddDate(0, 0, offset)}
+			break
+		}
+		for _, weekdayNum := range r.ByDay {
+			days = append(days, periodStart.AddDate(0, 0, (int(weekdayNum.Weekday)+6)%7))
+		}
+	case "MONTHLY":
+		periodStart = at(year, month+time.Month(period*r.Interval), 1)
+		days = r.daysInMonth(periodStart, day)
+	case "YEARLY":
+		periodStart = at(year+period*r.Interval, time.January, 1)
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{month}
+		}
+		for _, byMonth := range months {
+			first := at(year+period*r.Interval, byMonth, 1)
+			days = append(days, r.daysInMonth(first, day)...)
+		}
+	}
+
+	var candidates []time.Time
+	for _, candidate := range days {
+		if r.matches(candidate) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	return candidates, periodStart
+}
+
+// daysInMonth returns the days in the month selected by BYMONTHDAY or BYDAY,
+// or the same day of the month as dtstart if neither is set
+func (r *Rule) daysInMonth(first time.Time, dtstartDay int) []time.Time {
+	length := first.AddDate(0, 1, -1).Day()
+
+	var days []time.Time
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, monthDay := range r.ByMonthDay {
+			if monthDay < 0 {
+				monthDay = length + monthDay + 1
+			}
+			if monthDay >= 1 && monthDay <= length {
+				days = append(days, first.AddDate(0, 0, monthDay-1))
+			}
+		}
+	case len(r.ByDay) > 0:
+		for _, weekdayNum := range r.ByDay {
+			var matching []time.Time
+			for monthDay := 1; monthDay <= length; monthDay++ {
+				day := first.AddDate(0, 0, monthDay-1)
+				if day.Weekday() == weekdayNum.Weekday {
+					matching = append(matching, day)
+				}
+			}
+			switch {
+			case weekdayNum.Ordinal == 0:
+				days = append(days, matching...)
+			case weekdayNum.Ordinal > 0 && weekdayNum.Ordinal <= len(matching):
+				days = append(days, matching[weekdayNum.Ordinal-1])
+			case weekdayNum.Ordinal < 0 && -weekdayNum.Ordinal <= len(matching):
+				days = append(days, matching[len(matching)+weekdayNum.Ordinal])
+			}
+		}
+	default:
+		// Months without the day (such as the 31st) are skipped
+		if dtstartDay <= length {
+			days = append(days, first.AddDate(0, 0, dtstartDay-1))
+		}
+	}
+	return days
+}
+
+// matches checks the parts of the rule that limit (rather than expand) the instances
+func (r *Rule) matches(candidate time.Time) bool {
+	if len(r.ByMonth) > 0 && r.Frequency != "YEARLY" && !containsMonth(r.ByMonth, candidate.Month()) {
+		return false
+	}
+	if r.Frequency == "DAILY" || (r.Frequency != "WEEKLY" && len(r.ByMonthDay) > 0) {
+		if len(r.ByDay) > 0 && !containsWeekday(r.ByDay, candidate.Weekday()) {
+			return false
+		}
+	}
+	if r.Frequency == "DAILY" || r.Frequency == "WEEKLY" {
+		if len(r.ByMonthDay) > 0 && !containsMonthDay(r.ByMonthDay, candidate) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsMonth(months []time.Month, month time.Month) bool {
+	for _, m := range months {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func containsWeekday(weekdayNums []WeekdayNum, weekday time.Weekday) bool {
+	for _, weekdayNum := range weekdayNums {
+		if weekdayNum.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func containsMonthDay(monthDays []int, day time.Time) bool {
+	length := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+	for _, monthDay := range monthDays {
+		if monthDay == day.Day() || monthDay == day.Day()-length-1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	productID = "-//3 Brain Cells//Super Auto Hangouts//EN"
+	// Lines longer than this many bytes are folded
+	maxLineLength = 75
+)
+
+// Write writes the calendar's events as an iCalendar (RFC 5545) file,
+// with each event's DTSTAMP set to the given time
+func Write(w io.Writer, calendar *Calendar, stamp time.Time) error {
+	writer := bufio.NewWriter(w)
+	line := func(name string, value string) {
+		writeFolded(writer, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", productID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if calendar.Name != "" {
+		line("X-WR-CALNAME", escapeText(calendar.Name))
+	}
+	if calendar.RefreshInterval > 0 {
+		interval := formatDuration(calendar.RefreshInterval)
+		line("REFRESH-INTERVAL;VALUE=DURATION", interval)
+		line("X-PUBLISHED-TTL", interval)
+	}
+	for _, event := range calendar.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", event.UID)
+		line("DTSTAMP", formatUTC(stamp))
+		if event.AllDay {
+			line("DTSTART;VALUE=DATE", event.Start.Format(dateLayout))
+			line("DTEND;VALUE=DATE", event.End.Format(dateLayout))
+		} else {
+			line("DTSTART", formatUTC(event.Start))
+			line("DTEND", formatUTC(event.End))
+		}
+		if event.Sequence > 0 {
+			line("SEQUENCE", fmt.Sprint(event.Sequence))
+		}
+		if !event.LastModified.IsZero() {
+			line("LAST-MODIFIED", formatUTC(event.LastModified))
+		}
+		if event.Summary != "" {
+			line("SUMMARY", escapeText(event.Summary))
+		}
+		if event.Description != "" {
+			line("DESCRIPTION", escapeText(event.Description))
+		}
+		if event.Location != "" {
+			line("LOCATION", escapeText(event.Location))
+		}
+		if event.Geo != nil {
+			line("GEO", fmt.Sprintf("%.6f;%.6f", event.Geo.Latitude, event.Geo.Longitude))
+		}
+		if event.Organizer != nil {
+			name := ""
+			if event.Organizer.Name != "" {
+				// Parameter values can't contain quotes
+				name = fmt.Sprintf(`;CN="%s"`, strings.ReplaceAll(event.Organizer.Name, `"`, "'"))
+			}
+			line("ORGANIZER"+name, event.Organizer.URI)
+		}
+		if event.URL != "" {
+			line("URL", event.URL)
+		}
+		if event.Transparent {
+			line("TRANSP", "TRANSPARENT")
+		}
+		if event.Cancelled {
+			line("STATUS", "CANCELLED")
+		} else {
+			line("STATUS", "CONFIRMED")
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+
+	return writer.Flush()
+}
+
+func formatUTC(t time.Time) string {
+	return t.UTC().Format(utcDateTimeLayout)
+}
+
+// formatDuration formats a DURATION value (rounded to the second)
+func formatDuration(duration time.Duration) string {
+	seconds := int(duration.Round(time.Second).Seconds())
+	return fmt.Sprintf("PT%dH%dM%dS", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// writeFolded writes a content line,
+// splitting it into lines of at most 75 bytes (without splitting characters)
+func writeFolded(w *bufio.Writer, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		split := limit
+		for split > 0 && !utf8.RuneStart(line[split]) {
+			split--
+		}
+		w.WriteString(line[:split])
+		w.WriteString("\r\n ")
+		line = line[split:]
+		// Continuation lines start with a space, which counts towards their length
+		limit = maxLineLength - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+func escapeText(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(value)
+}
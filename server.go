@@ -9,7 +9,10 @@ import (
 	"time"
 
 	"github.com/3-brain-cells/sah-backend/api/events"
+	"github.com/3-brain-cells/sah-backend/api/locations"
 	"github.com/3-brain-cells/sah-backend/api/oauth"
+	"github.com/3-brain-cells/sah-backend/api/photos"
+	"github.com/3-brain-cells/sah-backend/api/profiles"
 	"github.com/3-brain-cells/sah-backend/db"
 	"github.com/3-brain-cells/sah-backend/db/mongo"
 	"github.com/3-brain-cells/sah-backend/env"
@@ -30,6 +33,11 @@ type APIServer struct {
 	dbProvider     db.Provider
 	logger         zerolog.Logger
 	discordSession *discordgo.Session
+	places         *locations.Selector
+	geocoder       locations.Geocoder
+	router         locations.Router
+	photos         *photos.Cache
+	sessions       *oauth.Sessions
 }
 
 // NewAPIServer initializes the struct and all constituent components
@@ -51,10 +59,50 @@ func NewAPIServer(logger zerolog.Logger) (*APIServer, error) {
 		log.Fatalf("Invalid bot parameters: %v", err)
 	}
 
+	// Initialize the place providers used to find vote options,
+	// whose searches are cached (and optionally shared through the database)
+	placeCache, err := locations.NewPlaceCacheFromEnv(dbProvider)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not initialize place cache")
+	}
+	places, err := locations.NewSelectorFromEnv(placeCache)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not initialize place providers")
+	}
+
+	// Initialize the geocoder used to find users' locations from their addresses
+	geocoder, err := locations.NewGeocoderFromEnv()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not initialize geocoder")
+	}
+
+	// Initialize the router used to rank places by travel time
+	router, err := locations.NewRouterFromEnv()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not initialize router")
+	}
+
+	// Initialize the cache of photos served by the photo proxy
+	photoCache, err := photos.NewCacheFromEnv()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not initialize photo cache")
+	}
+
+	// Initialize the sessions that identify users who logged in with Discord
+	sessions, err := oauth.NewSessionsFromEnv()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not initialize sessions")
+	}
+
 	return &APIServer{
 		dbProvider:     dbProvider,
 		logger:         logger,
 		discordSession: s,
+		places:         places,
+		geocoder:       geocoder,
+		router:         router,
+		photos:         photoCache,
+		sessions:       sessions,
 	}, nil
 }
 
@@ -150,9 +198,12 @@ func (a *APIServer) routes() *chi.Mux {
 			w.WriteHeader(204)
 		})
 
-		r.Mount("/events", events.Routes(a.dbProvider, a.discordSession))
+		r.Mount("/events", events.Routes(a.dbProvider, a.discordSession, a.places, a.geocoder, a.router, a.sessions))
+		r.Mount("/profiles", profiles.Routes(a.dbProvider, a.sessions))
+		r.Mount("/photos", photos.Routes(a.dbProvider, a.places, a.photos))
+		r.Get("/feeds/{token}", events.GetFeed(a.dbProvider, a.dbProvider))
 	})
-	router.Mount("/", oauth.Routes(a.dbProvider))
+	router.Mount("/", oauth.Routes(a.dbProvider, a.sessions))
 
 	return router
 }
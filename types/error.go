@@ -3,4 +3,12 @@ package types
 // ErrorResponse is the generic error JSON shape returned by the API
 type ErrorResponse struct {
 	Message string `json:"message"`
+	// Only set for validation errors
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError describes why a single field of a request body is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
@@ -12,73 +12,245 @@ type Event struct {
 	EventID   string `json:"id" bson:"id"`
 	ChannelID string `json:"channel_id" bson:"channel_id"`
 
-	Title              string           `json:"title" bson:"title"`
-	Description        string           `json:"description" bson:"description"`
-	EarliestDate       time.Time        `json:"earliest_date" bson:"earliest_date"` // ISO 8601 string
-	LatestDate         time.Time        `json:"latest_date" bson:"latest_date"`     // ISO 8601 string
-	StartTimeHour      int              `json:"start_time_hour" bson:"start_time_hour"`
-	StartTimeMinute    int              `json:"start_time_minute" bson:"start_time_minute"`
-	EndTimeHour        int              `json:"end_time_hour" bson:"end_time_hour"`
-	EndTimeMinute      int              `json:"end_time_minute" bson:"end_time_minute"`
-	SwitchToVotingTime time.Time        `json:"switch_to_voting" bson:"switch_to_voting"` // ISO 8601 string
+	Title              string    `json:"title" bson:"title"`
+	Description        string    `json:"description" bson:"description"`
+	EarliestDate       time.Time `json:"earliest_date" bson:"earliest_date"` // ISO 8601 string
+	LatestDate         time.Time `json:"latest_date" bson:"latest_date"`     // ISO 8601 string
+	StartTimeHour      int       `json:"start_time_hour" bson:"start_time_hour"`
+	StartTimeMinute    int       `json:"start_time_minute" bson:"start_time_minute"`
+	EndTimeHour        int       `json:"end_time_hour" bson:"end_time_hour"`
+	EndTimeMinute      int       `json:"end_time_minute" bson:"end_time_minute"`
+	SwitchToVotingTime time.Time `json:"switch_to_voting" bson:"switch_to_voting"` // ISO 8601 string
+	// Optional windows that replace the daily start and end time on weekdays/weekends
+	WeekdayWindow *DailyWindow `json:"weekday_window" bson:"weekday_window"`
+	WeekendWindow *DailyWindow `json:"weekend_window" bson:"weekend_window"`
+	// Optional windows that replace the window on specific dates
+	DateWindows []DateWindow `json:"date_windows" bson:"date_windows"`
+	// Dates that the event can't take place on (such as holidays)
+	ExcludedDates []time.Time `json:"excluded_dates" bson:"excluded_dates"`
+	// The windows (at most one per date) in which the event can take place,
+	// resolved from all of the above when the event is populated
+	Windows []TimeWindow `json:"windows" bson:"windows"`
+
+	// One of EventModeGrid (the default if empty) or EventModePoll
+	Mode string `json:"mode" bson:"mode"`
+	// One of EventTypeInPerson (the default if empty), EventTypeVirtual or EventTypeHybrid
+	Type string `json:"type" bson:"type"`
+	// Where virtual and hybrid events take place online
+	// (a voice channel in the event's guild and/or a link to a meeting)
+	VoiceChannelID string `json:"voice_channel_id" bson:"voice_channel_id"`
+	MeetingLink    string `json:"meeting_link" bson:"meeting_link"`
+	// The times proposed by the creator (only for EventModePoll)
+	CandidateTimes []TimePair `json:"candidate_times" bson:"candidate_times"`
+	// How the point that places are searched for around is found
+	// from the users' locations (see the locations package; centroid if empty)
+	MidpointStrategy string `json:"midpoint_strategy" bson:"midpoint_strategy"`
+	// How users travel to the event (see the locations package; drive if empty),
+	// which places are ranked by
+	TravelMode string `json:"travel_mode" bson:"travel_mode"`
+	// Narrow down the places that are suggested
+	VenueFilters VenueFilters `json:"venue_filters" bson:"venue_filters"`
+	// Places that the creator and participants want to vote on,
+	// which are added to the places that are found when voting starts
+	SuggestedVenues []Location `json:"suggested_venues" bson:"suggested_venues"`
 
 	Populated   bool       `json:"populated" bson:"populated"`       // field is set once creator goes on web and populates
 	VoteOptions VoteOption `json:"vote_options" bson:"vote_options"` // ^ not done until this is done
 	// Maps Discord User ID => availability
 	UserAvailability map[string]UserAvailability `json:"user_availability" bson:"user_availability"` // ^ not done until this is done
 	// Maps Discord User ID => location
-	UserLocations    map[string]UserLocation `json:"user_locations" bson:"user_locations"`       // userID:userLocation
-	UserVotes        map[string]UserVotes    `json:"user_votes" bson:"user_votes"`               // ^ not done until this is done
+	UserLocations map[string]UserLocation `json:"user_locations" bson:"user_locations"` // userID:userLocation
+	UserVotes     map[string]UserVotes    `json:"user_votes" bson:"user_votes"`         // ^ not done until this is done
+	// Set once voting is over, along with the winning time and location
+	// (the users of the final time are the ones attending)
+	Finalized     bool     `json:"finalized" bson:"finalized"`
+	FinalTime     TimePair `json:"final_time" bson:"final_time"`
+	FinalLocation Location `json:"final_location" bson:"final_location"`
+	// Set if the creator cancels the event
+	Cancelled bool `json:"cancelled" bson:"cancelled"`
+	// Incremented each time the event is finalized or cancelled
+	// (so calendar apps know to update their copy of it)
+	Sequence  int       `json:"sequence" bson:"sequence"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+	// Maps Discord User ID => answers to the candidate times (only for EventModePoll)
+	UserPollAnswers map[string]PollAnswers `json:"user_poll_answers" bson:"user_poll_answers"`
 }
 
+const (
+	// EventModeGrid is the default mode, where users fill out their availability
+	// and the times to vote on are found automatically
+	EventModeGrid = "grid"
+	// EventModePoll is the mode where the creator proposes candidate times
+	// and users answer yes/no/maybe for each
+	EventModePoll = "poll"
+)
+
+const (
+	// EventTypeInPerson is the default type, where users meet at a venue that they vote on
+	EventTypeInPerson = "in_person"
+	// EventTypeVirtual is the type of events that only take place online,
+	// so users' locations aren't collected and there are no venues to vote on
+	EventTypeVirtual = "virtual"
+	// EventTypeHybrid is the type of events at a venue that users can also join online
+	EventTypeHybrid = "hybrid"
+)
+
 type VoteOption struct {
 	Location      []Location `json:"address" bson:"address"`
 	StartEndPairs []TimePair `json:"start_end_pairs" bson:"start_end_pairs"`
+	// The point that the locations were searched for around
+	// (null if no users submitted a location)
+	Midpoint *Coordinates `json:"midpoint" bson:"midpoint"`
+	// Maps Discord User ID => the user's travel time (in minutes) to each location,
+	// in the same order as the locations (-1 if there is no route)
+	TravelMinutes map[string][]int `json:"travel_minutes" bson:"travel_minutes"`
+}
+
+// VenueFilters narrow down the places that are suggested for an event.
+// The zero value searches for restaurants within 1500 meters of the midpoint.
+type VenueFilters struct {
+	// The categories in the locations package (such as "restaurant" or "park")
+	// or provider-specific categories to search for
+	Categories []string `json:"categories" bson:"categories"`
+	// Words that the places should match (such as "bowling")
+	Keywords []string `json:"keywords" bson:"keywords"`
+	// How far from the midpoint to search at first,
+	// which is widened if too few places are found
+	RadiusMeters int `json:"radius_meters" bson:"radius_meters"`
+	// Places rated lower than this are left out (places without a rating are kept)
+	MinRating float64 `json:"min_rating" bson:"min_rating"`
+	// From 1 ($) to 4 ($$$$), or 0 for any price level
+	MaxPriceLevel int `json:"max_price_level" bson:"max_price_level"`
+	// Only suggest places that are known to be open for the whole of the most popular time option
+	// (venues that users suggested are still voted on)
+	OpenAtChosenTime bool `json:"open_at_chosen_time" bson:"open_at_chosen_time"`
 }
 
 type Location struct {
 	Name    string  `json:"name" bson:"name"`
 	Address string  `json:"address" bson:"address"`
 	Rating  float64 `json:"rating" bson:"rating"`
-	Image   string  `json:"image" bson:"image"`
+	// A public link to an image of the location (which never includes provider credentials)
+	Image     string  `json:"image" bson:"image"`
 	Latitude  float64 `json:"latitude" bson:"latitude"`
 	Longitude float64 `json:"longitude" bson:"longitude"`
+	// The place provider that found the location (such as "google" or "yelp")
+	// and its ID for the location there
+	Provider string `json:"provider" bson:"provider"`
+	PlaceID  string `json:"place_id" bson:"place_id"`
+	// Link to more information about the location (if the provider has one)
+	URL string `json:"url" bson:"url"`
+	// From 1 ($) to 4 ($$$$), or 0 if the provider doesn't know it
+	PriceLevel int `json:"price_level" bson:"price_level"`
+	// The ID of the user that suggested the location (if it wasn't only found by a provider)
+	SuggestedBy string `json:"suggested_by,omitempty" bson:"suggested_by,omitempty"`
+	// The provider's reference to a photo of the location,
+	// which is served through the photo proxy (since getting it needs the provider's API key)
+	Photo string `json:"photo,omitempty" bson:"photo,omitempty"`
+	// When the location is open (nil if it isn't known)
+	Hours *OpeningHours `json:"hours,omitempty" bson:"hours,omitempty"`
+}
+
+// OpeningHours are when a location is open each week, in its local time
+type OpeningHours struct {
+	// Set if the location never closes (in which case there are no periods)
+	AlwaysOpen bool            `json:"always_open" bson:"always_open"`
+	Periods    []OpeningPeriod `json:"periods" bson:"periods"`
+	// The offset of the location's local time from UTC, in minutes
+	// (nil if it isn't known, in which case times are compared as they are given)
+	UTCOffsetMinutes *int `json:"utc_offset_minutes,omitempty" bson:"utc_offset_minutes,omitempty"`
+}
+
+// OpeningPeriod is when a location opens and when it next closes
+// (which can be on the next day, such as for bars that are open past midnight)
+type OpeningPeriod struct {
+	Open  WeeklyTime `json:"open" bson:"open"`
+	Close WeeklyTime `json:"close" bson:"close"`
+}
+
+// WeeklyTime is a time of day on a day of the week
+type WeeklyTime struct {
+	Day    time.Weekday `json:"day" bson:"day"`
+	Hour   int          `json:"hour" bson:"hour"`
+	Minute int          `json:"minute" bson:"minute"`
 }
 
 type UserLocation struct {
 	Latitude  float64 `json:"latitude" bson:"latitude"`
 	Longitude float64 `json:"longitude" bson:"longitude"`
+	// Describes the location (such as the address it was geocoded from)
+	Label string `json:"label,omitempty" bson:"label,omitempty"`
 }
 
 type TimePair struct {
 	Start time.Time `json:"start" bson:"start"`
 	End   time.Time `json:"end" bson:"end"`
-	Users []User `json:"users" bson:"users"`
+	Users []User    `json:"users" bson:"users"`
+	// Users that might be available (only for EventModePoll)
+	MaybeUsers []User `json:"maybe_users,omitempty" bson:"maybe_users,omitempty"`
 }
 
 type User struct {
-	ID       string `json:"id" bson:"id"`
+	ID    string `json:"id" bson:"id"`
 	Color string `json:"color" bson:"color"`
-	Name string `json:"name" bson:"name"`
+	Name  string `json:"name" bson:"name"`
 }
 
 type UserVotes struct {
-	LocationVotes []int  `json:"location_votes" bson:"location_votes"`
-	TimeVotes     []int  `json:"time_votes" bson:"time_votes"`
+	LocationVotes []int `json:"location_votes" bson:"location_votes"`
+	TimeVotes     []int `json:"time_votes" bson:"time_votes"`
+}
+
+const (
+	PollAnswerYes   = "yes"
+	PollAnswerNo    = "no"
+	PollAnswerMaybe = "maybe"
+)
+
+type PollAnswers struct {
+	// One of PollAnswerYes, PollAnswerNo, or PollAnswerMaybe
+	// for each of the event's candidate times (in the same order)
+	Answers []string `json:"answers" bson:"answers"`
 }
 
 type UserAvailability struct {
 	DayAvailability []DayAvailability `json:"day_availability" bson:"day_availability"`
+	// Time the user needs to travel to (and from) the event.
+	// If null, it is estimated from the user's distance to the midpoint of all users.
+	TravelBufferMinutes *int `json:"travel_buffer_minutes" bson:"travel_buffer_minutes"`
 }
 
 type DayAvailability struct {
+	// The date of the event window that the blocks fall in
 	Date            time.Time           `json:"date" bson:"date"` // ISO 8601 string
 	AvailableBlocks []AvailabilityBlock `json:"available_blocks" bson:"available_blocks"`
 }
 
+// AvailabilityBlock is a period of time that a user is available,
+// which can span midnight
 type AvailabilityBlock struct {
+	Start time.Time `json:"start" bson:"start"`
+	End   time.Time `json:"end" bson:"end"`
+}
+
+// TimeWindow is a period of time between two absolute instants,
+// which can span midnight
+type TimeWindow struct {
+	Start time.Time `json:"start" bson:"start"`
+	End   time.Time `json:"end" bson:"end"`
+}
+
+// DailyWindow is a window given as times of day.
+// If the end time is not after the start time, the window ends on the following day.
+type DailyWindow struct {
 	StartHour   int `json:"start_hour" bson:"start_hour"`
 	StartMinute int `json:"start_minute" bson:"start_minute"`
 	EndHour     int `json:"end_hour" bson:"end_hour"`
 	EndMinute   int `json:"end_minute" bson:"end_minute"`
 }
+
+// DateWindow is a window on a specific date
+type DateWindow struct {
+	Date        time.Time `json:"date" bson:"date"` // ISO 8601 string
+	DailyWindow `bson:",inline"`
+}
@@ -0,0 +1,34 @@
+package types
+
+import "time"
+
+// Profile holds a user's saved defaults that are used for new events
+type Profile struct {
+	UserID string `json:"user_id" bson:"user_id"`
+	// Availability that repeats every week,
+	// used to pre-fill availability for events the user hasn't responded to yet
+	WeeklyAvailability []WeeklyBlock `json:"weekly_availability" bson:"weekly_availability"`
+	// If null, then the user hasn't saved a home location
+	HomeLocation *UserLocation `json:"home_location" bson:"home_location"`
+	// IANA time zone name (such as "America/New_York")
+	// that the weekly availability is in
+	TimeZone string `json:"time_zone" bson:"time_zone"`
+}
+
+// WeeklyBlock is a block of availability that repeats on the same weekday every week.
+// If the end time is not after the start time, the block ends on the following day.
+type WeeklyBlock struct {
+	Weekday     time.Weekday `json:"weekday" bson:"weekday"` // 0 is Sunday
+	DailyWindow `bson:",inline"`
+}
+
+// CalDAVAccount is the calendar on a CalDAV server (such as Nextcloud or Radicale)
+// that a user's availability is pre-filled from
+type CalDAVAccount struct {
+	UserID string `json:"user_id" bson:"user_id"`
+	// URL of the calendar collection
+	URL      string `json:"url" bson:"url"`
+	Username string `json:"username" bson:"username"`
+	// Should be an app password (rather than the user's main password) where the server supports them
+	Password string `json:"password" bson:"password"`
+}
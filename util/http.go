@@ -18,6 +18,8 @@ func ResponseCodeFromError(err error) int {
 		return http.StatusBadRequest
 	case *db.NotFoundError:
 		return http.StatusNotFound
+	case *ValidationError:
+		return http.StatusBadRequest
 	case *json.InvalidUTF8Error:
 		return http.StatusBadRequest
 	case *json.InvalidUnmarshalError:
@@ -55,6 +57,9 @@ func ErrorWithCode(r *http.Request, w http.ResponseWriter, originalError error,
 	response := types.ErrorResponse{
 		Message: fmt.Sprint(originalError),
 	}
+	if validationError, ok := originalError.(*ValidationError); ok {
+		response.Fields = validationError.Fields
+	}
 
 	hlog.FromRequest(r).
 		Warn().
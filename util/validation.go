@@ -0,0 +1,56 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/3-brain-cells/sah-backend/types"
+)
+
+// ValidationError is an error used to encode when a request body
+// has one or more invalid fields
+// (used to provide field-level feedback
+// and to use the correct status code)
+type ValidationError struct {
+	Fields []types.FieldError
+}
+
+// Add records a problem with the given field
+func (e *ValidationError) Add(field string, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, types.FieldError{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// OrNil returns the error if any fields were invalid, and nil otherwise
+func (e *ValidationError) OrNil() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = fmt.Sprintf("%s: %s", field.Field, field.Message)
+	}
+	return fmt.Sprintf("invalid request body (%s)", strings.Join(messages, "; "))
+}
+
+// ValidateDailyWindow records any problems with the window's times of day
+func ValidateDailyWindow(validationError *ValidationError, field string, window types.DailyWindow) {
+	if window.StartHour < 0 || window.StartHour > 23 {
+		validationError.Add(field+".start_hour", "hour %d must be between 0 and 23", window.StartHour)
+	}
+	if window.StartMinute < 0 || window.StartMinute > 59 {
+		validationError.Add(field+".start_minute", "minute %d must be between 0 and 59", window.StartMinute)
+	}
+	if window.EndHour < 0 || window.EndHour > 23 {
+		validationError.Add(field+".end_hour", "hour %d must be between 0 and 23", window.EndHour)
+	}
+	if window.EndMinute < 0 || window.EndMinute > 59 {
+		validationError.Add(field+".end_minute", "minute %d must be between 0 and 59", window.EndMinute)
+	}
+}